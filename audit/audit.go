@@ -0,0 +1,88 @@
+// Package audit works out the field-level changes recorded in the medicine
+// audit log
+package audit
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"medicine-reminder/models"
+	"time"
+)
+
+// SystemActor is the actor of changes made by the server itself rather than
+// through a request
+const SystemActor = "system"
+
+// ignoredFields change with every update or with time alone, so recording
+// them would only add noise
+var ignoredFields = map[string]bool{
+	"status":     true,
+	"updated_at": true,
+	"version":    true,
+}
+
+// Diff returns the fields that differ between the old and new medicine, keyed
+// by their JSON names. A nil old medicine is a creation, where every field
+// with a value counts as changed.
+func Diff(old *models.Medicine, new models.Medicine) (map[string]models.FieldChange, error) {
+	before := map[string]json.RawMessage{}
+	if old != nil {
+		var err error
+		if before, err = fields(*old); err != nil {
+			return nil, err
+		}
+	}
+	after, err := fields(new)
+	if err != nil {
+		return nil, err
+	}
+
+	null := json.RawMessage("null")
+	changes := map[string]models.FieldChange{}
+	for name, value := range after {
+		previous, ok := before[name]
+		if !ok {
+			previous = null
+		}
+		if ignoredFields[name] || bytes.Equal(previous, value) {
+			continue
+		}
+		changes[name] = models.FieldChange{Old: previous, New: value}
+	}
+	return changes, nil
+}
+
+// Record appends a change to the medicine audit log in tx, the transaction
+// that made the change. old is nil when the medicine was created.
+func Record(tx *sql.Tx, action, actor, requestID string, old *models.Medicine, medicine models.Medicine) error {
+	changes, err := Diff(old, medicine)
+	if err != nil {
+		return err
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(medicine)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO medicine_audit (medicine_id, action, actor, request_id, changed_at, changes, snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		medicine.ID, action, actor, requestID, time.Now(), changesJSON, snapshot)
+	return err
+}
+
+// fields returns the JSON of each field of a medicine
+func fields(m models.Medicine) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var f map[string]json.RawMessage
+	err = json.Unmarshal(data, &f)
+	return f, err
+}
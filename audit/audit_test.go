@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"encoding/json"
+	"medicine-reminder/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	old := models.Medicine{ID: 4, Name: "Amoxicillin", Dosage: "500mg", StartDate: start, Status: models.StatusActive, Version: 1}
+
+	updated := old
+	updated.Dosage = "250mg"
+	updated.Notes = "Take with food"
+	updated.Status = models.StatusPaused
+	updated.UpdatedAt = start.Add(time.Hour)
+	updated.Version = 2
+
+	changes, err := Diff(&old, updated)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]models.FieldChange{
+		"dosage": {Old: json.RawMessage(`"500mg"`), New: json.RawMessage(`"250mg"`)},
+		"notes":  {Old: json.RawMessage(`""`), New: json.RawMessage(`"Take with food"`)},
+	}, changes)
+
+	changes, err = Diff(&old, old)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiffCreate(t *testing.T) {
+	m := models.Medicine{ID: 4, Name: "Amoxicillin", Dosage: "500mg"}
+
+	changes, err := Diff(nil, m)
+	assert.NoError(t, err)
+	assert.Equal(t, json.RawMessage("null"), changes["name"].Old)
+	assert.Equal(t, json.RawMessage(`"Amoxicillin"`), changes["name"].New)
+	assert.Equal(t, json.RawMessage("4"), changes["id"].New)
+
+	// Fields without a value aren't changes
+	assert.NotContains(t, changes, "end_date")
+	assert.NotContains(t, changes, "version")
+}
@@ -0,0 +1,174 @@
+// Package catalog is a local drug catalog of generic and brand names,
+// strengths, forms, identifiers, active ingredients and drug classes
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Drug is a catalog entry
+type Drug struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"` // Generic name
+	BrandNames  []string `json:"brand_names"`
+	Ingredients []string `json:"ingredients"` // Active ingredients
+	Classes     []string `json:"classes"`     // Drug classes, e.g. "penicillin"
+	Strengths   []string `json:"strengths"`   // e.g. "500mg" or "125mg/5mL"
+	Forms       []string `json:"forms"`       // Dosage forms, e.g. "tablet"
+	// Identifiers in coding systems, keyed by system, e.g. "atc": "J01CA04"
+	Identifiers map[string]string `json:"identifiers"`
+}
+
+// Catalog is a set of drugs
+type Catalog struct {
+	Version string `json:"version"`
+	Drugs   []Drug `json:"drugs"`
+}
+
+// Load reads and validates a catalog from a JSON file
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	ids := map[string]bool{}
+	for i, d := range c.Drugs {
+		if d.ID == "" || NormalizeName(d.Name) == "" {
+			return nil, fmt.Errorf("invalid drug catalog %s: drug %d needs an id and a name", path, i)
+		}
+		for _, brand := range d.BrandNames {
+			if NormalizeName(brand) == "" {
+				return nil, fmt.Errorf("invalid drug catalog %s: drug %q has a blank brand name", path, d.ID)
+			}
+		}
+		if ids[d.ID] {
+			return nil, fmt.Errorf("invalid drug catalog %s: duplicate drug id %q", path, d.ID)
+		}
+		ids[d.ID] = true
+	}
+	return &c, nil
+}
+
+// current is the catalog used by Current
+var current atomic.Pointer[Catalog]
+
+// Current returns the catalog in use, which is empty until one is loaded
+func Current() *Catalog {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return &Catalog{}
+}
+
+// SetCurrent replaces the catalog in use
+func SetCurrent(c *Catalog) {
+	current.Store(c)
+}
+
+// LoadCurrent loads a catalog from a JSON file and puts it in use. The catalog
+// in use is kept when the file can't be loaded.
+func LoadCurrent(path string) error {
+	c, err := Load(path)
+	if err != nil {
+		return err
+	}
+	SetCurrent(c)
+	return nil
+}
+
+// Get returns the drug with the given ID
+func (c *Catalog) Get(id string) (Drug, bool) {
+	for _, d := range c.Drugs {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Drug{}, false
+}
+
+// Lookup returns the drugs whose generic or brand name is mentioned in a
+// medicine name, e.g. "Amoxil 500mg" gives amoxicillin
+func (c *Catalog) Lookup(name string) []Drug {
+	var found []Drug
+	for _, d := range c.Drugs {
+		if Mentions(name, d.Name) {
+			found = append(found, d)
+			continue
+		}
+		for _, brand := range d.BrandNames {
+			if Mentions(name, brand) {
+				found = append(found, d)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// Contains reports whether the drug is, contains or belongs to the class named
+// by term, returning what matched, e.g. "class penicillin"
+func (d Drug) Contains(term string) (string, bool) {
+	if sameTerm(d.Name, term) {
+		return "drug " + d.Name, true
+	}
+	for _, ingredient := range d.Ingredients {
+		if sameTerm(ingredient, term) {
+			return "ingredient " + ingredient, true
+		}
+	}
+	for _, class := range d.Classes {
+		if sameTerm(class, term) {
+			return "class " + class, true
+		}
+	}
+	return "", false
+}
+
+// NormalizeName lower-cases a name and replaces punctuation with spaces
+func NormalizeName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// Mentions reports whether the words of phrase appear consecutively in name,
+// ignoring case and punctuation
+func Mentions(name, phrase string) bool {
+	words := strings.Fields(NormalizeName(name))
+	target := strings.Fields(NormalizeName(phrase))
+	if len(target) == 0 {
+		return false
+	}
+
+	for i := 0; i+len(target) <= len(words); i++ {
+		match := true
+		for j, word := range target {
+			if words[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTerm compares names ignoring case, punctuation and a plural "s", so
+// "Penicillins" matches "penicillin"
+func sameTerm(a, b string) bool {
+	a, b = NormalizeName(a), NormalizeName(b)
+	return a != "" && (a == b || a+"s" == b || a == b+"s")
+}
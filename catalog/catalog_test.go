@@ -0,0 +1,91 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTestCatalog(t *testing.T) *Catalog {
+	c, err := Load(filepath.Join("testdata", "drugs.json"))
+	assert.NoError(t, err)
+	return c
+}
+
+func TestLookup(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	drugs := c.Lookup("Amoxil 500mg capsules")
+	assert.Len(t, drugs, 1)
+	assert.Equal(t, "amoxicillin", drugs[0].ID)
+
+	drugs = c.Lookup("Sulfamethoxazole/Trimethoprim")
+	assert.Len(t, drugs, 1)
+	assert.Equal(t, "sulfamethoxazole-trimethoprim", drugs[0].ID)
+
+	assert.Empty(t, c.Lookup("Vitamin D"))
+}
+
+func TestContains(t *testing.T) {
+	c := loadTestCatalog(t)
+	amoxicillin := c.Lookup("amoxicillin")[0]
+
+	matched, ok := amoxicillin.Contains("Penicillins")
+	assert.True(t, ok)
+	assert.Equal(t, "class penicillin", matched)
+
+	matched, ok = amoxicillin.Contains("amoxicillin")
+	assert.True(t, ok)
+	assert.Equal(t, "drug amoxicillin", matched)
+
+	bactrim := c.Lookup("Bactrim")[0]
+	matched, ok = bactrim.Contains("trimethoprim")
+	assert.True(t, ok)
+	assert.Equal(t, "ingredient trimethoprim", matched)
+
+	_, ok = amoxicillin.Contains("sulfa")
+	assert.False(t, ok)
+}
+
+func TestMentions(t *testing.T) {
+	assert.True(t, Mentions("Calcium Carbonate 500mg", "calcium carbonate"))
+	assert.True(t, Mentions("co-amoxiclav", "Co-Amoxiclav"))
+	assert.False(t, Mentions("Calcium", "calcium carbonate"))
+	assert.False(t, Mentions("Paracetamol", ""))
+}
+
+func TestLoadDuplicateID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drugs.json")
+	err := os.WriteFile(path, []byte(`{"drugs": [{"id": "a", "name": "a"}, {"id": "a", "name": "b"}]}`), 0o644)
+	assert.NoError(t, err)
+
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestLoadBlankNames(t *testing.T) {
+	for _, drugs := range []string{
+		`[{"id": "a", "name": " "}]`,
+		`[{"id": "a", "name": "--"}]`,
+		`[{"id": "a", "name": "aspirin", "brand_names": ["Bayer", ""]}]`,
+	} {
+		path := filepath.Join(t.TempDir(), "drugs.json")
+		err := os.WriteFile(path, []byte(`{"drugs": `+drugs+`}`), 0o644)
+		assert.NoError(t, err)
+
+		_, err = Load(path)
+		assert.Error(t, err, drugs)
+	}
+}
+
+func TestBundledCatalog(t *testing.T) {
+	c, err := Load(filepath.Join("..", "data", "drugs.json"))
+	assert.NoError(t, err)
+
+	drugs := c.Lookup("Augmentin")
+	assert.Len(t, drugs, 1)
+	_, ok := drugs[0].Contains("penicillin")
+	assert.True(t, ok)
+}
@@ -0,0 +1,140 @@
+package catalog
+
+import (
+	"sort"
+	"strings"
+)
+
+// Match kinds, from best to worst
+const (
+	MatchExact  = "exact"
+	MatchPrefix = "prefix"
+	MatchFuzzy  = "fuzzy"
+)
+
+// matchRank orders match kinds so the best matches come first
+var matchRank = map[string]int{MatchExact: 0, MatchPrefix: 1, MatchFuzzy: 2}
+
+// Result is a drug found by Search
+type Result struct {
+	Drug
+	MatchedName string `json:"matched_name"` // Generic or brand name that matched
+	Match       string `json:"match"`        // "exact", "prefix" or "fuzzy"
+	distance    int
+}
+
+// Search finds drugs by generic or brand name. Names starting with the query
+// match, as do names within a small edit distance of it so misspellings like
+// "paracetmol" still find paracetamol. Results are ordered best match first
+// and at most limit are returned.
+func (c *Catalog) Search(query string, limit int) []Result {
+	q := NormalizeName(query)
+	if q == "" {
+		return []Result{}
+	}
+
+	results := []Result{}
+	for _, d := range c.Drugs {
+		var best *Result
+		for _, name := range append([]string{d.Name}, d.BrandNames...) {
+			r, ok := matchName(q, name)
+			if !ok {
+				continue
+			}
+			if best == nil || r.better(*best) {
+				r.Drug = d
+				best = &r
+			}
+		}
+		if best != nil {
+			results = append(results, *best)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].better(results[j]) || results[j].better(results[i]) {
+			return results[i].better(results[j])
+		}
+		return results[i].Name < results[j].Name
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// matchName compares a normalized query with a drug name
+func matchName(q, name string) (Result, bool) {
+	n := NormalizeName(name)
+	r := Result{MatchedName: name}
+
+	switch {
+	case n == q:
+		r.Match = MatchExact
+		return r, true
+	case strings.HasPrefix(n, q):
+		r.Match = MatchPrefix
+		return r, true
+	}
+
+	// Any word of a multi-word name can start the match, e.g. "contin"
+	if words := strings.Fields(n); len(words) > 1 {
+		for _, word := range words[1:] {
+			if strings.HasPrefix(word, q) {
+				r.Match = MatchPrefix
+				r.distance = 1
+				return r, true
+			}
+		}
+	}
+
+	// Allow roughly one typo per four characters, comparing against both the
+	// whole name and the part of it the user has typed so far
+	allowed := len([]rune(q)) / 4
+	if allowed == 0 {
+		return r, false
+	}
+	distance := levenshtein(q, n)
+	if prefix := []rune(n); len(prefix) > len([]rune(q)) {
+		if d := levenshtein(q, string(prefix[:len([]rune(q))])); d < distance {
+			distance = d
+		}
+	}
+	if distance > allowed {
+		return r, false
+	}
+	r.Match = MatchFuzzy
+	r.distance = distance
+	return r, true
+}
+
+// better reports whether r is a better match than other
+func (r Result) better(other Result) bool {
+	if matchRank[r.Match] != matchRank[other.Match] {
+		return matchRank[r.Match] < matchRank[other.Match]
+	}
+	return r.distance < other.distance
+}
+
+// levenshtein returns the number of single character edits between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
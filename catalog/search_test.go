@@ -0,0 +1,69 @@
+package catalog
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	c := loadTestCatalog(t)
+
+	results := c.Search("para", 10)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "paracetamol", results[0].ID)
+	assert.Equal(t, MatchPrefix, results[0].Match)
+
+	// Brand names are searched too
+	results = c.Search("Tylenol", 10)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "paracetamol", results[0].ID)
+	assert.Equal(t, MatchExact, results[0].Match)
+	assert.Equal(t, "Tylenol", results[0].MatchedName)
+
+	// Misspellings still match
+	results = c.Search("Paracetmol", 10)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "paracetamol", results[0].ID)
+	assert.Equal(t, MatchFuzzy, results[0].Match)
+
+	results = c.Search("amoxcil", 10)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "amoxicillin", results[0].ID)
+
+	// Words after the first in multi-word names
+	results = c.Search("trimeth", 10)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "sulfamethoxazole-trimethoprim", results[0].ID)
+
+	assert.Empty(t, c.Search("xyz", 10))
+	assert.Empty(t, c.Search("", 10))
+}
+
+func TestSearchBlankName(t *testing.T) {
+	// Catalogs built without Load can still hold names that normalize to nothing
+	c := &Catalog{Drugs: []Drug{{ID: "a", Name: "aspirin", BrandNames: []string{"", "--"}}}}
+	assert.NotPanics(t, func() {
+		results := c.Search("asp", 10)
+		assert.Len(t, results, 1)
+	})
+}
+
+func TestSearchOrdersBestMatchFirst(t *testing.T) {
+	c, err := Load(filepath.Join("..", "data", "drugs.json"))
+	assert.NoError(t, err)
+
+	results := c.Search("amox", 10)
+	assert.GreaterOrEqual(t, len(results), 2)
+	assert.Equal(t, "amoxicillin", results[0].ID)
+
+	assert.Len(t, c.Search("a", 3), 3)
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("abc", "abc"))
+	assert.Equal(t, 1, levenshtein("paracetmol", "paracetamol"))
+	assert.Equal(t, 3, levenshtein("kitten", "sitting"))
+	assert.Equal(t, 3, levenshtein("", "abc"))
+}
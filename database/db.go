@@ -3,8 +3,11 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"medicine-reminder/dosage"
+	"medicine-reminder/models"
 	"net/url"
 
 	_ "github.com/lib/pq"
@@ -13,6 +16,10 @@ import (
 // DB is the global database connection pool
 var DB *sql.DB
 
+// FullTextSearch reports whether the database supports Postgres full-text
+// search of medicines. Stores without it are searched in memory instead.
+var FullTextSearch bool
+
 // Config holds database configuration
 type Config struct {
 	Host     string
@@ -59,15 +66,92 @@ func InitDB() {
 		log.Fatalf("Error connecting to the database: %v", err)
 	}
 
+	// Create patients table
+	err = createPatientsTable()
+	if err != nil {
+		log.Fatalf("Error creating patients table: %v", err)
+	}
+
 	// Create medicines table
 	err = createMedicinesTable()
 	if err != nil {
 		log.Fatalf("Error creating medicines table: %v", err)
 	}
 
+	// Create quiet hours table
+	err = createQuietHoursTable()
+	if err != nil {
+		log.Fatalf("Error creating quiet hours table: %v", err)
+	}
+
+	// Create patient travel table
+	err = createPatientTravelTable()
+	if err != nil {
+		log.Fatalf("Error creating patient travel table: %v", err)
+	}
+
+	// Create patient allergies table
+	err = createPatientAllergiesTable()
+	if err != nil {
+		log.Fatalf("Error creating patient allergies table: %v", err)
+	}
+
+	// Create patient anchors table
+	err = createPatientAnchorsTable()
+	if err != nil {
+		log.Fatalf("Error creating patient anchors table: %v", err)
+	}
+
+	// Create doses table
+	err = createDosesTable()
+	if err != nil {
+		log.Fatalf("Error creating doses table: %v", err)
+	}
+
+	// Create inventory transactions table
+	err = createInventoryTransactionsTable()
+	if err != nil {
+		log.Fatalf("Error creating inventory transactions table: %v", err)
+	}
+
+	// Create refill alerts table
+	err = createRefillAlertsTable()
+	if err != nil {
+		log.Fatalf("Error creating refill alerts table: %v", err)
+	}
+
+	// Create medicine audit table
+	err = createMedicineAuditTable()
+	if err != nil {
+		log.Fatalf("Error creating medicine audit table: %v", err)
+	}
+
+	// Full-text search is optional
+	if err = createMedicineSearchIndex(); err != nil {
+		log.Printf("Full-text search unavailable, medicines will be searched in memory: %v", err)
+	}
+	FullTextSearch = err == nil
+
 	log.Println("Database connection established successfully")
 }
 
+// createMedicineSearchIndex adds a weighted full-text search vector over each
+// medicine's name, dosage and notes, with a GIN index
+func createMedicineSearchIndex() error {
+	query := `
+		ALTER TABLE medicines
+			ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(dosage, '')), 'B') ||
+				setweight(to_tsvector('english', coalesce(notes, '')), 'C')
+			) STORED;
+		CREATE INDEX IF NOT EXISTS medicines_search_vector_idx ON medicines USING GIN (search_vector);
+	`
+
+	_, err := DB.Exec(query)
+	return err
+}
+
 // createMedicinesTable creates the medicines table if it doesn't exist
 func createMedicinesTable() error {
 	createTableQuery := `
@@ -76,15 +160,410 @@ func createMedicinesTable() error {
 			name VARCHAR(255) NOT NULL,
 			dosage VARCHAR(255) NOT NULL,
 			frequency VARCHAR(255) NOT NULL,
-			time_of_day VARCHAR(255) NOT NULL,
-			start_date TIMESTAMP NOT NULL,
-			end_date TIMESTAMP NOT NULL,
+			time_of_day JSONB NOT NULL,
+			start_date TIMESTAMPTZ NOT NULL,
+			end_date TIMESTAMPTZ,
 			notes TEXT,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
 	`
 
+	if _, err := DB.Exec(createTableQuery); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema
+	alterTableQuery := `
+		ALTER TABLE medicines
+			ADD COLUMN IF NOT EXISTS patient_id INTEGER REFERENCES patients(id) ON DELETE CASCADE,
+			ADD COLUMN IF NOT EXISTS quiet_hours_policy VARCHAR(20) NOT NULL DEFAULT 'defer',
+			ADD COLUMN IF NOT EXISTS schedule_type VARCHAR(20) NOT NULL DEFAULT 'wall_clock',
+			ADD COLUMN IF NOT EXISTS stock_quantity NUMERIC(10, 2),
+			ADD COLUMN IF NOT EXISTS units_per_dose NUMERIC(10, 2) NOT NULL DEFAULT 1,
+			ADD COLUMN IF NOT EXISTS refill_threshold_days INTEGER NOT NULL DEFAULT 7,
+			ADD COLUMN IF NOT EXISTS dosage_amount NUMERIC(12, 4),
+			ADD COLUMN IF NOT EXISTS dosage_unit VARCHAR(20),
+			ADD COLUMN IF NOT EXISTS dosage_form VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS as_needed BOOLEAN NOT NULL DEFAULT FALSE,
+			ADD COLUMN IF NOT EXISTS max_doses_per_24h INTEGER,
+			ADD COLUMN IF NOT EXISTS min_dose_interval_minutes INTEGER,
+			ADD COLUMN IF NOT EXISTS drug_id VARCHAR(100),
+			ADD COLUMN IF NOT EXISTS phases JSONB NOT NULL DEFAULT '[]',
+			ADD COLUMN IF NOT EXISTS relative_times JSONB NOT NULL DEFAULT '[]',
+			ADD COLUMN IF NOT EXISTS paused_at TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS pause_reason TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS review_interval_days INTEGER,
+			ADD COLUMN IF NOT EXISTS next_review_at TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS last_reviewed_at TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS review_reminded_at TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1,
+			ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+			ALTER COLUMN end_date DROP NOT NULL;
+
+		CREATE INDEX IF NOT EXISTS medicines_deleted_at_idx ON medicines (deleted_at) WHERE deleted_at IS NOT NULL;
+	`
+
+	if _, err := DB.Exec(alterTableQuery); err != nil {
+		return err
+	}
+
+	// Older databases stored course dates without a time zone; treat them as UTC
+	convertDatesQuery := `
+		DO $$
+		BEGIN
+			IF (SELECT data_type FROM information_schema.columns
+				WHERE table_name = 'medicines' AND column_name = 'start_date') = 'timestamp without time zone' THEN
+				ALTER TABLE medicines
+					ALTER COLUMN start_date TYPE TIMESTAMPTZ USING start_date AT TIME ZONE 'UTC',
+					ALTER COLUMN end_date TYPE TIMESTAMPTZ USING end_date AT TIME ZONE 'UTC';
+			END IF;
+		END $$;
+	`
+
+	if _, err := DB.Exec(convertDatesQuery); err != nil {
+		return err
+	}
+
+	if err := migrateTimeOfDay(); err != nil {
+		return err
+	}
+
+	return backfillDosages()
+}
+
+// backfillDosages fills in the structured dosage of medicines created before
+// dosages were parsed. Dosages that can't be parsed are left empty.
+func backfillDosages() error {
+	rows, err := DB.Query("SELECT id, dosage FROM medicines WHERE dosage_unit IS NULL")
+	if err != nil {
+		return err
+	}
+
+	parsed := make(map[int]dosage.Dosage)
+	for rows.Next() {
+		var id int
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		if d, err := dosage.Parse(raw); err == nil {
+			parsed[id] = d
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, d := range parsed {
+		_, err := DB.Exec("UPDATE medicines SET dosage_amount = $1, dosage_unit = $2, dosage_form = $3 WHERE id = $4",
+			d.Amount, string(d.Unit), d.Form, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTimeOfDay converts time_of_day from a JSON string in a VARCHAR column to
+// JSONB, normalizing the stored times. Entries that are not valid times are dropped
+// and logged so the affected medicines can be corrected.
+func migrateTimeOfDay() error {
+	var dataType string
+	err := DB.QueryRow(`
+		SELECT data_type FROM information_schema.columns
+		WHERE table_name = 'medicines' AND column_name = 'time_of_day'`).Scan(&dataType)
+	if err != nil || dataType == "jsonb" {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, time_of_day FROM medicines")
+	if err != nil {
+		return err
+	}
+
+	normalized := make(map[int]models.TimesOfDay)
+	for rows.Next() {
+		var id int
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+
+		var values []string
+		if err := json.Unmarshal([]byte(raw), &values); err != nil {
+			log.Printf("Medicine %d: dropping unreadable time of day %q", id, raw)
+		}
+
+		var times models.TimesOfDay
+		for _, v := range values {
+			t, err := models.ParseClockTime(v)
+			if err != nil {
+				log.Printf("Medicine %d: dropping invalid time of day: %v", id, err)
+				continue
+			}
+			times = append(times, t)
+		}
+		normalized[id] = times.Normalize()
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("ALTER TABLE medicines ALTER COLUMN time_of_day TYPE JSONB USING '[]'::jsonb"); err != nil {
+		return err
+	}
+	for id, times := range normalized {
+		if _, err := tx.Exec("UPDATE medicines SET time_of_day = $1 WHERE id = $2", times, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// createPatientsTable creates the patients table if it doesn't exist
+func createPatientsTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS patients (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+	if _, err := DB.Exec(createTableQuery); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema
+	alterTableQuery := `
+		ALTER TABLE patients
+			ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+	`
+
+	_, err := DB.Exec(alterTableQuery)
+	return err
+}
+
+// createQuietHoursTable creates the quiet_hours table if it doesn't exist.
+// Windows saved as "H:MM" by older versions are padded to "HH:MM".
+func createQuietHoursTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS quiet_hours (
+			id SERIAL PRIMARY KEY,
+			patient_id INTEGER NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+			start_time VARCHAR(5) NOT NULL,
+			end_time VARCHAR(5) NOT NULL
+		);
+
+		UPDATE quiet_hours SET start_time = '0' || start_time WHERE length(start_time) = 4;
+		UPDATE quiet_hours SET end_time = '0' || end_time WHERE length(end_time) = 4;
+	`
+
 	_, err := DB.Exec(createTableQuery)
 	return err
 }
+
+// createPatientTravelTable creates the patient_travel table if it doesn't exist
+func createPatientTravelTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS patient_travel (
+			patient_id INTEGER PRIMARY KEY REFERENCES patients(id) ON DELETE CASCADE,
+			timezone VARCHAR(64) NOT NULL,
+			start_at TIMESTAMPTZ NOT NULL,
+			end_at TIMESTAMPTZ,
+			max_shift_minutes INTEGER NOT NULL DEFAULT 60
+		);
+	`
+
+	_, err := DB.Exec(createTableQuery)
+	return err
+}
+
+// createPatientAllergiesTable creates the patient_allergies table if it doesn't exist
+func createPatientAllergiesTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS patient_allergies (
+			id SERIAL PRIMARY KEY,
+			patient_id INTEGER NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+			allergen VARCHAR(255) NOT NULL,
+			severity VARCHAR(20) NOT NULL DEFAULT 'severe',
+			reaction TEXT NOT NULL DEFAULT ''
+		);
+	`
+
+	_, err := DB.Exec(createTableQuery)
+	return err
+}
+
+// createPatientAnchorsTable creates the patient_anchors table if it doesn't exist
+func createPatientAnchorsTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS patient_anchors (
+			patient_id INTEGER NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+			event VARCHAR(20) NOT NULL,
+			time VARCHAR(5) NOT NULL,
+			PRIMARY KEY (patient_id, event)
+		);
+	`
+
+	_, err := DB.Exec(createTableQuery)
+	return err
+}
+
+// createDosesTable creates the doses table if it doesn't exist
+func createDosesTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS doses (
+			id SERIAL PRIMARY KEY,
+			medicine_id INTEGER NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL,
+			scheduled_at TIMESTAMPTZ,
+			taken_at TIMESTAMPTZ NOT NULL,
+			notes TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS doses_medicine_taken_at_idx ON doses (medicine_id, taken_at);
+	`
+
+	if _, err := DB.Exec(createTableQuery); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema
+	alterTableQuery := `
+		ALTER TABLE doses
+			ADD COLUMN IF NOT EXISTS override_reason TEXT NOT NULL DEFAULT '';
+	`
+
+	_, err := DB.Exec(alterTableQuery)
+	return err
+}
+
+// createInventoryTransactionsTable creates the inventory_transactions table if it doesn't exist
+func createInventoryTransactionsTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS inventory_transactions (
+			id SERIAL PRIMARY KEY,
+			medicine_id INTEGER NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+			dose_id INTEGER REFERENCES doses(id) ON DELETE SET NULL,
+			change NUMERIC(10, 2) NOT NULL,
+			balance_after NUMERIC(10, 2) NOT NULL,
+			reason TEXT NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+	_, err := DB.Exec(createTableQuery)
+	return err
+}
+
+// createRefillAlertsTable creates the refill_alerts table if it doesn't exist.
+// Each medicine has at most one open alert per refill cycle. A cycle ends
+// when the stock is next found above the refill threshold.
+func createRefillAlertsTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS refill_alerts (
+			id SERIAL PRIMARY KEY,
+			medicine_id INTEGER NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+			stock_quantity NUMERIC(10, 2) NOT NULL,
+			run_out_at TIMESTAMPTZ NOT NULL,
+			raised_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			acknowledged_at TIMESTAMPTZ
+		);
+		ALTER TABLE refill_alerts ADD COLUMN IF NOT EXISTS cycle_ended_at TIMESTAMPTZ;
+		CREATE UNIQUE INDEX IF NOT EXISTS refill_alerts_open_idx
+			ON refill_alerts (medicine_id) WHERE acknowledged_at IS NULL;
+	`
+
+	_, err := DB.Exec(createTableQuery)
+	return err
+}
+
+// createMedicineAuditTable creates the medicine_audit table if it doesn't
+// exist. The table is append-only: a trigger rejects every UPDATE, DELETE and
+// TRUNCATE. It has no foreign key so entries outlive purged medicines.
+func createMedicineAuditTable() error {
+	createTableQuery := `
+		CREATE TABLE IF NOT EXISTS medicine_audit (
+			id BIGSERIAL PRIMARY KEY,
+			medicine_id INTEGER NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			actor TEXT NOT NULL,
+			request_id TEXT NOT NULL,
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			changes JSONB NOT NULL,
+			snapshot JSONB NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS medicine_audit_medicine_idx ON medicine_audit (medicine_id, id);
+
+		CREATE OR REPLACE FUNCTION medicine_audit_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'medicine_audit is append-only';
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS medicine_audit_append_only ON medicine_audit;
+		CREATE TRIGGER medicine_audit_append_only
+			BEFORE UPDATE OR DELETE OR TRUNCATE ON medicine_audit
+			FOR EACH STATEMENT EXECUTE FUNCTION medicine_audit_append_only();
+	`
+
+	if _, err := DB.Exec(createTableQuery); err != nil {
+		return err
+	}
+	return baselineMedicineAudit()
+}
+
+// baselineMedicineAudit records the current state of medicines that have no
+// audit entries, such as those created before the audit log existed, so
+// point-in-time views include them from now on
+func baselineMedicineAudit() error {
+	rows, err := DB.Query("SELECT " + MedicineColumns + `
+		FROM medicines
+		WHERE NOT EXISTS (SELECT 1 FROM medicine_audit a WHERE a.medicine_id = medicines.id)`)
+	if err != nil {
+		return err
+	}
+
+	var medicines []models.Medicine
+	for rows.Next() {
+		m, err := ScanMedicine(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		medicines = append(medicines, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range medicines {
+		snapshot, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = DB.Exec(`
+			INSERT INTO medicine_audit (medicine_id, action, actor, request_id, changes, snapshot)
+			VALUES ($1, $2, 'system', 'baseline', '{}', $3)`,
+			m.ID, models.AuditBaseline, snapshot)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
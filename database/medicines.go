@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"medicine-reminder/models"
+	"time"
+)
+
+// MedicineColumns lists the medicines columns in the order expected by ScanMedicine
+const MedicineColumns = `id, name, dosage, frequency, time_of_day, start_date, end_date, notes,
+	created_at, updated_at, patient_id, quiet_hours_policy, schedule_type,
+	stock_quantity, units_per_dose, refill_threshold_days,
+	dosage_amount, dosage_unit, dosage_form,
+	as_needed, max_doses_per_24h, min_dose_interval_minutes, drug_id,
+	phases, relative_times, paused_at, pause_reason,
+	review_interval_days, next_review_at, last_reviewed_at, version, deleted_at`
+
+// Scanner is implemented by both *sql.Row and *sql.Rows
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// ScanMedicine reads a medicine selected with MedicineColumns
+func ScanMedicine(s Scanner) (models.Medicine, error) {
+	var m models.Medicine
+	var patientID sql.NullInt64
+	var stock sql.NullFloat64
+	var dosageAmount sql.NullFloat64
+	var dosageUnit, dosageForm sql.NullString
+
+	err := s.Scan(&m.ID, &m.Name, &m.Dosage, &m.Frequency, &m.TimeOfDay,
+		&m.StartDate, &m.EndDate, &m.Notes, &m.CreatedAt, &m.UpdatedAt,
+		&patientID, &m.QuietHoursPolicy, &m.ScheduleType, &stock, &m.UnitsPerDose, &m.RefillThresholdDays,
+		&dosageAmount, &dosageUnit, &dosageForm,
+		&m.AsNeeded, &m.MaxDosesPer24h, &m.MinDoseIntervalMinutes, &m.DrugID,
+		&m.Phases, &m.RelativeTimes, &m.PausedAt, &m.PauseReason,
+		&m.ReviewIntervalDays, &m.NextReviewAt, &m.LastReviewedAt, &m.Version, &m.DeletedAt)
+	if err != nil {
+		return m, err
+	}
+
+	if patientID.Valid {
+		id := int(patientID.Int64)
+		m.PatientID = &id
+	}
+	if stock.Valid {
+		m.StockQuantity = &stock.Float64
+	}
+	if dosageAmount.Valid {
+		m.DosageAmount = &dosageAmount.Float64
+	}
+	m.DosageUnit = dosageUnit.String
+	m.DosageForm = dosageForm.String
+	m.Status = m.StatusAt(time.Now())
+	return m, nil
+}
@@ -0,0 +1,159 @@
+// Package dosage parses free-text dosages such as "500mg" or "2 puffs" into
+// an amount, a unit and a dosage form
+package dosage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Unit is a canonical dosage unit
+type Unit string
+
+// Supported units
+const (
+	Milligram  Unit = "mg"
+	Microgram  Unit = "mcg"
+	Gram       Unit = "g"
+	Milliliter Unit = "mL"
+	IU         Unit = "IU"
+	Tablet     Unit = "tablets"
+	Puff       Unit = "puffs"
+	Drop       Unit = "drops"
+)
+
+// unitAliases maps lower-case spellings to canonical units
+var unitAliases = map[string]Unit{
+	"mg": Milligram, "milligram": Milligram, "milligrams": Milligram,
+	"mcg": Microgram, "ug": Microgram, "µg": Microgram, "microgram": Microgram, "micrograms": Microgram,
+	"g": Gram, "gram": Gram, "grams": Gram,
+	"ml": Milliliter, "milliliter": Milliliter, "milliliters": Milliliter, "millilitre": Milliliter, "millilitres": Milliliter,
+	"iu": IU, "unit": IU, "units": IU,
+	"tablet": Tablet, "tablets": Tablet, "tab": Tablet, "tabs": Tablet,
+	"puff": Puff, "puffs": Puff,
+	"drop": Drop, "drops": Drop,
+}
+
+// Dosage forms
+const (
+	FormTablet    = "tablet"
+	FormCapsule   = "capsule"
+	FormLiquid    = "liquid"
+	FormInhaler   = "inhaler"
+	FormDrops     = "drops"
+	FormInjection = "injection"
+	FormTopical   = "topical"
+	FormPatch     = "patch"
+)
+
+// formAliases maps lower-case words found after the unit to dosage forms
+var formAliases = map[string]string{
+	"tablet": FormTablet, "tablets": FormTablet, "tab": FormTablet, "tabs": FormTablet, "caplet": FormTablet,
+	"capsule": FormCapsule, "capsules": FormCapsule, "cap": FormCapsule, "caps": FormCapsule,
+	"liquid": FormLiquid, "syrup": FormLiquid, "solution": FormLiquid, "suspension": FormLiquid, "elixir": FormLiquid,
+	"inhaler": FormInhaler, "inhalation": FormInhaler, "spray": FormInhaler,
+	"drops": FormDrops, "drop": FormDrops,
+	"injection": FormInjection, "injections": FormInjection,
+	"cream": FormTopical, "ointment": FormTopical, "gel": FormTopical,
+	"patch": FormPatch, "patches": FormPatch,
+}
+
+// defaultForms gives the form implied by units that are themselves forms
+var defaultForms = map[Unit]string{
+	Tablet:     FormTablet,
+	Puff:       FormInhaler,
+	Drop:       FormDrops,
+	Milliliter: FormLiquid,
+}
+
+// Dosage is a parsed dosage
+type Dosage struct {
+	Amount float64
+	Unit   Unit
+	Form   string // Empty when the dosage doesn't say
+}
+
+// Parse reads a dosage made of an amount, a unit and optional words naming the
+// form, e.g. "500mg", "0.5 g", "2 tablets", "5 mL syrup" or "250mg capsule"
+func Parse(s string) (Dosage, error) {
+	s = strings.TrimSpace(s)
+
+	// Split the leading number from the rest
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	if end == 0 {
+		return Dosage{}, fmt.Errorf("dosage %q must start with an amount", s)
+	}
+	amount, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil || amount <= 0 {
+		return Dosage{}, fmt.Errorf("dosage %q has an invalid amount", s)
+	}
+
+	words := strings.FieldsFunc(s[end:], func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})
+	if len(words) == 0 {
+		return Dosage{}, fmt.Errorf("dosage %q must include a unit", s)
+	}
+
+	unit, ok := unitAliases[strings.ToLower(strings.TrimSuffix(words[0], "."))]
+	if !ok {
+		return Dosage{}, fmt.Errorf("dosage %q has an unknown unit %q", s, words[0])
+	}
+
+	d := Dosage{Amount: amount, Unit: unit, Form: defaultForms[unit]}
+	for _, word := range words[1:] {
+		if form, ok := formAliases[strings.ToLower(word)]; ok {
+			d.Form = form
+			break
+		}
+	}
+	return d, nil
+}
+
+// String formats the dosage, e.g. "500mg tablet"
+func (d Dosage) String() string {
+	amount := strconv.FormatFloat(d.Amount, 'f', -1, 64)
+	var s string
+	switch d.Unit {
+	case Milligram, Microgram, Gram:
+		s = amount + string(d.Unit)
+	default:
+		s = amount + " " + string(d.Unit)
+	}
+	if d.Form != "" && d.Form != defaultForms[d.Unit] {
+		s += " " + d.Form
+	}
+	return s
+}
+
+// In returns the dosage amount converted to another unit
+func (d Dosage) In(unit Unit) (float64, error) {
+	return Convert(d.Amount, d.Unit, unit)
+}
+
+// toMicrograms gives the size of each mass unit in micrograms
+var toMicrograms = map[Unit]float64{
+	Microgram: 1,
+	Milligram: 1000,
+	Gram:      1000000,
+}
+
+// Convert converts an amount between units. Mass units convert between each
+// other; every other unit only converts to itself.
+func Convert(amount float64, from, to Unit) (float64, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	fromFactor, fromMass := toMicrograms[from]
+	toFactor, toMass := toMicrograms[to]
+	if !fromMass || !toMass {
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return amount * fromFactor / toFactor, nil
+}
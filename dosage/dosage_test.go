@@ -0,0 +1,65 @@
+package dosage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		input string
+		want  Dosage
+	}{
+		{"500mg", Dosage{Amount: 500, Unit: Milligram}},
+		{"0.5 g", Dosage{Amount: 0.5, Unit: Gram}},
+		{"100 mcg", Dosage{Amount: 100, Unit: Microgram}},
+		{"2 tablets", Dosage{Amount: 2, Unit: Tablet, Form: FormTablet}},
+		{"1 tab", Dosage{Amount: 1, Unit: Tablet, Form: FormTablet}},
+		{"5 mL syrup", Dosage{Amount: 5, Unit: Milliliter, Form: FormLiquid}},
+		{"5ml", Dosage{Amount: 5, Unit: Milliliter, Form: FormLiquid}},
+		{"1000 IU", Dosage{Amount: 1000, Unit: IU}},
+		{"2 puffs", Dosage{Amount: 2, Unit: Puff, Form: FormInhaler}},
+		{"3 drops", Dosage{Amount: 3, Unit: Drop, Form: FormDrops}},
+		{"250mg capsule", Dosage{Amount: 250, Unit: Milligram, Form: FormCapsule}},
+		{"40 mg, film-coated tablets", Dosage{Amount: 40, Unit: Milligram, Form: FormTablet}},
+	}
+
+	for _, c := range cases {
+		got, err := Parse(c.input)
+		assert.NoError(t, err, c.input)
+		assert.Equal(t, c.want, got, c.input)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{"", "mg", "one tablet", "500", "500 bottles", "0mg", "1.2.3mg"} {
+		_, err := Parse(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "500mg", Dosage{Amount: 500, Unit: Milligram}.String())
+	assert.Equal(t, "2 tablets", Dosage{Amount: 2, Unit: Tablet, Form: FormTablet}.String())
+	assert.Equal(t, "250mg capsule", Dosage{Amount: 250, Unit: Milligram, Form: FormCapsule}.String())
+}
+
+func TestConvert(t *testing.T) {
+	grams, err := Dosage{Amount: 500, Unit: Milligram}.In(Gram)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, grams)
+
+	mcg, err := Convert(0.125, Milligram, Microgram)
+	assert.NoError(t, err)
+	assert.Equal(t, 125.0, mcg)
+
+	same, err := Convert(2, Puff, Puff)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, same)
+
+	_, err = Convert(5, Milliliter, Milligram)
+	assert.Error(t, err)
+	_, err = Convert(1, Tablet, Milligram)
+	assert.Error(t, err)
+}
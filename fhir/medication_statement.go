@@ -0,0 +1,257 @@
+package fhir
+
+import (
+	"fmt"
+	"medicine-reminder/dosage"
+	"medicine-reminder/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DrugSystem identifies drug catalog IDs in medication codings
+const DrugSystem = "urn:medicine-reminder:drug-catalog"
+
+// ucumSystem identifies UCUM unit codes
+const ucumSystem = "http://unitsofmeasure.org"
+
+// ucumCodes gives the UCUM code of each dosage unit
+var ucumCodes = map[dosage.Unit]string{
+	dosage.Milligram:  "mg",
+	dosage.Microgram:  "ug",
+	dosage.Gram:       "g",
+	dosage.Milliliter: "mL",
+	dosage.IU:         "[iU]",
+	dosage.Tablet:     "{tablet}",
+	dosage.Puff:       "{puff}",
+	dosage.Drop:       "[drp]",
+}
+
+// statuses maps lifecycle statuses to MedicationStatement statuses
+var statuses = map[string]string{
+	models.StatusUpcoming:  "intended",
+	models.StatusActive:    "active",
+	models.StatusPaused:    "on-hold",
+	models.StatusCompleted: "completed",
+}
+
+// Status returns the MedicationStatement status of a lifecycle status
+func Status(status string) string {
+	return statuses[status]
+}
+
+// LifecycleStatus returns the lifecycle status of a MedicationStatement
+// status, if it has one
+func LifecycleStatus(status string) (string, bool) {
+	for lifecycle, s := range statuses {
+		if s == status {
+			return lifecycle, true
+		}
+	}
+	return "", false
+}
+
+// FromMedicine returns a medicine as a MedicationStatement. The dosage text
+// and frequency are kept as written, alongside the structured dose and a
+// daily timing built from the times of day. Tapering phases and relative
+// times aren't represented.
+func FromMedicine(m models.Medicine) MedicationStatement {
+	s := MedicationStatement{
+		ResourceType: "MedicationStatement",
+		ID:           strconv.Itoa(m.ID),
+		Meta:         &Meta{VersionID: strconv.Itoa(m.Version), LastUpdated: formatDateTime(m.UpdatedAt)},
+		Status:       Status(m.Status),
+		MedicationCodeableConcept: &CodeableConcept{
+			Text: m.Name,
+		},
+		EffectivePeriod: &Period{Start: formatDateTime(m.StartDate)},
+		DateAsserted:    formatDateTime(m.CreatedAt),
+	}
+	if m.DrugID != nil {
+		s.MedicationCodeableConcept.Coding = []Coding{{System: DrugSystem, Code: *m.DrugID, Display: m.Name}}
+	}
+	if m.PatientID != nil {
+		s.Subject = &Reference{Reference: "Patient/" + strconv.Itoa(*m.PatientID)}
+	}
+	if m.EndDate != nil {
+		s.EffectivePeriod.End = formatDateTime(*m.EndDate)
+	}
+	if m.Notes != "" {
+		s.Note = []Annotation{{Text: m.Notes}}
+	}
+
+	d := Dosage{
+		Text:            m.Dosage,
+		AsNeededBoolean: m.AsNeeded,
+		Timing:          &Timing{Code: &CodeableConcept{Text: m.Frequency}},
+	}
+	if times := m.TimeOfDay.Strings(); len(times) > 0 {
+		repeat := &TimingRepeat{Frequency: len(times), Period: 1, PeriodUnit: "d"}
+		for _, t := range times {
+			repeat.TimeOfDay = append(repeat.TimeOfDay, t+":00")
+		}
+		d.Timing.Repeat = repeat
+	}
+	if m.DosageAmount != nil {
+		q := &Quantity{Value: *m.DosageAmount, Unit: m.DosageUnit}
+		if code, ok := ucumCodes[dosage.Unit(m.DosageUnit)]; ok {
+			q.System, q.Code = ucumSystem, code
+		}
+		d.DoseAndRate = []DoseAndRate{{DoseQuantity: q}}
+	}
+	if m.MaxDosesPer24h != nil {
+		d.MaxDosePerPeriod = &Ratio{
+			Numerator:   &Quantity{Value: float64(*m.MaxDosesPer24h)},
+			Denominator: &Quantity{Value: 24, Unit: "h", System: ucumSystem, Code: "h"},
+		}
+	}
+	s.Dosage = []Dosage{d}
+	return s
+}
+
+// ToInput reads a MedicationStatement into a medicine input. Only the first
+// dosage is read. Subjects must reference a patient of this service, as
+// "Patient/<id>".
+func ToInput(s MedicationStatement) (models.MedicineInput, error) {
+	var input models.MedicineInput
+	if s.ResourceType != "MedicationStatement" {
+		return input, fmt.Errorf("resourceType must be MedicationStatement")
+	}
+
+	if c := s.MedicationCodeableConcept; c != nil {
+		input.Name = c.Text
+		for _, coding := range c.Coding {
+			if input.Name == "" {
+				input.Name = coding.Display
+			}
+			if coding.System == DrugSystem && coding.Code != "" {
+				code := coding.Code
+				input.DrugID = &code
+			}
+		}
+	}
+	if input.Name == "" {
+		return input, fmt.Errorf("medicationCodeableConcept must have a text or display")
+	}
+
+	if s.Subject != nil && s.Subject.Reference != "" {
+		id, err := strconv.Atoi(strings.TrimPrefix(s.Subject.Reference, "Patient/"))
+		if err != nil || !strings.HasPrefix(s.Subject.Reference, "Patient/") {
+			return input, fmt.Errorf("subject %q must reference a patient as Patient/<id>", s.Subject.Reference)
+		}
+		input.PatientID = &id
+	}
+
+	var err error
+	start := s.EffectiveDateTime
+	if s.EffectivePeriod != nil {
+		start = s.EffectivePeriod.Start
+		if s.EffectivePeriod.End != "" {
+			if input.EndDate, err = parseDateTime(s.EffectivePeriod.End); err != nil {
+				return input, fmt.Errorf("effectivePeriod.end: %v", err)
+			}
+		}
+	}
+	if start == "" {
+		start = s.DateAsserted
+	}
+	if start != "" {
+		if input.StartDate, err = parseDateTime(start); err != nil {
+			return input, fmt.Errorf("effective start: %v", err)
+		}
+	}
+
+	var notes []string
+	for _, n := range s.Note {
+		notes = append(notes, n.Text)
+	}
+	input.Notes = strings.Join(notes, "\n")
+
+	if len(s.Dosage) == 0 {
+		return input, nil
+	}
+	d := s.Dosage[0]
+	input.AsNeeded = d.AsNeededBoolean
+	input.Dosage = d.Text
+	if input.Dosage == "" && len(d.DoseAndRate) > 0 && d.DoseAndRate[0].DoseQuantity != nil {
+		q := d.DoseAndRate[0].DoseQuantity
+		input.Dosage = strconv.FormatFloat(q.Value, 'f', -1, 64) + q.Unit
+	}
+	if d.Timing != nil {
+		if d.Timing.Code != nil {
+			input.Frequency = d.Timing.Code.Text
+		}
+		if r := d.Timing.Repeat; r != nil {
+			for _, t := range r.TimeOfDay {
+				input.TimeOfDay = append(input.TimeOfDay, strings.TrimSuffix(t, ":00"))
+			}
+			if input.Frequency == "" {
+				input.Frequency = describeFrequency(r)
+			}
+		}
+	}
+	if r := d.MaxDosePerPeriod; r != nil && r.Numerator != nil && r.Denominator != nil {
+		if hours := periodHours(*r.Denominator); hours == 24 {
+			n := int(r.Numerator.Value)
+			input.MaxDosesPer24h = &n
+		}
+	}
+	return input, nil
+}
+
+// describeFrequency writes a timing's repeat as a frequency like "Twice daily"
+func describeFrequency(r *TimingRepeat) string {
+	if r.Frequency == 0 || r.Period == 0 {
+		return ""
+	}
+	if r.Period == 1 && r.PeriodUnit == "d" {
+		switch r.Frequency {
+		case 1:
+			return "Once daily"
+		case 2:
+			return "Twice daily"
+		case 3:
+			return "Three times daily"
+		default:
+			return fmt.Sprintf("%d times daily", r.Frequency)
+		}
+	}
+	return fmt.Sprintf("%d times every %s %s", r.Frequency, strconv.FormatFloat(r.Period, 'f', -1, 64), r.PeriodUnit)
+}
+
+// periodHours returns the length of a period quantity in hours, or 0 if its
+// unit isn't understood
+func periodHours(q Quantity) float64 {
+	unit := q.Code
+	if unit == "" {
+		unit = q.Unit
+	}
+	switch unit {
+	case "h":
+		return q.Value
+	case "d":
+		return q.Value * 24
+	}
+	return 0
+}
+
+// formatDateTime formats a time as a FHIR dateTime, or as a date when it is
+// midnight UTC
+func formatDateTime(t time.Time) string {
+	t = t.UTC()
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// parseDateTime parses a FHIR date or dateTime. Partial dates start at the
+// beginning of their year or month.
+func parseDateTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a FHIR date or dateTime", s)
+}
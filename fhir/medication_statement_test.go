@@ -0,0 +1,174 @@
+package fhir
+
+import (
+	"encoding/json"
+	"medicine-reminder/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromMedicine(t *testing.T) {
+	patient, maxDoses := 4, 3
+	amount := 500.0
+	drug := "paracetamol"
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	m := models.Medicine{
+		ID:             12,
+		Name:           "Paracetamol",
+		Dosage:         "500mg",
+		Frequency:      "Twice daily",
+		TimeOfDay:      models.TimesOfDay{8 * 60, 20*60 + 30},
+		StartDate:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:        &end,
+		Notes:          "With food",
+		CreatedAt:      time.Date(2024, 2, 28, 10, 15, 0, 0, time.UTC),
+		UpdatedAt:      time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC),
+		PatientID:      &patient,
+		DosageAmount:   &amount,
+		DosageUnit:     "mg",
+		MaxDosesPer24h: &maxDoses,
+		DrugID:         &drug,
+		Status:         models.StatusActive,
+		Version:        2,
+	}
+
+	s := FromMedicine(m)
+	assert.Equal(t, "MedicationStatement", s.ResourceType)
+	assert.Equal(t, "12", s.ID)
+	assert.Equal(t, "2", s.Meta.VersionID)
+	assert.Equal(t, "active", s.Status)
+	assert.Equal(t, "Paracetamol", s.MedicationCodeableConcept.Text)
+	assert.Equal(t, []Coding{{System: DrugSystem, Code: "paracetamol", Display: "Paracetamol"}}, s.MedicationCodeableConcept.Coding)
+	assert.Equal(t, "Patient/4", s.Subject.Reference)
+	assert.Equal(t, &Period{Start: "2024-03-01", End: "2024-03-31"}, s.EffectivePeriod)
+	assert.Equal(t, "2024-02-28T10:15:00Z", s.DateAsserted)
+	assert.Equal(t, []Annotation{{Text: "With food"}}, s.Note)
+
+	assert.Len(t, s.Dosage, 1)
+	d := s.Dosage[0]
+	assert.Equal(t, "500mg", d.Text)
+	assert.Equal(t, "Twice daily", d.Timing.Code.Text)
+	assert.Equal(t, &TimingRepeat{Frequency: 2, Period: 1, PeriodUnit: "d", TimeOfDay: []string{"08:00:00", "20:30:00"}}, d.Timing.Repeat)
+	assert.Equal(t, &Quantity{Value: 500, Unit: "mg", System: ucumSystem, Code: "mg"}, d.DoseAndRate[0].DoseQuantity)
+	assert.Equal(t, 3.0, d.MaxDosePerPeriod.Numerator.Value)
+	assert.Equal(t, 24.0, d.MaxDosePerPeriod.Denominator.Value)
+}
+
+func TestFromMedicineIndefinite(t *testing.T) {
+	s := FromMedicine(models.Medicine{
+		ID:        1,
+		Name:      "Salbutamol",
+		Dosage:    "2 puffs",
+		AsNeeded:  true,
+		StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Status:    models.StatusPaused,
+	})
+	assert.Equal(t, "on-hold", s.Status)
+	assert.Empty(t, s.EffectivePeriod.End)
+	assert.Nil(t, s.Subject)
+	assert.True(t, s.Dosage[0].AsNeededBoolean)
+	assert.Nil(t, s.Dosage[0].Timing.Repeat)
+}
+
+func TestRoundTrip(t *testing.T) {
+	patient, maxDoses := 7, 4
+	drug := "ibuprofen"
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	m := models.Medicine{
+		ID:             3,
+		Name:           "Ibuprofen",
+		Dosage:         "200mg",
+		Frequency:      "Three times daily",
+		TimeOfDay:      models.TimesOfDay{7 * 60, 13 * 60, 19*60 + 45},
+		StartDate:      time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:        &end,
+		Notes:          "After meals",
+		PatientID:      &patient,
+		MaxDosesPer24h: &maxDoses,
+		DrugID:         &drug,
+		Status:         models.StatusActive,
+	}
+
+	// Through JSON, as it would be exchanged
+	body, err := json.Marshal(FromMedicine(m))
+	assert.NoError(t, err)
+	var s MedicationStatement
+	assert.NoError(t, json.Unmarshal(body, &s))
+
+	input, err := ToInput(s)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MedicineInput{
+		Name:           "Ibuprofen",
+		Dosage:         "200mg",
+		Frequency:      "Three times daily",
+		TimeOfDay:      []string{"07:00", "13:00", "19:45"},
+		StartDate:      m.StartDate,
+		EndDate:        end,
+		Notes:          "After meals",
+		PatientID:      &patient,
+		MaxDosesPer24h: &maxDoses,
+		DrugID:         &drug,
+	}, input)
+}
+
+func TestToInputFromExternalSystem(t *testing.T) {
+	body := `{
+		"resourceType": "MedicationStatement",
+		"status": "active",
+		"medicationCodeableConcept": {
+			"coding": [{"system": "http://www.nlm.nih.gov/research/umls/rxnorm", "code": "197361", "display": "Amlodipine 5 MG"}]
+		},
+		"subject": {"reference": "Patient/2"},
+		"effectiveDateTime": "2024-04-10T08:00:00+02:00",
+		"dosage": [{
+			"timing": {"repeat": {"frequency": 1, "period": 1, "periodUnit": "d", "timeOfDay": ["09:00:00"]}},
+			"doseAndRate": [{"doseQuantity": {"value": 5, "unit": "mg"}}]
+		}]
+	}`
+	var s MedicationStatement
+	assert.NoError(t, json.Unmarshal([]byte(body), &s))
+
+	input, err := ToInput(s)
+	assert.NoError(t, err)
+	assert.Equal(t, "Amlodipine 5 MG", input.Name)
+	assert.Nil(t, input.DrugID)
+	assert.Equal(t, 2, *input.PatientID)
+	assert.True(t, input.StartDate.Equal(time.Date(2024, 4, 10, 6, 0, 0, 0, time.UTC)))
+	assert.True(t, input.EndDate.IsZero())
+	assert.Equal(t, "5mg", input.Dosage)
+	assert.Equal(t, "Once daily", input.Frequency)
+	assert.Equal(t, []string{"09:00"}, input.TimeOfDay)
+}
+
+func TestToInputInvalid(t *testing.T) {
+	tests := map[string]MedicationStatement{
+		"wrong resource": {ResourceType: "Patient"},
+		"no medication":  {ResourceType: "MedicationStatement"},
+		"foreign subject": {
+			ResourceType:              "MedicationStatement",
+			MedicationCodeableConcept: &CodeableConcept{Text: "Aspirin"},
+			Subject:                   &Reference{Reference: "Group/1"},
+		},
+		"bad date": {
+			ResourceType:              "MedicationStatement",
+			MedicationCodeableConcept: &CodeableConcept{Text: "Aspirin"},
+			EffectivePeriod:           &Period{Start: "10/04/2024"},
+		},
+	}
+	for name, s := range tests {
+		_, err := ToInput(s)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestLifecycleStatus(t *testing.T) {
+	for _, status := range models.MedicineStatuses {
+		lifecycle, ok := LifecycleStatus(Status(status))
+		assert.True(t, ok)
+		assert.Equal(t, status, lifecycle)
+	}
+	_, ok := LifecycleStatus("entered-in-error")
+	assert.False(t, ok)
+}
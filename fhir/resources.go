@@ -0,0 +1,166 @@
+// Package fhir maps medicines to and from HL7 FHIR R4 resources. Only the
+// parts of each resource this service uses are modelled.
+package fhir
+
+import (
+	"encoding/json"
+)
+
+// ContentType is the media type of FHIR JSON
+const ContentType = "application/fhir+json"
+
+// MedicationStatement records a medicine a patient is taking
+// (https://hl7.org/fhir/R4/medicationstatement.html)
+type MedicationStatement struct {
+	ResourceType              string           `json:"resourceType"`
+	ID                        string           `json:"id,omitempty"`
+	Meta                      *Meta            `json:"meta,omitempty"`
+	Status                    string           `json:"status"`
+	MedicationCodeableConcept *CodeableConcept `json:"medicationCodeableConcept,omitempty"`
+	Subject                   *Reference       `json:"subject,omitempty"`
+	EffectiveDateTime         string           `json:"effectiveDateTime,omitempty"`
+	EffectivePeriod           *Period          `json:"effectivePeriod,omitempty"`
+	DateAsserted              string           `json:"dateAsserted,omitempty"`
+	Note                      []Annotation     `json:"note,omitempty"`
+	Dosage                    []Dosage         `json:"dosage,omitempty"`
+}
+
+// Meta holds a resource's version and last update
+type Meta struct {
+	VersionID   string `json:"versionId,omitempty"`
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+// CodeableConcept is a concept given by codes and/or text
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+// Coding is a code from a code system
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// Reference points to another resource, e.g. "Patient/4"
+type Reference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+// Period is a time range; a missing end means it is ongoing
+type Period struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// Annotation is a text note
+type Annotation struct {
+	Text string `json:"text"`
+}
+
+// Dosage is how a medicine is taken
+type Dosage struct {
+	Sequence         int           `json:"sequence,omitempty"`
+	Text             string        `json:"text,omitempty"`
+	Timing           *Timing       `json:"timing,omitempty"`
+	AsNeededBoolean  bool          `json:"asNeededBoolean,omitempty"`
+	DoseAndRate      []DoseAndRate `json:"doseAndRate,omitempty"`
+	MaxDosePerPeriod *Ratio        `json:"maxDosePerPeriod,omitempty"`
+}
+
+// Timing is when doses are taken
+type Timing struct {
+	Repeat *TimingRepeat    `json:"repeat,omitempty"`
+	Code   *CodeableConcept `json:"code,omitempty"`
+}
+
+// TimingRepeat describes a repeating schedule, e.g. twice per day at 08:00
+// and 20:00
+type TimingRepeat struct {
+	BoundsPeriod *Period  `json:"boundsPeriod,omitempty"`
+	Frequency    int      `json:"frequency,omitempty"`
+	Period       float64  `json:"period,omitempty"`
+	PeriodUnit   string   `json:"periodUnit,omitempty"` // s, min, h, d, wk, mo or a
+	TimeOfDay    []string `json:"timeOfDay,omitempty"`  // Times as HH:MM:SS
+}
+
+// DoseAndRate is the amount taken per dose
+type DoseAndRate struct {
+	DoseQuantity *Quantity `json:"doseQuantity,omitempty"`
+}
+
+// Quantity is an amount with a unit
+type Quantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+	System string  `json:"system,omitempty"`
+	Code   string  `json:"code,omitempty"`
+}
+
+// Ratio is a quantity per another, e.g. 4 doses per 24 hours
+type Ratio struct {
+	Numerator   *Quantity `json:"numerator,omitempty"`
+	Denominator *Quantity `json:"denominator,omitempty"`
+}
+
+// Bundle is a collection of resources, such as search results or the
+// resources of a transaction (https://hl7.org/fhir/R4/bundle.html)
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	Type         string        `json:"type"`
+	Total        *int          `json:"total,omitempty"`
+	Link         []BundleLink  `json:"link,omitempty"`
+	Entry        []BundleEntry `json:"entry,omitempty"`
+}
+
+// BundleLink is a link about the bundle, e.g. to the search that made it
+type BundleLink struct {
+	Relation string `json:"relation"`
+	URL      string `json:"url"`
+}
+
+// BundleEntry is a resource in a bundle. Resources are kept raw since a
+// bundle can hold any type of resource.
+type BundleEntry struct {
+	FullURL  string          `json:"fullUrl,omitempty"`
+	Resource json.RawMessage `json:"resource,omitempty"`
+	Search   *BundleSearch   `json:"search,omitempty"`
+	Response *BundleResponse `json:"response,omitempty"`
+}
+
+// BundleSearch says why an entry is in a search result
+type BundleSearch struct {
+	Mode string `json:"mode"`
+}
+
+// BundleResponse is the outcome of a transaction entry
+type BundleResponse struct {
+	Status   string            `json:"status"`
+	Location string            `json:"location,omitempty"`
+	Outcome  *OperationOutcome `json:"outcome,omitempty"`
+}
+
+// OperationOutcome reports errors (https://hl7.org/fhir/R4/operationoutcome.html)
+type OperationOutcome struct {
+	ResourceType string  `json:"resourceType"`
+	Issue        []Issue `json:"issue"`
+}
+
+// Issue is one error of an OperationOutcome
+type Issue struct {
+	Severity    string   `json:"severity"` // fatal, error, warning or information
+	Code        string   `json:"code"`     // e.g. invalid, not-found, processing
+	Diagnostics string   `json:"diagnostics,omitempty"`
+	Expression  []string `json:"expression,omitempty"`
+}
+
+// NewOperationOutcome returns an outcome with a single error
+func NewOperationOutcome(code, diagnostics string) OperationOutcome {
+	return OperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue:        []Issue{{Severity: "error", Code: code, Diagnostics: diagnostics}},
+	}
+}
@@ -0,0 +1,127 @@
+// Package guardrails enforces per-medicine dose limits such as a maximum number
+// of doses in 24 hours and a minimum interval between doses
+package guardrails
+
+import (
+	"fmt"
+	"medicine-reminder/models"
+	"sort"
+	"time"
+)
+
+// Window is the rolling period the maximum dose count applies to
+const Window = 24 * time.Hour
+
+// Violation describes why a dose would break a medicine's limits
+type Violation struct {
+	Reason        string
+	NextAllowedAt time.Time
+}
+
+// Error implements the error interface
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+// HasLimits reports whether the medicine restricts when doses may be taken
+func HasLimits(m models.Medicine) bool {
+	return m.MaxDosesPer24h != nil || m.MinDoseIntervalMinutes != nil
+}
+
+// Check returns a Violation if taking a dose at `at` would break the medicine's
+// limits, given the times of the doses already taken. Doses logged after the
+// fact are checked against doses on both sides of them.
+func Check(m models.Medicine, taken []time.Time, at time.Time) *Violation {
+	if m.MinDoseIntervalMinutes != nil {
+		interval := time.Duration(*m.MinDoseIntervalMinutes) * time.Minute
+		for _, t := range taken {
+			gap := at.Sub(t)
+			if gap < 0 {
+				gap = -gap
+			}
+			if gap < interval {
+				return &Violation{
+					Reason:        fmt.Sprintf("doses must be at least %d minutes apart", *m.MinDoseIntervalMinutes),
+					NextAllowedAt: NextAllowed(m, taken, at),
+				}
+			}
+		}
+	}
+
+	if m.MaxDosesPer24h != nil {
+		// Every 24 hour window containing the new dose must stay within the limit
+		withDose := append(sorted(taken), at)
+		sort.Slice(withDose, func(i, j int) bool { return withDose[i].Before(withDose[j]) })
+		for _, start := range withDose {
+			if start.After(at) || at.Sub(start) >= Window {
+				continue
+			}
+			if countIn(withDose, start, start.Add(Window)) > *m.MaxDosesPer24h {
+				return &Violation{
+					Reason:        fmt.Sprintf("no more than %d doses are allowed in 24 hours", *m.MaxDosesPer24h),
+					NextAllowedAt: NextAllowed(m, taken, at),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// NextAllowed returns the earliest time at or after now when a dose stays within
+// the medicine's limits, considering the doses taken up to now
+func NextAllowed(m models.Medicine, taken []time.Time, now time.Time) time.Time {
+	var past []time.Time
+	for _, t := range sorted(taken) {
+		if !t.After(now) {
+			past = append(past, t)
+		}
+	}
+
+	next := now
+	if m.MinDoseIntervalMinutes != nil && len(past) > 0 {
+		interval := time.Duration(*m.MinDoseIntervalMinutes) * time.Minute
+		if earliest := past[len(past)-1].Add(interval); earliest.After(next) {
+			next = earliest
+		}
+	}
+
+	if m.MaxDosesPer24h != nil && len(past) >= *m.MaxDosesPer24h {
+		// The window must have dropped enough doses for one more to fit
+		max := *m.MaxDosesPer24h
+		if earliest := past[len(past)-max].Add(Window); earliest.After(next) {
+			next = earliest
+		}
+	}
+
+	return next
+}
+
+// CountSince returns how many of the taken doses fall within (since, now]
+func CountSince(taken []time.Time, since, now time.Time) int {
+	count := 0
+	for _, t := range taken {
+		if t.After(since) && !t.After(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// countIn returns how many times fall within [from, to)
+func countIn(times []time.Time, from, to time.Time) int {
+	count := 0
+	for _, t := range times {
+		if !t.Before(from) && t.Before(to) {
+			count++
+		}
+	}
+	return count
+}
+
+// sorted returns a chronologically sorted copy of times
+func sorted(times []time.Time) []time.Time {
+	out := append([]time.Time(nil), times...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
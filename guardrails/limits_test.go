@@ -0,0 +1,69 @@
+package guardrails
+
+import (
+	"medicine-reminder/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var base = time.Date(2024, 3, 20, 8, 0, 0, 0, time.UTC)
+
+func hoursAfterBase(hours ...float64) []time.Time {
+	times := make([]time.Time, len(hours))
+	for i, h := range hours {
+		times[i] = base.Add(time.Duration(h * float64(time.Hour)))
+	}
+	return times
+}
+
+// paracetamol allows four doses in 24 hours, at least four hours apart
+func paracetamol() models.Medicine {
+	max, interval := 4, 240
+	return models.Medicine{AsNeeded: true, MaxDosesPer24h: &max, MinDoseIntervalMinutes: &interval}
+}
+
+func TestCheckMinInterval(t *testing.T) {
+	m := paracetamol()
+	taken := hoursAfterBase(0)
+
+	v := Check(m, taken, base.Add(3*time.Hour))
+	assert.NotNil(t, v)
+	assert.Equal(t, base.Add(4*time.Hour), v.NextAllowedAt)
+
+	assert.Nil(t, Check(m, taken, base.Add(4*time.Hour)))
+
+	// A dose logged after the fact is checked against later doses too
+	assert.NotNil(t, Check(m, hoursAfterBase(6), base.Add(3*time.Hour)))
+}
+
+func TestCheckMaxPer24h(t *testing.T) {
+	m := paracetamol()
+	taken := hoursAfterBase(0, 4, 8, 12)
+
+	v := Check(m, taken, base.Add(16*time.Hour))
+	assert.NotNil(t, v)
+	assert.Equal(t, base.Add(24*time.Hour), v.NextAllowedAt)
+
+	// Once the first dose leaves the window another is allowed
+	assert.Nil(t, Check(m, taken, base.Add(24*time.Hour)))
+}
+
+func TestNextAllowed(t *testing.T) {
+	m := paracetamol()
+	now := base.Add(time.Hour)
+
+	assert.Equal(t, now, NextAllowed(m, nil, now))
+	assert.Equal(t, base.Add(4*time.Hour), NextAllowed(m, hoursAfterBase(0), now))
+	assert.Equal(t, base.Add(24*time.Hour), NextAllowed(m, hoursAfterBase(0, 4, 8, 12), base.Add(13*time.Hour)))
+
+	// Medicines without limits can always be taken
+	assert.Equal(t, now, NextAllowed(models.Medicine{}, hoursAfterBase(0), now))
+	assert.False(t, HasLimits(models.Medicine{}))
+}
+
+func TestCountSince(t *testing.T) {
+	taken := hoursAfterBase(-30, -20, -1, 0)
+	assert.Equal(t, 3, CountSince(taken, base.Add(-Window), base))
+}
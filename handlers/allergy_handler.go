@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/catalog"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"net/http"
+	"strings"
+)
+
+// GetAllergies handles GET /api/patients/{pid}/allergies
+// Returns the patient's recorded allergies
+func GetAllergies(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	allergies, err := loadAllergies(pid)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, allergies)
+}
+
+// UpdateAllergies handles PUT /api/patients/{pid}/allergies
+// Replaces the patient's recorded allergies
+func UpdateAllergies(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var input []models.Allergy
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validateAllergies(input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM patient_allergies WHERE patient_id = $1", pid); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating allergies")
+		return
+	}
+	for _, a := range input {
+		_, err := tx.Exec(
+			"INSERT INTO patient_allergies (patient_id, allergen, severity, reaction) VALUES ($1, $2, $3, $4)",
+			pid, a.Allergen, a.Severity, a.Reaction)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating allergies")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating allergies")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, input)
+}
+
+// loadAllergies returns a patient's recorded allergies
+func loadAllergies(patientID int) ([]models.Allergy, error) {
+	rows, err := database.DB.Query(
+		"SELECT allergen, severity, reaction FROM patient_allergies WHERE patient_id = $1 ORDER BY allergen",
+		patientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	allergies := []models.Allergy{}
+	for rows.Next() {
+		var a models.Allergy
+		if err := rows.Scan(&a.Allergen, &a.Severity, &a.Reaction); err != nil {
+			return nil, err
+		}
+		allergies = append(allergies, a)
+	}
+	return allergies, rows.Err()
+}
+
+// checkAllergies returns the patient's allergies that a medicine being saved
+// matches, looking up its ingredients and classes in the drug catalog
+func checkAllergies(input models.MedicineInput) ([]models.AllergyWarning, error) {
+	if input.PatientID == nil {
+		return nil, nil
+	}
+
+	allergies, err := loadAllergies(*input.PatientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A linked catalog entry is more reliable than matching the name
+	drugs := catalog.Current().Lookup(input.Name)
+	if input.DrugID != nil {
+		if d, ok := catalog.Current().Get(*input.DrugID); ok {
+			drugs = []catalog.Drug{d}
+		}
+	}
+
+	return allergyWarnings(drugs, input.Name, allergies), nil
+}
+
+// allergyWarnings matches allergies against the catalog drugs found for a
+// medicine, or against the medicine's name when it isn't in the catalog
+func allergyWarnings(drugs []catalog.Drug, name string, allergies []models.Allergy) []models.AllergyWarning {
+	var warnings []models.AllergyWarning
+	for _, a := range allergies {
+		warning := models.AllergyWarning{Allergen: a.Allergen, Severity: a.Severity, Reaction: a.Reaction}
+
+		if len(drugs) == 0 && catalog.Mentions(name, a.Allergen) {
+			warning.Drug = name
+			warning.Matched = "name " + name
+			warnings = append(warnings, warning)
+			continue
+		}
+		for _, d := range drugs {
+			if matched, ok := d.Contains(a.Allergen); ok {
+				warning.Drug = d.Name
+				warning.Matched = matched
+				warnings = append(warnings, warning)
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+// checkMedicineAllergies returns the allergies a medicine being saved matches.
+// It writes an error response and returns false when the medicine matches a
+// severe allergy that hasn't been acknowledged.
+func checkMedicineAllergies(w http.ResponseWriter, input models.MedicineInput) ([]models.AllergyWarning, bool) {
+	warnings, err := checkAllergies(input)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking allergies")
+		return nil, false
+	}
+	if hasSevereAllergy(warnings) && !input.AcknowledgeAllergies {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     "medicine matches a severe allergy; set acknowledge_allergies to save it anyway",
+			"allergies": warnings,
+		})
+		return nil, false
+	}
+	return warnings, true
+}
+
+// hasSevereAllergy reports whether any of the warnings is for a severe allergy
+func hasSevereAllergy(warnings []models.AllergyWarning) bool {
+	for _, w := range warnings {
+		if w.Severity == models.AllergySevere {
+			return true
+		}
+	}
+	return false
+}
+
+func validateAllergies(allergies []models.Allergy) error {
+	for i := range allergies {
+		a := &allergies[i]
+		a.Allergen = strings.TrimSpace(a.Allergen)
+		if a.Allergen == "" {
+			return fmt.Errorf("allergen is required")
+		}
+		if a.Severity == "" {
+			a.Severity = models.AllergySevere
+		}
+		switch a.Severity {
+		case models.AllergyMild, models.AllergyModerate, models.AllergySevere:
+		default:
+			return fmt.Errorf("severity must be %q, %q or %q",
+				models.AllergyMild, models.AllergyModerate, models.AllergySevere)
+		}
+	}
+	return nil
+}
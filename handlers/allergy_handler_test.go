@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/catalog"
+	"medicine-reminder/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateMedicineAllergies(t *testing.T) {
+	setupTestDB(t)
+
+	drugs, err := catalog.Load(filepath.Join("..", "data", "drugs.json"))
+	assert.NoError(t, err)
+	catalog.SetCurrent(drugs)
+
+	patient := createTestPatient(t)
+	rr := putAllergies(t, patient.ID, []models.Allergy{
+		{Allergen: "Penicillin", Reaction: "anaphylaxis"},
+		{Allergen: "NSAIDs", Severity: models.AllergyMild},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	input := models.MedicineInput{
+		Name:      "Amoxil",
+		Dosage:    "500mg",
+		Frequency: "Three times daily",
+		TimeOfDay: []string{"08:00", "14:00", "20:00"},
+		StartDate: time.Now(),
+		EndDate:   time.Now().AddDate(0, 0, 7),
+		PatientID: &patient.ID,
+	}
+
+	// Severe allergies block the medicine until acknowledged
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	input.AcknowledgeAllergies = true
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Edits that don't change what's matched don't need acknowledging again
+	var acknowledged medicineResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &acknowledged)
+	assert.NoError(t, err)
+	rr = patchMedicine(t, acknowledged.ID, "application/merge-patch+json", `{"notes": "Finish the course"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = patchMedicine(t, acknowledged.ID, "application/merge-patch+json", `{"name": "Amoxicillin"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Milder allergies only warn
+	input.Name = "Ibuprofen"
+	input.AcknowledgeAllergies = false
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var created medicineResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &created)
+	assert.NoError(t, err)
+	assert.Len(t, created.Allergies, 1)
+	assert.Equal(t, "class nsaid", created.Allergies[0].Matched)
+}
+
+func TestUpdateAllergiesInvalidSeverity(t *testing.T) {
+	setupTestDB(t)
+
+	patient := createTestPatient(t)
+	rr := putAllergies(t, patient.ID, []models.Allergy{{Allergen: "sulfa", Severity: "fatal"}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+// Helper function to replace a patient's allergies through the handler
+func putAllergies(t *testing.T, patientID int, allergies []models.Allergy) *httptest.ResponseRecorder {
+	body, err := json.Marshal(allergies)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("/api/patients/%d/allergies", patientID), bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"pid": fmt.Sprintf("%d", patientID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(UpdateAllergies).ServeHTTP(rr, req)
+	return rr
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/audit"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Page sizes for GET /api/audit
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+)
+
+// auditColumns lists the medicine_audit columns in the order expected by scanAuditEntry
+const auditColumns = "id, medicine_id, action, actor, request_id, changed_at, changes"
+
+// RequestIDHeader carries the ID that ties audit entries to the request that made them
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestID gives every request an X-Request-ID, keeping the client's if it
+// sent one, and echoes it in the response
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set(RequestIDHeader, requestID(r))
+		w.Header().Set(RequestIDHeader, r.Header.Get(RequestIDHeader))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestID returns the request's X-Request-ID, or a new random ID if it has none
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// actor returns who is making a request, as given in the X-Actor header
+func actor(r *http.Request) string {
+	if a := strings.TrimSpace(r.Header.Get("X-Actor")); a != "" {
+		return a
+	}
+	return "anonymous"
+}
+
+// recordMedicineAudit appends a change to the medicine audit log in the same
+// transaction as the change. old is nil when the medicine was created.
+func recordMedicineAudit(tx *sql.Tx, r *http.Request, action string, old *models.Medicine, medicine models.Medicine) error {
+	return audit.Record(tx, action, actor(r), requestID(r), old, medicine)
+}
+
+// scanAuditEntry reads an audit entry selected with auditColumns
+func scanAuditEntry(s database.Scanner) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	var changes []byte
+	if err := s.Scan(&e.ID, &e.MedicineID, &e.Action, &e.Actor, &e.RequestID, &e.ChangedAt, &changes); err != nil {
+		return e, err
+	}
+	err := json.Unmarshal(changes, &e.Changes)
+	return e, err
+}
+
+// GetMedicineHistory handles GET /api/medicines/{id}/history
+// Returns every recorded change to a medicine, most recent first. The history
+// stays available after the medicine is deleted or purged.
+func GetMedicineHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Medicine not found")
+		return
+	}
+
+	entries, err := loadAuditEntries("SELECT "+auditColumns+
+		" FROM medicine_audit WHERE medicine_id = $1 ORDER BY id DESC", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(entries) == 0 {
+		var exists bool
+		if err := database.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM medicines WHERE id = $1)", id).Scan(&exists); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if !exists {
+			respondWithError(w, http.StatusNotFound, "Medicine not found")
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+// GetAuditLog handles GET /api/audit
+// Returns changes to all medicines, most recent first. Entries can be filtered
+// by ?medicine_id=, ?actor=, ?action=, ?request_id= and a ?since= / ?until=
+// time range. Pages hold ?limit= entries, 100 by default; pass the ID of the
+// last entry as ?before= for the next page.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	for _, param := range []string{"medicine_id", "before"} {
+		if s := values.Get(param); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, param+" must be a number")
+				return
+			}
+			column, op := param, " = "
+			if param == "before" {
+				column, op = "id", " < "
+			}
+			conditions = append(conditions, column+op+arg(n))
+		}
+	}
+	for _, param := range []string{"actor", "action", "request_id"} {
+		if s := values.Get(param); s != "" {
+			conditions = append(conditions, param+" = "+arg(s))
+		}
+	}
+	for _, f := range []struct{ param, condition string }{{"since", "changed_at >= "}, {"until", "changed_at <= "}} {
+		if s := values.Get(f.param); s != "" {
+			t, err := parseDateParam(f.param, s)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			conditions = append(conditions, f.condition+arg(t))
+		}
+	}
+
+	limit := defaultAuditPageSize
+	if s := values.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxAuditPageSize {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAuditPageSize))
+			return
+		}
+		limit = n
+	}
+
+	query := "SELECT " + auditColumns + " FROM medicine_audit"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT " + strconv.Itoa(limit)
+
+	entries, err := loadAuditEntries(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+// loadAuditEntries runs an audit entry query
+func loadAuditEntries(query string, args ...interface{}) ([]models.AuditEntry, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// getMedicinesAsOf writes the medicines as they were recorded at ?as_of=, a
+// time or a date meaning the end of that day, rebuilt from the audit log.
+// Medicines edited or deleted since appear as they were then, with their
+// status at that time. Only ?patient_id= can narrow the list.
+func getMedicinesAsOf(w http.ResponseWriter, values url.Values) {
+	for param := range values {
+		if param != "as_of" && param != "patient_id" {
+			respondWithError(w, http.StatusBadRequest, "as_of can only be combined with patient_id")
+			return
+		}
+	}
+
+	s := values.Get("as_of")
+	asOf, err := parseDateParam("as_of", s)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(s) == len("2006-01-02") {
+		asOf = asOf.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+
+	var patientID *int
+	if s := values.Get("patient_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "patient_id must be a number")
+			return
+		}
+		patientID = &id
+	}
+
+	// The latest snapshot of each medicine at the time, unless it was deleted
+	rows, err := database.DB.Query(`
+		SELECT snapshot FROM (
+			SELECT DISTINCT ON (medicine_id) medicine_id, snapshot
+			FROM medicine_audit
+			WHERE changed_at <= $1
+			ORDER BY medicine_id, id DESC
+		) latest
+		WHERE snapshot->'deleted_at' = 'null'::jsonb
+			AND ($2::integer IS NULL OR (snapshot->>'patient_id')::integer = $2)
+		ORDER BY medicine_id DESC`,
+		asOf, patientID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	page := medicinePage{Data: []models.Medicine{}}
+	for rows.Next() {
+		var snapshot []byte
+		var m models.Medicine
+		if err := rows.Scan(&snapshot); err != nil || json.Unmarshal(snapshot, &m) != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		m.Status = m.StatusAt(asOf)
+		page.Data = append(page.Data, m)
+	}
+	if err := rows.Err(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	page.Total = len(page.Data)
+	respondWithJSON(w, http.StatusOK, page)
+}
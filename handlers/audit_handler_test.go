@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedicineHistory(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createAuditedMedicine(t, "Amoxicillin", "500mg")
+
+	rr := patchMedicine(t, medicine.ID, "application/merge-patch+json", `{"dosage": "250mg"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("/api/medicines/%d", medicine.ID), nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-Actor", "dr.jones")
+	req.Header.Set(RequestIDHeader, "req-123")
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicine.ID)})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(DeleteMedicine).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// History is kept for deleted medicines, most recent first
+	history := getAuditEntries(t, GetMedicineHistory, fmt.Sprintf("/api/medicines/%d/history", medicine.ID),
+		map[string]string{"id": fmt.Sprintf("%d", medicine.ID)})
+	assert.Len(t, history, 3)
+	assert.Equal(t, []string{models.AuditDelete, models.AuditUpdate, models.AuditCreate},
+		[]string{history[0].Action, history[1].Action, history[2].Action})
+
+	assert.Equal(t, "dr.jones", history[0].Actor)
+	assert.Equal(t, "req-123", history[0].RequestID)
+	assert.Contains(t, history[0].Changes, "deleted_at")
+
+	assert.Equal(t, "anonymous", history[1].Actor)
+	assert.NotEmpty(t, history[1].RequestID)
+	assert.Equal(t, models.FieldChange{Old: json.RawMessage(`"500mg"`), New: json.RawMessage(`"250mg"`)},
+		history[1].Changes["dosage"])
+	assert.Equal(t, models.FieldChange{Old: json.RawMessage("500"), New: json.RawMessage("250")},
+		history[1].Changes["dosage_amount"])
+	assert.NotContains(t, history[1].Changes, "name")
+
+	assert.Equal(t, json.RawMessage("null"), history[2].Changes["name"].Old)
+
+	// The admin-wide log can be filtered
+	entries := getAuditEntries(t, GetAuditLog, "/api/audit?actor=dr.jones&action=delete", nil)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, medicine.ID, entries[0].MedicineID)
+
+	// The log is append-only
+	_, err = database.DB.Exec("UPDATE medicine_audit SET actor = 'someone else' WHERE medicine_id = $1", medicine.ID)
+	assert.Error(t, err)
+	_, err = database.DB.Exec("DELETE FROM medicine_audit WHERE medicine_id = $1", medicine.ID)
+	assert.Error(t, err)
+}
+
+func TestStockChangesAudited(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createAuditedMedicine(t, "Amoxicillin", "500mg")
+
+	rr := postAdjustment(t, medicine.ID, models.InventoryAdjustmentInput{Change: 12, Reason: "refill"})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	history := getAuditEntries(t, GetMedicineHistory, fmt.Sprintf("/api/medicines/%d/history", medicine.ID),
+		map[string]string{"id": fmt.Sprintf("%d", medicine.ID)})
+	assert.Len(t, history, 2)
+	assert.Equal(t, models.AuditUpdate, history[0].Action)
+	assert.Equal(t, models.FieldChange{Old: json.RawMessage("null"), New: json.RawMessage("12")},
+		history[0].Changes["stock_quantity"])
+}
+
+func TestMedicineHistoryNotFound(t *testing.T) {
+	setupTestDB(t)
+
+	req, err := http.NewRequest("GET", "/api/medicines/999999/history", nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "999999"})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetMedicineHistory).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetMedicinesAsOf(t *testing.T) {
+	setupTestDB(t)
+
+	beforeAll := time.Now()
+	edited := createAuditedMedicine(t, "Amoxicillin", "500mg")
+	deleted := createAuditedMedicine(t, "Ibuprofen", "200mg")
+	recorded := time.Now()
+
+	rr := patchMedicine(t, edited.ID, "application/merge-patch+json", `{"dosage": "250mg"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("/api/medicines/%d", deleted.ID), nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", deleted.ID)})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(DeleteMedicine).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// The list as recorded before the edit and delete
+	page := getMedicinePage(t, "/api/medicines?as_of="+url.QueryEscape(recorded.Format(time.RFC3339Nano)))
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, deleted.ID, page.Data[0].ID)
+	assert.Equal(t, edited.ID, page.Data[1].ID)
+	assert.Equal(t, "500mg", page.Data[1].Dosage)
+
+	// and now
+	page = getMedicinePage(t, "/api/medicines?as_of="+url.QueryEscape(time.Now().Format(time.RFC3339Nano)))
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, "250mg", page.Data[0].Dosage)
+
+	page = getMedicinePage(t, "/api/medicines?as_of="+url.QueryEscape(beforeAll.Format(time.RFC3339Nano)))
+	assert.Empty(t, page.Data)
+
+	for _, query := range []string{"as_of=yesterday", "as_of=2024-03-03&status=active"} {
+		req, err := http.NewRequest("GET", "/api/medicines?"+query, nil)
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetMedicines).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	var seen string
+	handler := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get(RequestIDHeader)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/medicines", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Len(t, seen, 32)
+	assert.Equal(t, seen, rr.Header().Get(RequestIDHeader))
+
+	// The client's request ID is kept
+	req = httptest.NewRequest("GET", "/api/medicines", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, "abc", seen)
+	assert.Equal(t, "abc", rr.Header().Get(RequestIDHeader))
+}
+
+func getAuditEntries(t *testing.T, handler http.HandlerFunc, url string, vars map[string]string) []models.AuditEntry {
+	req, err := http.NewRequest("GET", url, nil)
+	assert.NoError(t, err)
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var entries []models.AuditEntry
+	err = json.Unmarshal(rr.Body.Bytes(), &entries)
+	assert.NoError(t, err)
+	return entries
+}
+
+// createAuditedMedicine creates a week-long course through CreateMedicine, so it is audited
+func createAuditedMedicine(t *testing.T, name, dosage string) models.Medicine {
+	rr := postMedicine(t, models.MedicineInput{
+		Name:      name,
+		Dosage:    dosage,
+		Frequency: "Three times daily",
+		TimeOfDay: []string{"08:00", "14:00", "20:00"},
+		StartDate: time.Now(),
+		EndDate:   time.Now().AddDate(0, 0, 7),
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var medicine models.Medicine
+	err := json.Unmarshal(rr.Body.Bytes(), &medicine)
+	assert.NoError(t, err)
+	return medicine
+}
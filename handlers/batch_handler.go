@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"net/http"
+	"strconv"
+)
+
+// maxBatchOperations is the most operations a batch may hold
+const maxBatchOperations = 100
+
+// Batch operations
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+)
+
+// batchRequest is the body of POST /api/medicines/batch
+type batchRequest struct {
+	// Atomic saves either every operation or, if any fails, none of them.
+	// Otherwise each operation is saved on its own.
+	Atomic     bool             `json:"atomic"`
+	Operations []batchOperation `json:"operations"`
+}
+
+// batchOperation creates, updates or deletes one medicine
+type batchOperation struct {
+	Op       string                `json:"op"`       // create, update or delete
+	ID       int                   `json:"id"`       // Medicine to update or delete
+	Medicine *models.MedicineInput `json:"medicine"` // Medicine to create, or the new values of an update
+	IfMatch  string                `json:"if_match"` // ETag the medicine must still have, like an If-Match header
+}
+
+// batchResult is the outcome of one operation, with the status code and body
+// the operation would have had as a request of its own
+type batchResult struct {
+	Index        int                         `json:"index"`
+	Op           string                      `json:"op"`
+	Status       int                         `json:"status"`
+	Medicine     *models.Medicine            `json:"medicine,omitempty"`
+	Interactions []models.InteractionWarning `json:"interactions,omitempty"`
+	Allergies    []models.AllergyWarning     `json:"allergies,omitempty"`
+	Error        string                      `json:"error,omitempty"`
+}
+
+// batchResponse is the response of POST /api/medicines/batch
+type batchResponse struct {
+	Atomic    bool          `json:"atomic"`
+	Committed bool          `json:"committed"` // Whether any changes were saved
+	Results   []batchResult `json:"results"`
+}
+
+// BatchMedicines handles POST /api/medicines/batch
+// Creates, updates and deletes several medicines in one request, returning
+// the result of each operation. With "atomic": true the operations share one
+// transaction and are only saved if they all succeed; otherwise each is saved
+// on its own and failures don't affect the others.
+func BatchMedicines(w http.ResponseWriter, r *http.Request) {
+	var batch batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := validateBatch(batch); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := batchResponse{Atomic: batch.Atomic, Results: make([]batchResult, len(batch.Operations))}
+	if batch.Atomic {
+		tx, err := database.DB.Begin()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		defer tx.Rollback()
+
+		failed := -1
+		for i, op := range batch.Operations {
+			response.Results[i] = runBatchOperation(tx, r, i, op)
+			if !successful(response.Results[i].Status) {
+				failed = i
+				break
+			}
+		}
+
+		if failed < 0 {
+			if err := tx.Commit(); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error saving batch")
+				return
+			}
+			response.Committed = true
+			respondWithJSON(w, http.StatusOK, response)
+			return
+		}
+
+		// Nothing was saved, so every other operation failed along with it
+		for i, op := range batch.Operations {
+			if i != failed {
+				response.Results[i] = batchResult{
+					Index:  i,
+					Op:     op.Op,
+					Status: http.StatusFailedDependency,
+					Error:  fmt.Sprintf("not saved because operation %d failed", failed),
+				}
+			}
+		}
+		respondWithJSON(w, http.StatusBadRequest, response)
+		return
+	}
+
+	for i, op := range batch.Operations {
+		tx, err := database.DB.Begin()
+		if err != nil {
+			response.Results[i] = batchResult{Index: i, Op: op.Op, Status: http.StatusInternalServerError, Error: "Database error"}
+			continue
+		}
+		response.Results[i] = runBatchOperation(tx, r, i, op)
+		if !successful(response.Results[i].Status) {
+			tx.Rollback()
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			response.Results[i] = batchResult{Index: i, Op: op.Op, Status: http.StatusInternalServerError, Error: "Error saving medicine"}
+			continue
+		}
+		response.Committed = true
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// validateBatch checks the shape of a batch before any of it is run
+func validateBatch(batch batchRequest) error {
+	if len(batch.Operations) == 0 {
+		return fmt.Errorf("operations are required")
+	}
+	if len(batch.Operations) > maxBatchOperations {
+		return fmt.Errorf("a batch can hold at most %d operations", maxBatchOperations)
+	}
+	for i, op := range batch.Operations {
+		switch op.Op {
+		case batchCreate:
+			if op.Medicine == nil {
+				return fmt.Errorf("operation %d: medicine is required", i)
+			}
+		case batchUpdate:
+			if op.ID == 0 || op.Medicine == nil {
+				return fmt.Errorf("operation %d: id and medicine are required", i)
+			}
+		case batchDelete:
+			if op.ID == 0 {
+				return fmt.Errorf("operation %d: id is required", i)
+			}
+		default:
+			return fmt.Errorf("operation %d: op must be create, update or delete", i)
+		}
+	}
+	return nil
+}
+
+// runBatchOperation runs one operation in tx with the same checks as the
+// single-medicine endpoints
+func runBatchOperation(tx *sql.Tx, r *http.Request, index int, op batchOperation) batchResult {
+	result := batchResult{Index: index, Op: op.Op}
+	rec := &batchRecorder{header: http.Header{}}
+	id := strconv.Itoa(op.ID)
+
+	var response medicineResponse
+	ok := false
+	switch op.Op {
+	case batchCreate:
+		if response, ok = insertMedicine(rec, tx, r, *op.Medicine); ok {
+			result.Status = http.StatusCreated
+		}
+	case batchUpdate:
+		var medicine models.Medicine
+		if medicine, ok = lockMedicine(rec, tx, id); !ok {
+			break
+		}
+		if ok = checkETag(rec, op.IfMatch, medicine); !ok {
+			break
+		}
+		if response, ok = saveMedicine(rec, tx, medicine, *op.Medicine); !ok {
+			break
+		}
+		if err := recordMedicineAudit(tx, r, models.AuditUpdate, &medicine, response.Medicine); err != nil {
+			respondWithError(rec, http.StatusInternalServerError, "Error updating medicine")
+			ok = false
+			break
+		}
+		result.Status = http.StatusOK
+	case batchDelete:
+		var medicine models.Medicine
+		if medicine, ok = lockMedicine(rec, tx, id); ok {
+			ok = checkETag(rec, op.IfMatch, medicine) && trashMedicine(rec, tx, r, medicine)
+		}
+		if ok {
+			result.Status = http.StatusNoContent
+			return result
+		}
+	}
+
+	if !ok {
+		var failure struct {
+			Error        string                      `json:"error"`
+			Interactions []models.InteractionWarning `json:"interactions"`
+			Allergies    []models.AllergyWarning     `json:"allergies"`
+		}
+		json.Unmarshal(rec.body.Bytes(), &failure)
+		result.Status = rec.status
+		result.Error = failure.Error
+		result.Interactions = failure.Interactions
+		result.Allergies = failure.Allergies
+		return result
+	}
+
+	result.Medicine = &response.Medicine
+	result.Interactions = response.Interactions
+	result.Allergies = response.Allergies
+	return result
+}
+
+// successful reports whether a status code means an operation succeeded
+func successful(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// batchRecorder captures the error response an operation writes so it can
+// be reported as the operation's result
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the response headers
+func (rec *batchRecorder) Header() http.Header {
+	return rec.header
+}
+
+// Write records the response body
+func (rec *batchRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+// WriteHeader records the status code
+func (rec *batchRecorder) WriteHeader(status int) {
+	rec.status = status
+}
+
+// errorMessage returns the message of the recorded error response
+func (rec *batchRecorder) errorMessage() string {
+	var failure struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(rec.body.Bytes(), &failure)
+	return failure.Error
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/interactions"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchMedicines(t *testing.T) {
+	setupTestDB(t)
+
+	existing := createAuditedMedicine(t, "Amoxicillin", "500mg")
+	trashed := createAuditedMedicine(t, "Ibuprofen", "200mg")
+
+	body := fmt.Sprintf(`{"operations": [
+		{"op": "create", "medicine": {"name": "Metformin", "dosage": "500mg", "frequency": "Twice daily",
+			"time_of_day": ["08:00", "20:00"], "start_date": "2024-03-01T00:00:00Z"}},
+		{"op": "create", "medicine": {"name": "", "dosage": "10mg"}},
+		{"op": "update", "id": %d, "medicine": {"name": "Amoxicillin", "dosage": "250mg", "frequency": "Three times daily",
+			"time_of_day": ["08:00", "14:00", "20:00"], "start_date": "2024-03-01T00:00:00Z", "end_date": "2024-03-08T00:00:00Z"}},
+		{"op": "delete", "id": %d},
+		{"op": "delete", "id": 999999}
+	]}`, existing.ID, trashed.ID)
+
+	rr, response := postBatch(t, body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, response.Committed)
+	assert.Len(t, response.Results, 5)
+
+	assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+	assert.Equal(t, "Metformin", response.Results[0].Medicine.Name)
+	assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+	assert.NotEmpty(t, response.Results[1].Error)
+	assert.Equal(t, http.StatusOK, response.Results[2].Status)
+	assert.Equal(t, "250mg", response.Results[2].Medicine.Dosage)
+	assert.Equal(t, http.StatusNoContent, response.Results[3].Status)
+	assert.Equal(t, http.StatusNotFound, response.Results[4].Status)
+
+	assert.Equal(t, 2, getMedicinePage(t, "/api/medicines").Total)
+}
+
+func TestBatchMedicinesAtomic(t *testing.T) {
+	setupTestDB(t)
+
+	existing := createAuditedMedicine(t, "Amoxicillin", "500mg")
+
+	body := fmt.Sprintf(`{"atomic": true, "operations": [
+		{"op": "create", "medicine": {"name": "Metformin", "dosage": "500mg", "frequency": "Twice daily",
+			"time_of_day": ["08:00", "20:00"], "start_date": "2024-03-01T00:00:00Z"}},
+		{"op": "delete", "id": %d},
+		{"op": "delete", "id": 999999}
+	]}`, existing.ID)
+
+	rr, response := postBatch(t, body)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.False(t, response.Committed)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+	assert.Nil(t, response.Results[0].Medicine)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[1].Status)
+	assert.Equal(t, http.StatusNotFound, response.Results[2].Status)
+
+	// Nothing was saved
+	page := getMedicinePage(t, "/api/medicines")
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, existing.ID, page.Data[0].ID)
+}
+
+func TestBatchMedicinesInvalid(t *testing.T) {
+	for _, body := range []string{
+		`not json`,
+		`{"operations": []}`,
+		`{"operations": [{"op": "upsert", "id": 1}]}`,
+		`{"operations": [{"op": "create"}]}`,
+		`{"operations": [{"op": "update", "medicine": {"name": "Metformin"}}]}`,
+		`{"operations": [{"op": "delete"}]}`,
+	} {
+		rr, _ := postBatch(t, body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func postBatch(t *testing.T, body string) (*httptest.ResponseRecorder, batchResponse) {
+	req, err := http.NewRequest("POST", "/api/medicines/batch", bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(BatchMedicines).ServeHTTP(rr, req)
+
+	var response batchResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	return rr, response
+}
+
+func TestBatchMedicinesInteractions(t *testing.T) {
+	setupTestDB(t)
+
+	dataset, err := interactions.Load(filepath.Join("..", "data", "interactions.json"))
+	assert.NoError(t, err)
+	interactions.SetCurrent(dataset)
+
+	// Medicines created earlier in an atomic batch are checked against too
+	patient := createTestPatient(t)
+	body := fmt.Sprintf(`{"atomic": true, "operations": [
+		{"op": "create", "medicine": {"name": "Coumadin", "dosage": "5mg", "frequency": "Once daily",
+			"time_of_day": ["18:00"], "start_date": "2024-03-01T00:00:00Z", "patient_id": %[1]d}},
+		{"op": "create", "medicine": {"name": "Aspirin", "dosage": "81mg", "frequency": "Once daily",
+			"time_of_day": ["08:00"], "start_date": "2024-03-01T00:00:00Z", "patient_id": %[1]d}}
+	]}`, patient.ID)
+
+	rr, response := postBatch(t, body)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.False(t, response.Committed)
+	assert.Equal(t, http.StatusConflict, response.Results[1].Status)
+}
+
+func TestBatchMedicinesIfMatch(t *testing.T) {
+	setupTestDB(t)
+
+	existing := createAuditedMedicine(t, "Amoxicillin", "500mg")
+
+	body := fmt.Sprintf(`{"operations": [
+		{"op": "update", "id": %[1]d, "if_match": %[2]q, "medicine": {"name": "Amoxicillin", "dosage": "250mg",
+			"frequency": "Once daily", "time_of_day": ["08:00"], "start_date": "2024-03-01T00:00:00Z"}},
+		{"op": "delete", "id": %[1]d, "if_match": %[3]q}
+	]}`, existing.ID, `"999"`, medicineETag(existing))
+
+	rr, response := postBatch(t, body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Results[0].Status)
+	assert.Equal(t, http.StatusNoContent, response.Results[1].Status)
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/spreadsheet"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limits of POST /api/medicines/import
+const (
+	maxImportBytes = 5 << 20
+	maxImportRows  = 1000
+)
+
+// Import row statuses
+const (
+	importValid   = "valid"   // The row can be imported; nothing was saved
+	importCreated = "created" // The row was imported
+	importInvalid = "invalid" // The row can't be imported
+)
+
+// importRow is the validation result of one row of an import
+type importRow struct {
+	Row      int              `json:"row"` // Line of the file, counting the header as line 1
+	Status   string           `json:"status"`
+	Medicine *models.Medicine `json:"medicine,omitempty"` // The created medicine
+	Errors   []string         `json:"errors,omitempty"`
+}
+
+// importReport is the response of POST /api/medicines/import
+type importReport struct {
+	DryRun         bool        `json:"dry_run"`
+	Saved          bool        `json:"saved"`
+	Valid          int         `json:"valid"`
+	Invalid        int         `json:"invalid"`
+	IgnoredColumns []string    `json:"ignored_columns"`
+	Rows           []importRow `json:"rows"`
+}
+
+// ExportMedicinesCSV handles GET /api/medicines/export.csv
+// Returns every medicine matching the filters of GET /api/medicines as CSV,
+// in the same order. Pages don't apply, so limit and cursor are ignored.
+func ExportMedicinesCSV(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	values.Del("limit")
+	values.Del("cursor")
+	q, err := parseMedicineListQuery(values, time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := database.DB.Query("SELECT "+database.MedicineColumns+" FROM medicines"+q.where()+q.orderBy(), q.args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="medicines.csv"`)
+	out := csv.NewWriter(w)
+	out.Write(spreadsheet.Columns)
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			// The response has started, so the export can only be cut short
+			log.Printf("Error exporting medicines: %v", err)
+			break
+		}
+		out.Write(spreadsheet.Record(m))
+	}
+	out.Flush()
+}
+
+// ImportMedicines handles POST /api/medicines/import
+// Creates medicines from CSV sent as the body or as the "file" field of a
+// form. The header row names each column's field, and ?map=Column:field
+// maps columns with other names. Numeric dates are read with ?date_order=dmy
+// or mdy. Rows go through the same checks as POST /api/medicines and are
+// only saved if every row is valid; ?dry_run=true checks them without saving.
+// Returns a report of each row.
+func ImportMedicines(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	dryRun := values.Get("dry_run") == "true"
+
+	order := values.Get("date_order")
+	if order != "" && order != spreadsheet.DayFirst && order != spreadsheet.MonthFirst {
+		respondWithError(w, http.StatusBadRequest, "date_order must be dmy or mdy")
+		return
+	}
+
+	mapping := map[string]string{}
+	for _, m := range values["map"] {
+		column, field, ok := strings.Cut(m, ":")
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "map must be given as Column:field")
+			return
+		}
+		mapping[column] = field
+	}
+
+	body, ok := importBody(w, r)
+	if !ok {
+		return
+	}
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid CSV: "+err.Error())
+		return
+	}
+	if len(records) < 2 {
+		respondWithError(w, http.StatusBadRequest, "CSV must have a header row and at least one medicine")
+		return
+	}
+	if len(records)-1 > maxImportRows {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("CSV can have at most %d medicines", maxImportRows))
+		return
+	}
+
+	header, err := spreadsheet.ParseHeader(records[0], mapping)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	report := importReport{DryRun: dryRun, IgnoredColumns: header.Ignored, Rows: []importRow{}}
+	if report.IgnoredColumns == nil {
+		report.IgnoredColumns = []string{}
+	}
+	var created []models.Medicine
+	for i, record := range records[1:] {
+		row := importRow{Row: i + 2, Status: importValid}
+		input, errs := header.Input(record, order)
+		if len(errs) > 0 {
+			row.Status, row.Errors = importInvalid, errs
+		} else if medicine, message, ok := importMedicine(tx, r, input); ok {
+			created = append(created, medicine)
+		} else {
+			row.Status, row.Errors = importInvalid, []string{message}
+		}
+
+		if row.Status == importValid {
+			report.Valid++
+		} else {
+			report.Invalid++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	if dryRun {
+		respondWithJSON(w, http.StatusOK, report)
+		return
+	}
+	if report.Invalid > 0 {
+		respondWithJSON(w, http.StatusBadRequest, report)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error importing medicines")
+		return
+	}
+
+	report.Saved = true
+	for i := range report.Rows {
+		report.Rows[i].Status = importCreated
+		report.Rows[i].Medicine = &created[i]
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// importBody returns the CSV of an import request, writing an error response
+// and returning false when there is none
+func importBody(w http.ResponseWriter, r *http.Request) (io.Reader, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, true
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "The form must have a CSV file named file")
+		return nil, false
+	}
+	return file, true
+}
+
+// importMedicine creates one imported medicine in tx, returning why it can't
+// when it fails. A savepoint keeps a failed row from aborting the others.
+func importMedicine(tx *sql.Tx, r *http.Request, input models.MedicineInput) (models.Medicine, string, bool) {
+	if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+		return models.Medicine{}, "Database error", false
+	}
+
+	rec := &batchRecorder{header: http.Header{}}
+	response, ok := insertMedicine(rec, tx, r, input)
+	if !ok {
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT import_row"); err != nil {
+			return models.Medicine{}, "Database error", false
+		}
+		return models.Medicine{}, rec.errorMessage(), false
+	}
+
+	if _, err := tx.Exec("RELEASE SAVEPOINT import_row"); err != nil {
+		return models.Medicine{}, "Database error", false
+	}
+	return response.Medicine, "", true
+}
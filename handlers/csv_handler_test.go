@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"medicine-reminder/spreadsheet"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportMedicinesCSV(t *testing.T) {
+	setupTestDB(t)
+
+	createAuditedMedicine(t, "Amoxicillin", "500mg")
+	createAuditedMedicine(t, "Ibuprofen", "200mg")
+
+	req, err := http.NewRequest("GET", "/api/medicines/export.csv?sort=name", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ExportMedicinesCSV).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, spreadsheet.Columns, records[0])
+	assert.Equal(t, "Amoxicillin", records[1][2])
+	assert.Equal(t, "08:00;14:00;20:00", records[1][5])
+	assert.Equal(t, "Ibuprofen", records[2][2])
+
+	// Pages don't apply to exports
+	req, err = http.NewRequest("GET", "/api/medicines/export.csv?sort=name&limit=1&cursor=not-a-cursor", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(ExportMedicinesCSV).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	records, err = csv.NewReader(rr.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+}
+
+func TestImportMedicines(t *testing.T) {
+	setupTestDB(t)
+
+	file := "Medication,Dose,Frequency,Times,Start,End,Colour\n" +
+		"Amoxicillin,500mg,Three times daily,08:00;14:00;20:00,01/03/2024,08/03/2024,pink\n" +
+		"Metformin,500mg,Twice daily,\"08:00, 20:00\",1 Mar 2024,,white\n"
+
+	// A dry run saves nothing
+	rr, report := postImport(t, "?dry_run=true&date_order=dmy", file)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, report.DryRun)
+	assert.False(t, report.Saved)
+	assert.Equal(t, 2, report.Valid)
+	assert.Equal(t, []string{"Colour"}, report.IgnoredColumns)
+	assert.Equal(t, 0, getMedicinePage(t, "/api/medicines").Total)
+
+	rr, report = postImport(t, "?date_order=dmy", file)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, report.Saved)
+	assert.Equal(t, importCreated, report.Rows[0].Status)
+	assert.Equal(t, 2, report.Rows[0].Row)
+	assert.Equal(t, "Amoxicillin", report.Rows[0].Medicine.Name)
+	assert.Equal(t, time.March, report.Rows[0].Medicine.EndDate.Month())
+	assert.Equal(t, 8, report.Rows[0].Medicine.EndDate.Day())
+	assert.Nil(t, report.Rows[1].Medicine.EndDate)
+	assert.Equal(t, 2, getMedicinePage(t, "/api/medicines").Total)
+}
+
+func TestImportMedicinesInvalidRows(t *testing.T) {
+	setupTestDB(t)
+
+	file := "Rx,dosage,frequency,time_of_day,start_date\n" +
+		"Amoxicillin,500mg,Three times daily,08:00,2024-03-01\n" +
+		",500mg,Twice daily,08:00,2024-03-01\n" +
+		"Metformin,500mg,Twice daily,08:00,03/04/2024\n"
+
+	rr, report := postImport(t, "?map=Rx:name", file)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.False(t, report.Saved)
+	assert.Equal(t, 1, report.Valid)
+	assert.Equal(t, 2, report.Invalid)
+	assert.Equal(t, importValid, report.Rows[0].Status)
+	assert.Equal(t, []string{"name is required"}, report.Rows[1].Errors)
+	assert.Contains(t, report.Rows[2].Errors[0], "start_date")
+
+	// Nothing is saved unless every row is valid
+	assert.Equal(t, 0, getMedicinePage(t, "/api/medicines").Total)
+}
+
+func TestImportMedicinesForm(t *testing.T) {
+	setupTestDB(t)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "medicines.csv")
+	assert.NoError(t, err)
+	part.Write([]byte("name,dosage,frequency,time_of_day,start_date\nAmoxicillin,500mg,Daily,08:00,2024-03-01\n"))
+	form.Close()
+
+	req, err := http.NewRequest("POST", "/api/medicines/import", &body)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ImportMedicines).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestImportMedicinesBadRequest(t *testing.T) {
+	for query, file := range map[string]string{
+		"?date_order=ymd": "name\nAmoxicillin\n",
+		"?map=Rx":         "name\nAmoxicillin\n",
+		"":                "name,dosage\n",
+		"?map=Rx:colour":  "Rx\nAmoxicillin\n",
+		"?dry_run=true":   "Colour\npink\n",
+	} {
+		rr, _ := postImport(t, query, file)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func postImport(t *testing.T, query, file string) (*httptest.ResponseRecorder, importReport) {
+	req, err := http.NewRequest("POST", "/api/medicines/import"+query, bytes.NewBufferString(file))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ImportMedicines).ServeHTTP(rr, req)
+
+	var report importReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	return rr, report
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/guardrails"
+	"medicine-reminder/models"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// doseColumns lists the doses columns in the order expected by scanDose
+const doseColumns = "id, medicine_id, status, scheduled_at, taken_at, notes, created_at, override_reason"
+
+// scanDose reads a dose selected with doseColumns
+func scanDose(s database.Scanner) (models.Dose, error) {
+	var d models.Dose
+	err := s.Scan(&d.ID, &d.MedicineID, &d.Status, &d.ScheduledAt, &d.TakenAt, &d.Notes, &d.CreatedAt,
+		&d.OverrideReason)
+	return d, err
+}
+
+// GetDoses handles GET /api/medicines/{id}/doses
+// Returns the doses logged for a medicine, most recent first
+func GetDoses(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !findMedicine(w, id) {
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT "+doseColumns+" FROM doses WHERE medicine_id = $1 ORDER BY taken_at DESC", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	doses := []models.Dose{}
+	for rows.Next() {
+		d, err := scanDose(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		doses = append(doses, d)
+	}
+
+	respondWithJSON(w, http.StatusOK, doses)
+}
+
+// LogDose handles POST /api/medicines/{id}/doses
+// Records a dose as taken or skipped. Taking a dose uses up stock when the
+// medicine's supply is tracked.
+func LogDose(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var input models.DoseInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validateDoseInput(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the medicine so concurrent doses don't break limits or lose stock updates
+	medicine, err := database.ScanMedicine(tx.QueryRow(
+		"SELECT "+database.MedicineColumns+" FROM medicines WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Medicine not found")
+		return
+	}
+
+	if input.Status == models.DoseTaken && guardrails.HasLimits(medicine) {
+		taken, err := loadTakenDoses(tx, medicine.ID,
+			input.TakenAt.Add(-guardrails.Window), input.TakenAt.Add(guardrails.Window))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		if v := guardrails.Check(medicine, taken, input.TakenAt); v != nil && input.OverrideReason == "" {
+			respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":           v.Reason + "; provide override_reason to log it anyway",
+				"next_allowed_at": v.NextAllowedAt,
+			})
+			return
+		}
+	}
+
+	dose, err := scanDose(tx.QueryRow(`
+		INSERT INTO doses (medicine_id, status, scheduled_at, taken_at, notes, created_at, override_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+doseColumns,
+		medicine.ID, input.Status, input.ScheduledAt, input.TakenAt, input.Notes, time.Now(), input.OverrideReason,
+	))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging dose")
+		return
+	}
+
+	if dose.Status == models.DoseTaken && medicine.StockQuantity != nil {
+		stock := *medicine.StockQuantity
+		balance := stock - medicine.UnitsPerDose
+		if balance < 0 {
+			balance = 0
+		}
+		if err := updateStock(tx, r, medicine, balance); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating stock")
+			return
+		}
+		err = recordInventoryChange(tx, dose.MedicineID, &dose.ID, balance-stock, balance, "dose taken")
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating stock")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging dose")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, dose)
+}
+
+// GetDoseStatus handles GET /api/medicines/{id}/dose-status
+// Returns the medicine's recent doses against its limits and when the next dose is allowed
+func GetDoseStatus(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	medicine, err := database.ScanMedicine(database.DB.QueryRow(
+		"SELECT "+database.MedicineColumns+" FROM medicines WHERE id = $1 AND deleted_at IS NULL", id))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Medicine not found")
+		return
+	}
+
+	status, err := doseStatus(medicine, time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, status)
+}
+
+// GetAsNeededDoseStatus handles GET /api/medicines/as-needed
+// Returns the dose status of every as-needed (PRN) medicine
+func GetAsNeededDoseStatus(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query(
+		"SELECT " + database.MedicineColumns + " FROM medicines WHERE as_needed AND deleted_at IS NULL ORDER BY name")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	var medicines []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		medicines = append(medicines, m)
+	}
+
+	now := time.Now()
+	statuses := []models.DoseStatus{}
+	for _, m := range medicines {
+		status, err := doseStatus(m, now)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		statuses = append(statuses, status)
+	}
+
+	respondWithJSON(w, http.StatusOK, statuses)
+}
+
+// doseStatus summarizes a medicine's doses in the 24 hours before now
+func doseStatus(m models.Medicine, now time.Time) (models.DoseStatus, error) {
+	taken, err := loadTakenDoses(database.DB, m.ID, now.Add(-guardrails.Window), now)
+	if err != nil {
+		return models.DoseStatus{}, err
+	}
+
+	status := models.DoseStatus{
+		MedicineID:             m.ID,
+		Name:                   m.Name,
+		Dosage:                 m.Dosage,
+		DosesLast24h:           guardrails.CountSince(taken, now.Add(-guardrails.Window), now),
+		MaxDosesPer24h:         m.MaxDosesPer24h,
+		MinDoseIntervalMinutes: m.MinDoseIntervalMinutes,
+		NextAllowedAt:          guardrails.NextAllowed(m, taken, now),
+	}
+	if len(taken) > 0 {
+		status.LastTakenAt = &taken[len(taken)-1]
+	}
+	return status, nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadTakenDoses returns when the medicine's doses within [from, to] were taken, oldest first
+func loadTakenDoses(q queryer, medicineID int, from, to time.Time) ([]time.Time, error) {
+	rows, err := q.Query(`
+		SELECT taken_at FROM doses
+		WHERE medicine_id = $1 AND status = $2 AND taken_at BETWEEN $3 AND $4
+		ORDER BY taken_at`,
+		medicineID, models.DoseTaken, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var taken []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		taken = append(taken, t)
+	}
+	return taken, rows.Err()
+}
+
+func validateDoseInput(input *models.DoseInput) error {
+	if input.Status == "" {
+		input.Status = models.DoseTaken
+	}
+	if input.Status != models.DoseTaken && input.Status != models.DoseSkipped {
+		return fmt.Errorf("status must be %q or %q", models.DoseTaken, models.DoseSkipped)
+	}
+	if input.TakenAt.IsZero() {
+		input.TakenAt = time.Now()
+	}
+	if input.TakenAt.After(time.Now().Add(5 * time.Minute)) {
+		return fmt.Errorf("taken at must not be in the future")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogDoseDecrementsStock(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+	_, err := database.DB.Exec("UPDATE medicines SET stock_quantity = 10, units_per_dose = 2 WHERE id = $1", medicine.ID)
+	assert.NoError(t, err)
+
+	rr := postDose(t, medicine.ID, models.DoseInput{Status: models.DoseTaken})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var dose models.Dose
+	err = json.Unmarshal(rr.Body.Bytes(), &dose)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DoseTaken, dose.Status)
+	assert.False(t, dose.TakenAt.IsZero())
+
+	// Skipped doses don't use stock
+	rr = postDose(t, medicine.ID, models.DoseInput{Status: models.DoseSkipped})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var stock float64
+	err = database.DB.QueryRow("SELECT stock_quantity FROM medicines WHERE id = $1", medicine.ID).Scan(&stock)
+	assert.NoError(t, err)
+	assert.Equal(t, 8.0, stock)
+
+	var reason string
+	err = database.DB.QueryRow("SELECT reason FROM inventory_transactions WHERE dose_id = $1", dose.ID).Scan(&reason)
+	assert.NoError(t, err)
+	assert.Equal(t, "dose taken", reason)
+}
+
+func TestGetDosesNotFound(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+	_, err := database.DB.Exec("UPDATE medicines SET deleted_at = $1 WHERE id = $2", time.Now(), medicine.ID)
+	assert.NoError(t, err)
+
+	// Unknown and trashed medicines have no doses or refill alerts to list
+	for _, id := range []int{0, medicine.ID} {
+		vars := map[string]string{"id": fmt.Sprintf("%d", id)}
+		for _, handler := range []http.HandlerFunc{GetDoses, GetMedicineRefillAlerts} {
+			req, err := http.NewRequest("GET", "", nil)
+			assert.NoError(t, err)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, mux.SetURLVars(req, vars))
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+		}
+	}
+}
+
+func TestLogDoseInvalidStatus(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+
+	rr := postDose(t, medicine.ID, models.DoseInput{Status: "forgotten"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestLogDoseEnforcesMaxDoses(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+	_, err := database.DB.Exec(
+		"UPDATE medicines SET as_needed = TRUE, max_doses_per_24h = 2, min_dose_interval_minutes = 60 WHERE id = $1",
+		medicine.ID)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	rr := postDose(t, medicine.ID, models.DoseInput{TakenAt: now.Add(-3 * time.Hour)})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Too soon after the first dose
+	rr = postDose(t, medicine.ID, models.DoseInput{TakenAt: now.Add(-150 * time.Minute)})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = postDose(t, medicine.ID, models.DoseInput{TakenAt: now.Add(-time.Hour)})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// A third dose in 24 hours is refused unless overridden
+	rr = postDose(t, medicine.ID, models.DoseInput{TakenAt: now})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	var conflict map[string]interface{}
+	err = json.Unmarshal(rr.Body.Bytes(), &conflict)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, conflict["next_allowed_at"])
+
+	rr = postDose(t, medicine.ID, models.DoseInput{TakenAt: now, OverrideReason: "severe pain, doctor approved"})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var dose models.Dose
+	err = json.Unmarshal(rr.Body.Bytes(), &dose)
+	assert.NoError(t, err)
+	assert.Equal(t, "severe pain, doctor approved", dose.OverrideReason)
+}
+
+// Helper function to send a dose to the log handler
+func postDose(t *testing.T, medicineID int, input models.DoseInput) *httptest.ResponseRecorder {
+	body, err := json.Marshal(input)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("/api/medicines/%d/doses", medicineID), bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicineID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(LogDose).ServeHTTP(rr, req)
+	return rr
+}
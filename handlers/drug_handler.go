@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"medicine-reminder/catalog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultDrugSearchLimit is how many drugs a search returns unless ?limit= says otherwise
+const defaultDrugSearchLimit = 10
+
+// SearchDrugs handles GET /api/drugs?q=
+// Returns catalog drugs whose generic or brand name starts with or closely
+// matches the query, best match first. The number of results defaults to 10
+// and can be changed with ?limit=N.
+func SearchDrugs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		respondWithError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	limit := defaultDrugSearchLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	respondWithJSON(w, http.StatusOK, catalog.Current().Search(q, limit))
+}
+
+// GetDrug handles GET /api/drugs/{drug_id}
+// Returns a drug catalog entry
+func GetDrug(w http.ResponseWriter, r *http.Request) {
+	drug, ok := catalog.Current().Get(mux.Vars(r)["drug_id"])
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Drug not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, drug)
+}
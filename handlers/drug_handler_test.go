@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"medicine-reminder/catalog"
+	"medicine-reminder/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchDrugs(t *testing.T) {
+	drugs, err := catalog.Load(filepath.Join("..", "data", "drugs.json"))
+	assert.NoError(t, err)
+	catalog.SetCurrent(drugs)
+
+	req, err := http.NewRequest("GET", "/api/drugs?q=Paracetmol&limit=5", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SearchDrugs).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var results []catalog.Result
+	err = json.Unmarshal(rr.Body.Bytes(), &results)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, results)
+	assert.Equal(t, "paracetamol", results[0].ID)
+
+	req, err = http.NewRequest("GET", "/api/drugs", nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(SearchDrugs).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetDrug(t *testing.T) {
+	drugs, err := catalog.Load(filepath.Join("..", "data", "drugs.json"))
+	assert.NoError(t, err)
+	catalog.SetCurrent(drugs)
+
+	req, err := http.NewRequest("GET", "/api/drugs/amoxicillin", nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"drug_id": "amoxicillin"})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetDrug).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var drug catalog.Drug
+	err = json.Unmarshal(rr.Body.Bytes(), &drug)
+	assert.NoError(t, err)
+	assert.Equal(t, "J01CA04", drug.Identifiers["atc"])
+}
+
+func TestCreateMedicineUnknownDrug(t *testing.T) {
+	drugs, err := catalog.Load(filepath.Join("..", "data", "drugs.json"))
+	assert.NoError(t, err)
+	catalog.SetCurrent(drugs)
+
+	drugID := "not-a-drug"
+	rr := postMedicine(t, models.MedicineInput{
+		Name:      "Paracetamol",
+		Dosage:    "500mg",
+		Frequency: "Twice daily",
+		TimeOfDay: []string{"08:00", "20:00"},
+		StartDate: time.Now(),
+		EndDate:   time.Now().AddDate(0, 0, 7),
+		DrugID:    &drugID,
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/fhir"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Bundle types POST /fhir accepts
+const (
+	bundleTransaction = "transaction" // All entries are saved or none are
+	bundleBatch       = "batch"       // Each entry is saved on its own
+	bundleCollection  = "collection"  // Saved like a transaction
+)
+
+// skippedStatuses are MedicationStatement statuses that aren't imported
+var skippedStatuses = map[string]bool{"entered-in-error": true, "not-taken": true}
+
+// SearchMedicationStatements handles GET /fhir/MedicationStatement
+// Returns medicines as a FHIR searchset Bundle of MedicationStatements.
+// Supports the patient (an ID or Patient/<id>), status, _id, _count and
+// _offset search parameters.
+func SearchMedicationStatements(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	list := url.Values{"sort": {"id"}}
+
+	if p := values.Get("patient"); p != "" {
+		if _, err := strconv.Atoi(strings.TrimPrefix(p, "Patient/")); err != nil {
+			respondWithFHIRError(w, http.StatusBadRequest, "invalid", "patient must be a patient ID or Patient/<id>")
+			return
+		}
+		list.Set("patient_id", strings.TrimPrefix(p, "Patient/"))
+	}
+
+	if s := values.Get("status"); s != "" {
+		var statuses []string
+		for _, status := range strings.Split(s, ",") {
+			lifecycle, ok := fhir.LifecycleStatus(status)
+			if !ok {
+				respondWithFHIRError(w, http.StatusBadRequest, "invalid", "status must be one of intended, active, on-hold, completed")
+				return
+			}
+			statuses = append(statuses, lifecycle)
+		}
+		list.Set("status", strings.Join(statuses, ","))
+	}
+
+	q, err := parseMedicineListQuery(list, time.Now())
+	if err != nil {
+		respondWithFHIRError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+
+	if s := values.Get("_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			respondWithFHIRError(w, http.StatusBadRequest, "invalid", "_id must be a number")
+			return
+		}
+		q.args = append(q.args, id)
+		q.conditions = append(q.conditions, fmt.Sprintf("id = $%d", len(q.args)))
+	}
+
+	count, offset := defaultMedicinePageSize, 0
+	if s := values.Get("_count"); s != "" {
+		if count, err = strconv.Atoi(s); err != nil || count < 0 || count > maxMedicinePageSize {
+			respondWithFHIRError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("_count must be between 0 and %d", maxMedicinePageSize))
+			return
+		}
+	}
+	if s := values.Get("_offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			respondWithFHIRError(w, http.StatusBadRequest, "invalid", "_offset must be a number of at least 0")
+			return
+		}
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM medicines"+q.where(), q.args...).Scan(&total); err != nil {
+		respondWithFHIRError(w, http.StatusInternalServerError, "exception", "Database error")
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT "+database.MedicineColumns+" FROM medicines"+q.where()+q.orderBy()+fmt.Sprintf(" LIMIT %d OFFSET %d", count, offset),
+		q.args...)
+	if err != nil {
+		respondWithFHIRError(w, http.StatusInternalServerError, "exception", "Database error")
+		return
+	}
+	defer rows.Close()
+
+	base := fhirBase(r)
+	bundle := fhir.Bundle{ResourceType: "Bundle", Type: "searchset", Total: &total}
+	bundle.Link = append(bundle.Link, fhir.BundleLink{Relation: "self", URL: base + r.URL.RequestURI()})
+	if offset+count < total && count > 0 {
+		next := r.URL.Query()
+		next.Set("_offset", strconv.Itoa(offset+count))
+		bundle.Link = append(bundle.Link, fhir.BundleLink{Relation: "next", URL: base + r.URL.Path + "?" + next.Encode()})
+	}
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			respondWithFHIRError(w, http.StatusInternalServerError, "exception", "Error scanning database result")
+			return
+		}
+		resource, err := json.Marshal(fhir.FromMedicine(m))
+		if err != nil {
+			respondWithFHIRError(w, http.StatusInternalServerError, "exception", "Error encoding MedicationStatement")
+			return
+		}
+		bundle.Entry = append(bundle.Entry, fhir.BundleEntry{
+			FullURL:  fmt.Sprintf("%s/fhir/MedicationStatement/%d", base, m.ID),
+			Resource: resource,
+			Search:   &fhir.BundleSearch{Mode: "match"},
+		})
+	}
+	respondWithFHIR(w, http.StatusOK, bundle)
+}
+
+// GetMedicationStatement handles GET /fhir/MedicationStatement/{id}
+// Returns one medicine as a FHIR MedicationStatement
+func GetMedicationStatement(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	medicine, err := database.ScanMedicine(database.DB.QueryRow(
+		"SELECT "+database.MedicineColumns+" FROM medicines WHERE id = $1 AND deleted_at IS NULL", id))
+	if err != nil {
+		respondWithFHIRError(w, http.StatusNotFound, "not-found", "MedicationStatement/"+id+" not found")
+		return
+	}
+
+	w.Header().Set("ETag", medicineETag(medicine))
+	respondWithFHIR(w, http.StatusOK, fhir.FromMedicine(medicine))
+}
+
+// ImportFHIRBundle handles POST /fhir
+// Creates medicines from the MedicationStatements of a FHIR Bundle, with the
+// same checks as POST /api/medicines. Transaction and collection bundles are
+// only saved if every statement is valid; a batch saves the valid ones.
+// Other resources, and statements entered in error or not taken, are
+// skipped. Returns a transaction-response or batch-response Bundle.
+func ImportFHIRBundle(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var bundle fhir.Bundle
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		respondWithFHIRError(w, http.StatusBadRequest, "structure", "Invalid FHIR JSON")
+		return
+	}
+	if bundle.ResourceType != "Bundle" {
+		respondWithFHIRError(w, http.StatusBadRequest, "invalid", "resourceType must be Bundle")
+		return
+	}
+	if bundle.Type != bundleTransaction && bundle.Type != bundleBatch && bundle.Type != bundleCollection {
+		respondWithFHIRError(w, http.StatusBadRequest, "not-supported", "Bundle type must be transaction, batch or collection")
+		return
+	}
+	if len(bundle.Entry) > maxImportRows {
+		respondWithFHIRError(w, http.StatusBadRequest, "too-costly", fmt.Sprintf("Bundle can have at most %d entries", maxImportRows))
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithFHIRError(w, http.StatusInternalServerError, "exception", "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	response := fhir.Bundle{ResourceType: "Bundle", Type: "transaction-response"}
+	if bundle.Type == bundleBatch {
+		response.Type = "batch-response"
+	}
+	failures := fhir.OperationOutcome{ResourceType: "OperationOutcome"}
+	for i, entry := range bundle.Entry {
+		expression := fmt.Sprintf("Bundle.entry[%d].resource", i)
+		fail := func(code, diagnostics string) {
+			issue := fhir.Issue{Severity: "error", Code: code, Diagnostics: diagnostics, Expression: []string{expression}}
+			failures.Issue = append(failures.Issue, issue)
+			response.Entry = append(response.Entry, fhir.BundleEntry{Response: &fhir.BundleResponse{
+				Status:  "400 Bad Request",
+				Outcome: &fhir.OperationOutcome{ResourceType: "OperationOutcome", Issue: []fhir.Issue{issue}},
+			}})
+		}
+		skip := func(diagnostics string) {
+			response.Entry = append(response.Entry, fhir.BundleEntry{Response: &fhir.BundleResponse{
+				Status: "200 OK",
+				Outcome: &fhir.OperationOutcome{ResourceType: "OperationOutcome", Issue: []fhir.Issue{
+					{Severity: "information", Code: "informational", Diagnostics: diagnostics, Expression: []string{expression}},
+				}},
+			}})
+		}
+
+		var resource struct {
+			ResourceType string `json:"resourceType"`
+			Status       string `json:"status"`
+		}
+		if err := json.Unmarshal(entry.Resource, &resource); err != nil {
+			fail("structure", "entry has no resource")
+			continue
+		}
+		if resource.ResourceType != "MedicationStatement" {
+			skip(resource.ResourceType + " resources are not imported")
+			continue
+		}
+		if skippedStatuses[resource.Status] {
+			skip("MedicationStatements with status " + resource.Status + " are not imported")
+			continue
+		}
+
+		var statement fhir.MedicationStatement
+		if err := json.Unmarshal(entry.Resource, &statement); err != nil {
+			fail("structure", "Invalid MedicationStatement: "+err.Error())
+			continue
+		}
+		input, err := fhir.ToInput(statement)
+		if err != nil {
+			fail("invalid", err.Error())
+			continue
+		}
+		medicine, message, ok := importMedicine(tx, r, input)
+		if !ok {
+			fail("processing", message)
+			continue
+		}
+		response.Entry = append(response.Entry, fhir.BundleEntry{
+			FullURL: fmt.Sprintf("%s/fhir/MedicationStatement/%d", fhirBase(r), medicine.ID),
+			Response: &fhir.BundleResponse{
+				Status:   "201 Created",
+				Location: fmt.Sprintf("MedicationStatement/%d/_history/%d", medicine.ID, medicine.Version),
+			},
+		})
+	}
+
+	if len(failures.Issue) > 0 && bundle.Type != bundleBatch {
+		respondWithFHIR(w, http.StatusBadRequest, failures)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithFHIRError(w, http.StatusInternalServerError, "exception", "Error importing medicines")
+		return
+	}
+	respondWithFHIR(w, http.StatusOK, response)
+}
+
+// fhirBase returns the scheme and host the request was made to, for the
+// absolute URLs FHIR bundles use
+func fhirBase(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// respondWithFHIR writes a FHIR resource as the response
+func respondWithFHIR(w http.ResponseWriter, code int, resource interface{}) {
+	w.Header().Set("Content-Type", fhir.ContentType)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resource)
+}
+
+// respondWithFHIRError writes an OperationOutcome with a single error
+func respondWithFHIRError(w http.ResponseWriter, code int, issueCode, message string) {
+	respondWithFHIR(w, code, fhir.NewOperationOutcome(issueCode, message))
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"medicine-reminder/fhir"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const fhirTestBundle = `{
+	"resourceType": "Bundle",
+	"type": "transaction",
+	"entry": [
+		{"resource": {"resourceType": "Patient", "id": "ehr-1"}},
+		{"resource": {
+			"resourceType": "MedicationStatement",
+			"status": "active",
+			"medicationCodeableConcept": {"text": "Metformin"},
+			"effectivePeriod": {"start": "2024-03-01", "end": "2024-06-30"},
+			"note": [{"text": "With meals"}],
+			"dosage": [{
+				"text": "500mg",
+				"timing": {
+					"code": {"text": "Twice daily"},
+					"repeat": {"frequency": 2, "period": 1, "periodUnit": "d", "timeOfDay": ["08:00:00", "20:00:00"]}
+				}
+			}]
+		}},
+		{"resource": {
+			"resourceType": "MedicationStatement",
+			"status": "entered-in-error",
+			"medicationCodeableConcept": {"text": "Warfarin"}
+		}}
+	]
+}`
+
+func TestImportFHIRBundleRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	rr, response := postFHIRBundle(t, fhirTestBundle)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, fhir.ContentType, rr.Header().Get("Content-Type"))
+	assert.Equal(t, "transaction-response", response.Type)
+	assert.Len(t, response.Entry, 3)
+	assert.Equal(t, "200 OK", response.Entry[0].Response.Status)
+	assert.Equal(t, "201 Created", response.Entry[1].Response.Status)
+	assert.Equal(t, "200 OK", response.Entry[2].Response.Status)
+	assert.Equal(t, 1, getMedicinePage(t, "/api/medicines").Total)
+
+	// Searching returns the imported statement as it was sent
+	bundle := searchMedicationStatements(t, "?status=active")
+	assert.Equal(t, "searchset", bundle.Type)
+	assert.Equal(t, 1, *bundle.Total)
+	assert.Len(t, bundle.Entry, 1)
+	assert.Equal(t, response.Entry[1].FullURL, bundle.Entry[0].FullURL)
+
+	var s fhir.MedicationStatement
+	assert.NoError(t, json.Unmarshal(bundle.Entry[0].Resource, &s))
+	assert.Equal(t, "Metformin", s.MedicationCodeableConcept.Text)
+	assert.Equal(t, &fhir.Period{Start: "2024-03-01", End: "2024-06-30"}, s.EffectivePeriod)
+	assert.Equal(t, "500mg", s.Dosage[0].Text)
+	assert.Equal(t, "Twice daily", s.Dosage[0].Timing.Code.Text)
+	assert.Equal(t, []string{"08:00:00", "20:00:00"}, s.Dosage[0].Timing.Repeat.TimeOfDay)
+	assert.Equal(t, []fhir.Annotation{{Text: "With meals"}}, s.Note)
+
+	// And the statement can be read on its own
+	req, err := http.NewRequest("GET", "/fhir/MedicationStatement/"+s.ID, nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": s.ID})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetMedicationStatement).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	assert.Equal(t, 0, *searchMedicationStatements(t, "?status=completed").Total)
+}
+
+func TestImportFHIRBundleInvalid(t *testing.T) {
+	setupTestDB(t)
+
+	body := `{"resourceType": "Bundle", "type": "transaction", "entry": [
+		{"resource": {"resourceType": "MedicationStatement", "status": "active", "medicationCodeableConcept": {"text": "Aspirin"},
+			"effectiveDateTime": "2024-03-01", "dosage": [{"text": "75mg", "timing": {"code": {"text": "Daily"}, "repeat": {"timeOfDay": ["08:00:00"]}}}]}},
+		{"resource": {"resourceType": "MedicationStatement", "status": "active", "subject": {"reference": "Group/1"},
+			"medicationCodeableConcept": {"text": "Aspirin"}}}
+	]}`
+	rr, _ := postFHIRBundle(t, body)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var outcome fhir.OperationOutcome
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &outcome))
+	assert.Len(t, outcome.Issue, 1)
+	assert.Equal(t, []string{"Bundle.entry[1].resource"}, outcome.Issue[0].Expression)
+
+	// Nothing is saved from a failed transaction
+	assert.Equal(t, 0, getMedicinePage(t, "/api/medicines").Total)
+
+	// A batch saves the valid entries
+	rr, response := postFHIRBundle(t, strings.Replace(body, `"transaction"`, `"batch"`, 1))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "201 Created", response.Entry[0].Response.Status)
+	assert.Equal(t, "400 Bad Request", response.Entry[1].Response.Status)
+	assert.Equal(t, 1, getMedicinePage(t, "/api/medicines").Total)
+}
+
+func TestGetMedicationStatementNotFound(t *testing.T) {
+	setupTestDB(t)
+
+	req, err := http.NewRequest("GET", "/fhir/MedicationStatement/999999", nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "999999"})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetMedicationStatement).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	var outcome fhir.OperationOutcome
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &outcome))
+	assert.Equal(t, "not-found", outcome.Issue[0].Code)
+}
+
+func TestFHIRBadRequests(t *testing.T) {
+	for _, query := range []string{"?status=unknown", "?patient=Group/1", "?_count=-1", "?_offset=x", "?_id=abc"} {
+		req, err := http.NewRequest("GET", "/fhir/MedicationStatement"+query, nil)
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(SearchMedicationStatements).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	for _, body := range []string{"{", `{"resourceType": "Patient"}`, `{"resourceType": "Bundle", "type": "searchset"}`} {
+		rr, _ := postFHIRBundle(t, body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func postFHIRBundle(t *testing.T, body string) (*httptest.ResponseRecorder, fhir.Bundle) {
+	req, err := http.NewRequest("POST", "/fhir", bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", fhir.ContentType)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ImportFHIRBundle).ServeHTTP(rr, req)
+
+	var bundle fhir.Bundle
+	json.Unmarshal(rr.Body.Bytes(), &bundle)
+	return rr, bundle
+}
+
+func searchMedicationStatements(t *testing.T, query string) fhir.Bundle {
+	req, err := http.NewRequest("GET", "/fhir/MedicationStatement"+query, nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SearchMedicationStatements).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var bundle fhir.Bundle
+	json.Unmarshal(rr.Body.Bytes(), &bundle)
+	return bundle
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"medicine-reminder/catalog"
+	"medicine-reminder/database"
+	"medicine-reminder/interactions"
+	"medicine-reminder/models"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GetPatientInteractions handles GET /api/patients/{pid}/interactions
+// Returns the known interactions between the patient's current medicines,
+// most serious first
+func GetPatientInteractions(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := database.DB.Query("SELECT "+database.MedicineColumns+`
+		FROM medicines
+		WHERE patient_id = $1 AND deleted_at IS NULL AND (end_date IS NULL OR end_date >= $2)
+		ORDER BY id`, pid, time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	var medicines []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		medicines = append(medicines, m)
+	}
+
+	dataset := interactions.Current()
+	warnings := []models.InteractionWarning{}
+	for i, m := range medicines {
+		warnings = append(warnings, interactionWarnings(dataset, m, medicines[i+1:])...)
+	}
+	sortWarnings(warnings)
+
+	respondWithJSON(w, http.StatusOK, warnings)
+}
+
+// checkInteractions returns the interactions between a medicine being saved and
+// the patient's other medicines taken during its course. It reads them through
+// q so medicines created earlier in the same transaction are included.
+func checkInteractions(q queryer, input models.MedicineInput) ([]models.InteractionWarning, error) {
+	if input.PatientID == nil {
+		return nil, nil
+	}
+
+	rows, err := q.Query("SELECT "+database.MedicineColumns+`
+		FROM medicines
+		WHERE patient_id = $1 AND deleted_at IS NULL AND (end_date IS NULL OR (end_date >= $2 AND end_date >= $4))
+			AND ($3::timestamptz IS NULL OR start_date <= $3)
+		ORDER BY id`, *input.PatientID, time.Now(), endDate(input), input.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var others []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			return nil, err
+		}
+		others = append(others, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	warnings := interactionWarnings(interactions.Current(), models.Medicine{Name: input.Name, DrugID: input.DrugID}, others)
+	sortWarnings(warnings)
+	return warnings, nil
+}
+
+// interactionWarnings returns the interactions between a medicine and each of others
+func interactionWarnings(dataset *interactions.Dataset, m models.Medicine, others []models.Medicine) []models.InteractionWarning {
+	var warnings []models.InteractionWarning
+	for _, other := range others {
+		for _, in := range dataset.Between(interactionName(m), interactionName(other)) {
+			warnings = append(warnings, models.InteractionWarning{
+				MedicineID:        m.ID,
+				MedicineName:      m.Name,
+				OtherMedicineID:   other.ID,
+				OtherMedicineName: other.Name,
+				Drugs:             in.Drugs,
+				Severity:          string(in.Severity),
+				Description:       in.Description,
+			})
+		}
+	}
+	return warnings
+}
+
+// interactionName returns the name a medicine's interactions are looked up by.
+// A linked catalog entry is more reliable than the medicine's name, so its
+// generic name and active ingredients are used when the medicine has one.
+func interactionName(m models.Medicine) string {
+	if m.DrugID != nil {
+		if d, ok := catalog.Current().Get(*m.DrugID); ok {
+			return strings.Join(append([]string{d.Name}, d.Ingredients...), ", ")
+		}
+	}
+	return m.Name
+}
+
+// hasSevereInteraction reports whether any of the warnings is severe
+func hasSevereInteraction(warnings []models.InteractionWarning) bool {
+	for _, w := range warnings {
+		if w.Severity == string(interactions.Severe) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortWarnings orders warnings most serious first
+func sortWarnings(warnings []models.InteractionWarning) {
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return interactions.Rank(interactions.Severity(warnings[i].Severity)) >
+			interactions.Rank(interactions.Severity(warnings[j].Severity))
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/catalog"
+	"medicine-reminder/interactions"
+	"medicine-reminder/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateMedicineInteractions(t *testing.T) {
+	setupTestDB(t)
+
+	dataset, err := interactions.Load(filepath.Join("..", "data", "interactions.json"))
+	assert.NoError(t, err)
+	interactions.SetCurrent(dataset)
+
+	patient := createTestPatient(t)
+	input := models.MedicineInput{
+		Name:      "Coumadin",
+		Dosage:    "5mg",
+		Frequency: "Once daily",
+		TimeOfDay: []string{"18:00"},
+		StartDate: time.Now(),
+		EndDate:   time.Now().AddDate(0, 1, 0),
+		PatientID: &patient.ID,
+	}
+	rr := postMedicine(t, input)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Severe interactions need to be acknowledged
+	input.Name = "Aspirin"
+	input.Dosage = "81mg"
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	input.AcknowledgeInteractions = true
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var created medicineResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &created)
+	assert.NoError(t, err)
+	assert.Len(t, created.Interactions, 1)
+	assert.Equal(t, "severe", created.Interactions[0].Severity)
+	assert.Equal(t, created.ID, created.Interactions[0].MedicineID)
+
+	// Brand names are matched to their generic drug
+	input.Name = "Advil"
+	input.Dosage = "200mg"
+	input.AcknowledgeInteractions = false
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/patients/%d/interactions", patient.ID), nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"pid": fmt.Sprintf("%d", patient.ID)})
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetPatientInteractions).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var warnings []models.InteractionWarning
+	err = json.Unmarshal(rr.Body.Bytes(), &warnings)
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, [2]string{"warfarin", "aspirin"}, warnings[0].Drugs)
+}
+
+func TestInteractionWarningsLinkedDrug(t *testing.T) {
+	dataset, err := interactions.Load(filepath.Join("..", "data", "interactions.json"))
+	assert.NoError(t, err)
+	drugs, err := catalog.Load(filepath.Join("..", "data", "drugs.json"))
+	assert.NoError(t, err)
+	catalog.SetCurrent(drugs)
+
+	// Medicines linked to a catalog drug are matched by it, whatever their name
+	warfarin := "warfarin"
+	thinner := models.Medicine{ID: 1, Name: "Blood thinner", DrugID: &warfarin}
+	aspirin := models.Medicine{ID: 2, Name: "Aspirin"}
+	warnings := interactionWarnings(dataset, thinner, []models.Medicine{aspirin})
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, "Blood thinner", warnings[0].MedicineName)
+	assert.Equal(t, "severe", warnings[0].Severity)
+
+	warnings = interactionWarnings(dataset, aspirin, []models.Medicine{thinner})
+	assert.Len(t, warnings, 1)
+
+	// Unlinked medicines are still matched by name
+	assert.Empty(t, interactionWarnings(dataset, models.Medicine{Name: "Blood thinner"}, []models.Medicine{aspirin}))
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"medicine-reminder/database"
+	"medicine-reminder/inventory"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// recentActivityLimit is how many inventory transactions GetInventory returns
+const recentActivityLimit = 20
+
+// GetInventory handles GET /api/medicines/{id}/inventory
+// Returns the medicine's stock, daily usage and projected run-out date
+func GetInventory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	medicine, err := database.ScanMedicine(database.DB.QueryRow(
+		"SELECT "+database.MedicineColumns+" FROM medicines WHERE id = $1 AND deleted_at IS NULL", id))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Medicine not found")
+		return
+	}
+
+	clock, err := reminders.LoadClock(medicine.PatientID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading patient time zone")
+		return
+	}
+
+	now := time.Now()
+	runOutAt, err := inventory.RunOutAt(medicine, now, clock)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error projecting supply")
+		return
+	}
+
+	activity, err := loadInventoryTransactions(medicine.ID, recentActivityLimit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Inventory{
+		MedicineID:     medicine.ID,
+		StockQuantity:  medicine.StockQuantity,
+		UnitsPerDose:   medicine.UnitsPerDose,
+		DailyUsage:     inventory.DailyUsage(medicine, now, clock.Location(now)),
+		DaysOfSupply:   inventory.DaysOfSupply(medicine, now, clock.Location(now)),
+		RunOutAt:       runOutAt,
+		RecentActivity: activity,
+	})
+}
+
+// AdjustInventory handles POST /api/medicines/{id}/inventory/adjustments
+// Manually adds or removes stock, e.g. for a refill or lost pills.
+// Adjusting a medicine whose stock isn't tracked starts tracking it from zero.
+func AdjustInventory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var input models.InventoryAdjustmentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if input.Change == 0 {
+		respondWithError(w, http.StatusBadRequest, "change is required")
+		return
+	}
+	if input.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	medicine, ok := lockMedicine(w, tx, id)
+	if !ok {
+		return
+	}
+
+	balance := input.Change
+	if medicine.StockQuantity != nil {
+		balance += *medicine.StockQuantity
+	}
+	if balance < 0 {
+		respondWithError(w, http.StatusBadRequest, "adjustment would make stock negative")
+		return
+	}
+
+	if err := updateStock(tx, r, medicine, balance); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating stock")
+		return
+	}
+	if err := recordInventoryChange(tx, medicine.ID, nil, input.Change, balance, input.Reason); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating stock")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating stock")
+		return
+	}
+
+	activity, err := loadInventoryTransactions(medicine.ID, 1)
+	if err != nil || len(activity) == 0 {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, activity[0])
+}
+
+// updateStock sets the stock of a medicine locked in tx, recording the change
+// in the audit log like any other update
+func updateStock(tx *sql.Tx, r *http.Request, medicine models.Medicine, balance float64) error {
+	updated, err := database.ScanMedicine(tx.QueryRow(
+		"UPDATE medicines SET stock_quantity = $1, version = version + 1 WHERE id = $2 RETURNING "+database.MedicineColumns,
+		balance, medicine.ID))
+	if err != nil {
+		return err
+	}
+	return recordMedicineAudit(tx, r, models.AuditUpdate, &medicine, updated)
+}
+
+// recordInventoryChange appends a stock change to the medicine's inventory history
+func recordInventoryChange(tx *sql.Tx, medicineID int, doseID *int, change, balance float64, reason string) error {
+	_, err := tx.Exec(`
+		INSERT INTO inventory_transactions (medicine_id, dose_id, change, balance_after, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		medicineID, doseID, change, balance, reason, time.Now())
+	return err
+}
+
+// loadInventoryTransactions returns a medicine's most recent stock changes
+func loadInventoryTransactions(medicineID, limit int) ([]models.InventoryTransaction, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, medicine_id, dose_id, change, balance_after, reason, created_at
+		FROM inventory_transactions
+		WHERE medicine_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, medicineID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.InventoryTransaction{}
+	for rows.Next() {
+		var t models.InventoryTransaction
+		if err := rows.Scan(&t.ID, &t.MedicineID, &t.DoseID, &t.Change, &t.BalanceAfter, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
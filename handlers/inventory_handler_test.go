@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdjustAndGetInventory(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+
+	// A refill starts tracking stock
+	rr := postAdjustment(t, medicine.ID, models.InventoryAdjustmentInput{Change: 3, Reason: "refill"})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Stock can't go below zero
+	rr = postAdjustment(t, medicine.ID, models.InventoryAdjustmentInput{Change: -5, Reason: "lost"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/medicines/%d/inventory", medicine.ID), nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicine.ID)})
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetInventory).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response models.Inventory
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	// The test medicine is taken once a day for a week, so three doses run out before it ends
+	assert.Equal(t, 3.0, *response.StockQuantity)
+	assert.Equal(t, 1.0, response.DailyUsage)
+	assert.Equal(t, 3.0, *response.DaysOfSupply)
+	assert.NotNil(t, response.RunOutAt)
+	assert.Equal(t, 1, len(response.RecentActivity))
+}
+
+// Helper function to send a stock adjustment to the handler
+func postAdjustment(t *testing.T, medicineID int, input models.InventoryAdjustmentInput) *httptest.ResponseRecorder {
+	body, err := json.Marshal(input)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("/api/medicines/%d/inventory/adjustments", medicineID), bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicineID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(AdjustInventory).ServeHTTP(rr, req)
+	return rr
+}
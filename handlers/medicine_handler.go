@@ -2,58 +2,120 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"medicine-reminder/catalog"
 	"medicine-reminder/database"
+	"medicine-reminder/dosage"
+	"medicine-reminder/jsonpatch"
 	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// medicineResponse is a medicine along with the warnings raised while saving it
+type medicineResponse struct {
+	models.Medicine
+	Interactions []models.InteractionWarning `json:"interactions,omitempty"`
+	Allergies    []models.AllergyWarning     `json:"allergies,omitempty"`
+}
+
+// notEnded matches courses still running at $1, including indefinite ones
+const notEnded = "(end_date IS NULL OR end_date + INTERVAL '1 day' > $1)"
+
+// statusConditions gives the SQL condition matching each lifecycle status, with
+// the current time as $1. They mirror models.Medicine.StatusAt.
+var statusConditions = map[string]string{
+	models.StatusCompleted: "end_date + INTERVAL '1 day' <= $1",
+	models.StatusPaused:    "(paused_at IS NOT NULL AND " + notEnded + ")",
+	models.StatusUpcoming:  "(paused_at IS NULL AND start_date > $1 AND " + notEnded + ")",
+	models.StatusActive:    "(paused_at IS NULL AND start_date <= $1 AND " + notEnded + ")",
+}
+
 // GetMedicines handles GET /api/medicines
-// Returns a list of all medicines
+// Returns a page of medicines with the total matching the filters. Medicines
+// can be filtered by lifecycle ?status= (e.g. active,paused), ?name= contained
+// in the name, ?patient_id=, ?active_on= a date, and start or end date ranges
+// (?start_from=, ?start_to=, ?end_from=, ?end_to=). ?sort= takes a field, with
+// a "-" prefix for descending order, and defaults to -created_at. Pages hold
+// ?limit= medicines, 50 by default; pass the returned next_cursor as ?cursor=
+// for the next page. ?as_of= returns the list as it was recorded at a past
+// time instead.
 func GetMedicines(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query("SELECT * FROM medicines ORDER BY created_at DESC")
+	if r.URL.Query().Has("as_of") {
+		getMedicinesAsOf(w, r.URL.Query())
+		return
+	}
+
+	q, err := parseMedicineListQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page := medicinePage{Data: []models.Medicine{}}
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM medicines"+q.where(), q.args...).Scan(&page.Total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	clause, args := q.pageSQL()
+	rows, err := database.DB.Query("SELECT "+database.MedicineColumns+" FROM medicines"+clause, args...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 	defer rows.Close()
 
-	var medicines []models.Medicine
 	for rows.Next() {
-		var m models.Medicine
-		err := rows.Scan(&m.ID, &m.Name, &m.Dosage, &m.Frequency, &m.TimeOfDay,
-			&m.StartDate, &m.EndDate, &m.Notes, &m.CreatedAt, &m.UpdatedAt)
+		m, err := database.ScanMedicine(rows)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
 			return
 		}
-		medicines = append(medicines, m)
+		page.Data = append(page.Data, m)
+	}
+
+	if len(page.Data) > q.limit {
+		page.Data = page.Data[:q.limit]
+		last := page.Data[q.limit-1]
+		cursor := medicineCursor{Sort: q.sortSpec(), Value: medicineSortValue(last, q.sortField), ID: last.ID}.encode()
+		page.NextCursor = &cursor
 	}
 
-	respondWithJSON(w, http.StatusOK, medicines)
+	respondWithJSON(w, http.StatusOK, page)
 }
 
 // GetMedicine handles GET /api/medicines/{id}
-// Returns a specific medicine by ID
+// Returns a specific medicine by ID with its ETag, or 304 Not Modified when
+// If-None-Match lists the current ETag
 func GetMedicine(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	var medicine models.Medicine
-	err := database.DB.QueryRow("SELECT * FROM medicines WHERE id = $1", id).Scan(
-		&medicine.ID, &medicine.Name, &medicine.Dosage, &medicine.Frequency, &medicine.TimeOfDay,
-		&medicine.StartDate, &medicine.EndDate, &medicine.Notes, &medicine.CreatedAt, &medicine.UpdatedAt,
-	)
+	medicine, err := database.ScanMedicine(database.DB.QueryRow(
+		"SELECT "+database.MedicineColumns+" FROM medicines WHERE id = $1 AND deleted_at IS NULL", id))
 
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Medicine not found")
 		return
 	}
 
+	etag := medicineETag(medicine)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	respondWithJSON(w, http.StatusOK, medicine)
 }
 
@@ -66,59 +128,132 @@ func CreateMedicine(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	response, ok := insertMedicine(w, tx, r, input)
+	if !ok {
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating medicine")
+		return
+	}
+
+	w.Header().Set("ETag", medicineETag(response.Medicine))
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+// insertMedicine validates input and creates a medicine from it in tx,
+// writing an error response and returning false when it can't
+func insertMedicine(w http.ResponseWriter, tx *sql.Tx, r *http.Request, input models.MedicineInput) (medicineResponse, bool) {
 	// Validate input
-	if err := validateMedicineInput(input); err != nil {
+	if err := validateMedicineInput(&input); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
+		return medicineResponse{}, false
 	}
 
 	// Convert time_of_day array to JSON string
 	timeOfDayJSON, err := json.Marshal(input.TimeOfDay)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error processing time of day")
-		return
+		return medicineResponse{}, false
 	}
 
-	query := `
-		INSERT INTO medicines (name, dosage, frequency, time_of_day, start_date, end_date, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING *`
+	// Severe interactions with the patient's other medicines must be acknowledged
+	warnings, err := checkInteractions(tx, input)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking interactions")
+		return medicineResponse{}, false
+	}
+	if hasSevereInteraction(warnings) && !input.AcknowledgeInteractions {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":        "medicine has severe interactions; set acknowledge_interactions to save it anyway",
+			"interactions": warnings,
+		})
+		return medicineResponse{}, false
+	}
+
+	allergies, ok := checkMedicineAllergies(w, input)
+	if !ok {
+		return medicineResponse{}, false
+	}
 
-	var medicine models.Medicine
-	err = database.DB.QueryRow(
+	// Already validated, so the dosage always parses
+	parsedDosage, _ := dosage.Parse(input.Dosage)
+	phases := dosePhases(input.Phases)
+
+	query := `
+		INSERT INTO medicines (name, dosage, frequency, time_of_day, start_date, end_date, notes, created_at, updated_at,
+			patient_id, quiet_hours_policy, schedule_type, stock_quantity, units_per_dose, refill_threshold_days,
+			dosage_amount, dosage_unit, dosage_form, as_needed, max_doses_per_24h, min_dose_interval_minutes,
+			drug_id, phases, relative_times, review_interval_days, next_review_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18,
+			$19, $20, $21, $22, $23, $24, $25, $26)
+		RETURNING ` + database.MedicineColumns
+
+	medicine, err := database.ScanMedicine(tx.QueryRow(
 		query,
 		input.Name,
 		input.Dosage,
 		input.Frequency,
 		string(timeOfDayJSON),
 		input.StartDate,
-		input.EndDate,
+		endDate(input),
 		input.Notes,
 		time.Now(),
 		time.Now(),
-	).Scan(
-		&medicine.ID,
-		&medicine.Name,
-		&medicine.Dosage,
-		&medicine.Frequency,
-		&medicine.TimeOfDay,
-		&medicine.StartDate,
-		&medicine.EndDate,
-		&medicine.Notes,
-		&medicine.CreatedAt,
-		&medicine.UpdatedAt,
-	)
+		input.PatientID,
+		input.QuietHoursPolicy,
+		input.ScheduleType,
+		input.StockQuantity,
+		input.UnitsPerDose,
+		input.RefillThresholdDays,
+		parsedDosage.Amount,
+		string(parsedDosage.Unit),
+		parsedDosage.Form,
+		input.AsNeeded,
+		input.MaxDosesPer24h,
+		input.MinDoseIntervalMinutes,
+		input.DrugID,
+		phases,
+		models.RelativeTimes(input.RelativeTimes),
+		input.ReviewIntervalDays,
+		firstReviewAt(input, time.Now()),
+	))
 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating medicine")
-		return
+		return medicineResponse{}, false
 	}
 
-	respondWithJSON(w, http.StatusCreated, medicine)
+	if err := recordMedicineAudit(tx, r, models.AuditCreate, nil, medicine); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating medicine")
+		return medicineResponse{}, false
+	}
+
+	// Record the starting stock so the inventory history is complete
+	if medicine.StockQuantity != nil {
+		err = recordInventoryChange(tx, medicine.ID, nil, *medicine.StockQuantity, *medicine.StockQuantity, "initial stock")
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating medicine")
+			return medicineResponse{}, false
+		}
+	}
+
+	for i := range warnings {
+		warnings[i].MedicineID = medicine.ID
+	}
+	return medicineResponse{Medicine: medicine, Interactions: warnings, Allergies: allergies}, true
 }
 
 // UpdateMedicine handles PUT /api/medicines/{id}
-// Updates an existing medicine record
+// Updates an existing medicine record. With If-Match, the update is only made
+// if the medicine still has that ETag.
 func UpdateMedicine(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -129,118 +264,728 @@ func UpdateMedicine(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate input
-	if err := validateMedicineInput(input); err != nil {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	medicine, ok := lockMedicine(w, tx, id)
+	if !ok || !checkIfMatch(w, r, medicine) {
+		return
+	}
+
+	response, ok := saveMedicine(w, tx, medicine, input)
+	if !ok {
+		return
+	}
+	if err := recordMedicineAudit(tx, r, models.AuditUpdate, &medicine, response.Medicine); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating medicine")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating medicine")
+		return
+	}
+
+	w.Header().Set("ETag", medicineETag(response.Medicine))
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// PatchMedicine handles PATCH /api/medicines/{id}
+// Applies a JSON Merge Patch (application/merge-patch+json, the default) or a
+// JSON Patch (application/json-patch+json) to a medicine. The patched medicine
+// is validated as a whole before it is saved. If-Match is honored as for PUT.
+func PatchMedicine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	applyPatch := jsonpatch.MergePatch
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "", "application/json", "application/merge-patch+json":
+	case "application/json-patch+json":
+		applyPatch = jsonpatch.Apply
+	default:
+		respondWithError(w, http.StatusUnsupportedMediaType,
+			"Content-Type must be application/merge-patch+json or application/json-patch+json")
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	medicine, ok := lockMedicine(w, tx, id)
+	if !ok || !checkIfMatch(w, r, medicine) {
+		return
+	}
+
+	doc, err := json.Marshal(medicineInput(medicine))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error patching medicine")
+		return
+	}
+	patched, err := applyPatch(doc, patch)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	var input models.MedicineInput
+	if err := json.Unmarshal(patched, &input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid patched medicine: "+err.Error())
+		return
+	}
+	dropDefaultReview(medicine, &input)
+
+	response, ok := saveMedicine(w, tx, medicine, input)
+	if !ok {
+		return
+	}
+	if err := recordMedicineAudit(tx, r, models.AuditUpdate, &medicine, response.Medicine); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error patching medicine")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error patching medicine")
+		return
+	}
+
+	w.Header().Set("ETag", medicineETag(response.Medicine))
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// lockMedicine loads a medicine and locks it until tx ends, so the checks made
+// on it hold when it is saved. It writes 404 when the medicine doesn't exist.
+func lockMedicine(w http.ResponseWriter, tx *sql.Tx, id string) (models.Medicine, bool) {
+	medicine, err := database.ScanMedicine(tx.QueryRow(
+		"SELECT "+database.MedicineColumns+" FROM medicines WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Medicine not found")
+		return medicine, false
+	}
+	return medicine, true
+}
+
+// findMedicine checks that the medicine exists and isn't in the trash,
+// writing 404 Not Found and returning false otherwise
+func findMedicine(w http.ResponseWriter, id string) bool {
+	var found int
+	if err := database.DB.QueryRow("SELECT id FROM medicines WHERE id = $1 AND deleted_at IS NULL", id).Scan(&found); err != nil {
+		respondWithError(w, http.StatusNotFound, "Medicine not found")
+		return false
+	}
+	return true
+}
+
+// rowQueryer is implemented by both *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// saveMedicine validates input and saves it over the medicine old, writing an
+// error response and returning false when it can't. Allergies are only checked
+// again when the change could match different ones, so a warning acknowledged
+// when the medicine was saved doesn't block unrelated edits.
+func saveMedicine(w http.ResponseWriter, q rowQueryer, old models.Medicine, input models.MedicineInput) (medicineResponse, bool) {
+	// Stock is only set when a medicine is created; later changes are
+	// inventory adjustments so they are kept in the inventory history
+	if input.StockQuantity != nil {
+		respondWithError(w, http.StatusBadRequest,
+			"stock_quantity can't be updated; use POST /api/medicines/{id}/inventory/adjustments")
+		return medicineResponse{}, false
+	}
+
+	// Validate input
+	if err := validateMedicineInput(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return medicineResponse{}, false
+	}
+
 	// Convert time_of_day array to JSON string
 	timeOfDayJSON, err := json.Marshal(input.TimeOfDay)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error processing time of day")
-		return
+		return medicineResponse{}, false
+	}
+
+	var allergies []models.AllergyWarning
+	if allergensChanged(old, input) {
+		var ok bool
+		if allergies, ok = checkMedicineAllergies(w, input); !ok {
+			return medicineResponse{}, false
+		}
 	}
 
+	// Already validated, so the dosage always parses
+	parsedDosage, _ := dosage.Parse(input.Dosage)
+	phases := dosePhases(input.Phases)
+
 	query := `
 		UPDATE medicines 
 		SET name = $1, dosage = $2, frequency = $3, time_of_day = $4, 
-			start_date = $5, end_date = $6, notes = $7, updated_at = $8
-		WHERE id = $9
-		RETURNING *`
-
-	var medicine models.Medicine
-	err = database.DB.QueryRow(
+			start_date = $5, end_date = $6, notes = $7, updated_at = $8,
+			patient_id = $9, quiet_hours_policy = $10, schedule_type = $11, units_per_dose = $12,
+			refill_threshold_days = $13, dosage_amount = $14, dosage_unit = $15, dosage_form = $16,
+			as_needed = $17, max_doses_per_24h = $18, min_dose_interval_minutes = $19,
+			drug_id = $20, phases = $21, relative_times = $22, review_interval_days = $23, version = version + 1,
+			next_review_at = CASE
+				WHEN $23::integer IS NULL THEN NULL
+				WHEN review_interval_days = $23 THEN next_review_at
+				ELSE COALESCE(last_reviewed_at, $24) + $23 * INTERVAL '1 day'
+			END
+		WHERE id = $25
+		RETURNING ` + database.MedicineColumns
+
+	medicine, err := database.ScanMedicine(q.QueryRow(
 		query,
 		input.Name,
 		input.Dosage,
 		input.Frequency,
 		string(timeOfDayJSON),
 		input.StartDate,
-		input.EndDate,
+		endDate(input),
 		input.Notes,
 		time.Now(),
-		id,
-	).Scan(
-		&medicine.ID,
-		&medicine.Name,
-		&medicine.Dosage,
-		&medicine.Frequency,
-		&medicine.TimeOfDay,
-		&medicine.StartDate,
-		&medicine.EndDate,
-		&medicine.Notes,
-		&medicine.CreatedAt,
-		&medicine.UpdatedAt,
-	)
+		input.PatientID,
+		input.QuietHoursPolicy,
+		input.ScheduleType,
+		input.UnitsPerDose,
+		input.RefillThresholdDays,
+		parsedDosage.Amount,
+		string(parsedDosage.Unit),
+		parsedDosage.Form,
+		input.AsNeeded,
+		input.MaxDosesPer24h,
+		input.MinDoseIntervalMinutes,
+		input.DrugID,
+		phases,
+		models.RelativeTimes(input.RelativeTimes),
+		input.ReviewIntervalDays,
+		reviewBase(input, time.Now()),
+		old.ID,
+	))
 
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Medicine not found")
+		return medicineResponse{}, false
+	}
+
+	return medicineResponse{Medicine: medicine, Allergies: allergies}, true
+}
+
+// allergensChanged reports whether input changes what a medicine's allergies
+// are matched against: its name, catalog drug or patient
+func allergensChanged(old models.Medicine, input models.MedicineInput) bool {
+	return input.Name != old.Name || !sameInt(input.PatientID, old.PatientID) || !sameString(input.DrugID, old.DrugID)
+}
+
+// sameInt reports whether two optional numbers are equal
+func sameInt(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// sameString reports whether two optional strings are equal
+func sameString(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// dropDefaultReview clears the review interval an indefinite course was given
+// by default once a patch gives it an end date, since finite courses aren't
+// reviewed unless asked. An interval set by the patch is kept.
+func dropDefaultReview(old models.Medicine, input *models.MedicineInput) {
+	if old.EndDate == nil && !input.EndDate.IsZero() && sameInt(input.ReviewIntervalDays, old.ReviewIntervalDays) &&
+		input.ReviewIntervalDays != nil && *input.ReviewIntervalDays == models.DefaultReviewIntervalDays {
+		input.ReviewIntervalDays = nil
+	}
+}
+
+// medicineInput returns the input that would save a medicine as it is, which
+// PATCH requests are applied to
+func medicineInput(m models.Medicine) models.MedicineInput {
+	input := models.MedicineInput{
+		Name:                   m.Name,
+		Dosage:                 m.Dosage,
+		Frequency:              m.Frequency,
+		TimeOfDay:              m.TimeOfDay.Strings(),
+		StartDate:              m.StartDate,
+		Notes:                  m.Notes,
+		PatientID:              m.PatientID,
+		QuietHoursPolicy:       m.QuietHoursPolicy,
+		ScheduleType:           m.ScheduleType,
+		UnitsPerDose:           m.UnitsPerDose,
+		RefillThresholdDays:    &m.RefillThresholdDays,
+		AsNeeded:               m.AsNeeded,
+		MaxDosesPer24h:         m.MaxDosesPer24h,
+		MinDoseIntervalMinutes: m.MinDoseIntervalMinutes,
+		DrugID:                 m.DrugID,
+		RelativeTimes:          m.RelativeTimes,
+		ReviewIntervalDays:     m.ReviewIntervalDays,
+	}
+	if m.EndDate != nil {
+		input.EndDate = *m.EndDate
+	}
+	for _, p := range m.Phases {
+		input.Phases = append(input.Phases, models.DosePhaseInput{
+			StartDate: p.StartDate,
+			EndDate:   p.EndDate,
+			Dosage:    p.Dosage,
+			TimeOfDay: p.TimeOfDay.Strings(),
+		})
+	}
+	return input
+}
+
+// PauseMedicine handles POST /api/medicines/{id}/pause
+// Pauses a course, holding its reminders until it is resumed
+func PauseMedicine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	// The reason is optional, so an empty body is allowed
+	var input models.PauseInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && err != io.EOF {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, medicine)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	medicine, ok := lockMedicine(w, tx, id)
+	if !ok {
+		return
+	}
+	if medicine.Status == models.StatusPaused || medicine.Status == models.StatusCompleted {
+		respondWithError(w, http.StatusConflict, "Medicine is already "+medicine.Status)
+		return
+	}
+
+	paused, err := database.ScanMedicine(tx.QueryRow(`
+		UPDATE medicines
+		SET paused_at = $1, pause_reason = $2, updated_at = $1, version = version + 1
+		WHERE id = $3
+		RETURNING `+database.MedicineColumns,
+		time.Now(), input.Reason, medicine.ID,
+	))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error pausing medicine")
+		return
+	}
+	if err := recordMedicineAudit(tx, r, models.AuditUpdate, &medicine, paused); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error pausing medicine")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error pausing medicine")
+		return
+	}
+
+	w.Header().Set("ETag", medicineETag(paused))
+	respondWithJSON(w, http.StatusOK, paused)
+}
+
+// ResumeMedicine handles POST /api/medicines/{id}/resume
+// Resumes a paused course; reminders start again from now
+func ResumeMedicine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	medicine, ok := lockMedicine(w, tx, id)
+	if !ok {
+		return
+	}
+	if medicine.PausedAt == nil {
+		respondWithError(w, http.StatusConflict, "Medicine is not paused")
+		return
+	}
+
+	resumed, err := database.ScanMedicine(tx.QueryRow(`
+		UPDATE medicines
+		SET paused_at = NULL, pause_reason = '', updated_at = $1, version = version + 1
+		WHERE id = $2
+		RETURNING `+database.MedicineColumns,
+		time.Now(), medicine.ID,
+	))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resuming medicine")
+		return
+	}
+	if err := recordMedicineAudit(tx, r, models.AuditUpdate, &medicine, resumed); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resuming medicine")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error resuming medicine")
+		return
+	}
+
+	w.Header().Set("ETag", medicineETag(resumed))
+	respondWithJSON(w, http.StatusOK, resumed)
+}
+
+// ReviewMedicine handles POST /api/medicines/{id}/review
+// Records a medication review, scheduling the next one a review interval from now
+func ReviewMedicine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	medicine, ok := lockMedicine(w, tx, id)
+	if !ok {
+		return
+	}
+	if medicine.ReviewIntervalDays == nil {
+		respondWithError(w, http.StatusConflict, "Medicine has no review interval")
+		return
+	}
+
+	reviewed, err := database.ScanMedicine(tx.QueryRow(`
+		UPDATE medicines
+		SET last_reviewed_at = $1, next_review_at = $1 + review_interval_days * INTERVAL '1 day', updated_at = $1,
+			version = version + 1
+		WHERE id = $2
+		RETURNING `+database.MedicineColumns,
+		time.Now(), medicine.ID,
+	))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reviewing medicine")
+		return
+	}
+	if err := recordMedicineAudit(tx, r, models.AuditUpdate, &medicine, reviewed); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reviewing medicine")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reviewing medicine")
+		return
+	}
+
+	w.Header().Set("ETag", medicineETag(reviewed))
+	respondWithJSON(w, http.StatusOK, reviewed)
 }
 
 // DeleteMedicine handles DELETE /api/medicines/{id}
-// Deletes a medicine record
+// Moves a medicine to the trash, from which it can be restored until it is
+// purged. With If-Match, the medicine is only deleted if it still has that ETag.
 func DeleteMedicine(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	result, err := database.DB.Exec("DELETE FROM medicines WHERE id = $1", id)
+	tx, err := database.DB.Begin()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error deleting medicine")
+		respondWithError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error checking delete result")
+	medicine, ok := lockMedicine(w, tx, id)
+	if !ok || !checkIfMatch(w, r, medicine) {
 		return
 	}
 
-	if rowsAffected == 0 {
-		respondWithError(w, http.StatusNotFound, "Medicine not found")
+	if !trashMedicine(w, tx, r, medicine) {
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting medicine")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// trashMedicine moves a locked medicine to the trash, where it can be restored
+// until it is purged, writing an error response and returning false when it can't
+func trashMedicine(w http.ResponseWriter, tx *sql.Tx, r *http.Request, medicine models.Medicine) bool {
+	deleted, err := database.ScanMedicine(tx.QueryRow(`
+		UPDATE medicines
+		SET deleted_at = $1, updated_at = $1, version = version + 1
+		WHERE id = $2
+		RETURNING `+database.MedicineColumns,
+		time.Now(), medicine.ID,
+	))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting medicine")
+		return false
+	}
+	if err := recordMedicineAudit(tx, r, models.AuditDelete, &medicine, deleted); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting medicine")
+		return false
+	}
+	return true
+}
+
 // Helper functions
 
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, map[string]string{"error": message})
 }
 
+// medicineETag returns the entity tag of a medicine, made from its version
+// alone. The computed status changes with the clock rather than with writes,
+// so it is left out to keep tags stable across day boundaries.
+func medicineETag(m models.Medicine) string {
+	return fmt.Sprintf(`"%d"`, m.Version)
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists etag or
+// is "*". Weak comparison, used for If-None-Match, ignores the W/ prefix.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch honors a request's If-Match header, writing 412 Precondition
+// Failed when the medicine no longer has any of the listed ETags
+func checkIfMatch(w http.ResponseWriter, r *http.Request, m models.Medicine) bool {
+	return checkETag(w, r.Header.Get("If-Match"), m)
+}
+
+// checkETag is checkIfMatch for an If-Match value given some other way, such
+// as in a batch operation
+func checkETag(w http.ResponseWriter, header string, m models.Medicine) bool {
+	if header == "" || etagMatches(header, medicineETag(m), false) {
+		return true
+	}
+	w.Header().Set("ETag", medicineETag(m))
+	respondWithError(w, http.StatusPreconditionFailed, "Medicine has changed since it was read")
+	return false
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(payload)
 }
 
-func validateMedicineInput(input models.MedicineInput) error {
+func validateMedicineInput(input *models.MedicineInput) error {
 	if input.Name == "" {
 		return fmt.Errorf("name is required")
 	}
+	if err := validateDosePhases(input); err != nil {
+		return err
+	}
 	if input.Dosage == "" {
 		return fmt.Errorf("dosage is required")
 	}
+	if _, err := dosage.Parse(input.Dosage); err != nil {
+		return err
+	}
 	if input.Frequency == "" {
 		return fmt.Errorf("frequency is required")
 	}
-	if len(input.TimeOfDay) == 0 {
+	for _, rt := range input.RelativeTimes {
+		if err := rt.Validate(); err != nil {
+			return err
+		}
+	}
+	if len(input.TimeOfDay) == 0 && len(input.RelativeTimes) == 0 && !input.AsNeeded && !phasesHaveTimes(input.Phases) {
 		return fmt.Errorf("time of day is required")
 	}
+	// Store times in canonical HH:MM form, sorted and without duplicates
+	times, err := models.ParseTimesOfDay(input.TimeOfDay)
+	if err != nil {
+		return err
+	}
+	input.TimeOfDay = times.Strings()
 	if input.StartDate.IsZero() {
 		return fmt.Errorf("start date is required")
 	}
+	if !input.EndDate.IsZero() && input.EndDate.Before(input.StartDate) {
+		return fmt.Errorf("end date must be after start date")
+	}
+	if input.ReviewIntervalDays == nil && input.EndDate.IsZero() {
+		days := models.DefaultReviewIntervalDays
+		input.ReviewIntervalDays = &days
+	}
+	if input.ReviewIntervalDays != nil && *input.ReviewIntervalDays < 1 {
+		return fmt.Errorf("review interval days must be at least 1")
+	}
+	if input.QuietHoursPolicy == "" {
+		input.QuietHoursPolicy = models.QuietHoursDefer
+	}
+	if err := reminders.ValidatePolicy(input.QuietHoursPolicy); err != nil {
+		return err
+	}
+	if input.ScheduleType == "" {
+		input.ScheduleType = models.ScheduleWallClock
+	}
+	if input.ScheduleType != models.ScheduleWallClock && input.ScheduleType != models.ScheduleFixedInterval {
+		return fmt.Errorf("schedule type must be %q or %q", models.ScheduleWallClock, models.ScheduleFixedInterval)
+	}
+	if input.UnitsPerDose == 0 {
+		input.UnitsPerDose = 1
+	}
+	if input.UnitsPerDose < 0 {
+		return fmt.Errorf("units per dose must be positive")
+	}
+	if input.StockQuantity != nil && *input.StockQuantity < 0 {
+		return fmt.Errorf("stock quantity must not be negative")
+	}
+	if input.RefillThresholdDays == nil {
+		threshold := models.DefaultRefillThresholdDays
+		input.RefillThresholdDays = &threshold
+	}
+	if *input.RefillThresholdDays < 0 {
+		return fmt.Errorf("refill threshold days must not be negative")
+	}
+	if input.MaxDosesPer24h != nil && *input.MaxDosesPer24h < 1 {
+		return fmt.Errorf("max doses per 24h must be at least 1")
+	}
+	if input.MinDoseIntervalMinutes != nil && *input.MinDoseIntervalMinutes < 1 {
+		return fmt.Errorf("min dose interval minutes must be at least 1")
+	}
+	if input.DrugID != nil {
+		if _, ok := catalog.Current().Get(*input.DrugID); !ok {
+			return fmt.Errorf("drug %q is not in the drug catalog", *input.DrugID)
+		}
+	}
+	if input.PatientID != nil {
+		exists, err := patientExists(*input.PatientID)
+		if err != nil {
+			return fmt.Errorf("error checking patient")
+		}
+		if !exists {
+			return fmt.Errorf("patient not found")
+		}
+	}
+	return nil
+}
+
+// validateDosePhases checks a tapering or titration schedule. Phases must be in
+// date order without overlapping, and the medicine's dosage and course dates
+// default to those of the phases.
+func validateDosePhases(input *models.MedicineInput) error {
+	if len(input.Phases) == 0 {
+		return nil
+	}
+
+	for i := range input.Phases {
+		p := &input.Phases[i]
+		if p.Dosage == "" {
+			return fmt.Errorf("phase %d: dosage is required", i+1)
+		}
+		if _, err := dosage.Parse(p.Dosage); err != nil {
+			return fmt.Errorf("phase %d: %v", i+1, err)
+		}
+		if p.StartDate.IsZero() || p.EndDate.IsZero() {
+			return fmt.Errorf("phase %d: start date and end date are required", i+1)
+		}
+		if p.EndDate.Before(p.StartDate) {
+			return fmt.Errorf("phase %d: end date must be after start date", i+1)
+		}
+		if i > 0 && !p.StartDate.After(input.Phases[i-1].EndDate) {
+			return fmt.Errorf("phase %d must start after phase %d ends", i+1, i)
+		}
+		times, err := models.ParseTimesOfDay(p.TimeOfDay)
+		if err != nil {
+			return fmt.Errorf("phase %d: %v", i+1, err)
+		}
+		p.TimeOfDay = times.Strings()
+	}
+
+	first, last := input.Phases[0], input.Phases[len(input.Phases)-1]
+	if input.Dosage == "" {
+		input.Dosage = first.Dosage
+	}
+	if input.StartDate.IsZero() {
+		input.StartDate = first.StartDate
+	}
 	if input.EndDate.IsZero() {
-		return fmt.Errorf("end date is required")
+		input.EndDate = last.EndDate
 	}
-	if input.EndDate.Before(input.StartDate) {
-		return fmt.Errorf("end date must be after start date")
+	if first.StartDate.Before(input.StartDate) || last.EndDate.After(input.EndDate) {
+		return fmt.Errorf("phases must fall between the start date and end date")
 	}
 	return nil
 }
+
+// endDate returns the course's end date, nil for an indefinite course
+func endDate(input models.MedicineInput) *time.Time {
+	if input.EndDate.IsZero() {
+		return nil
+	}
+	return &input.EndDate
+}
+
+// reviewBase returns when the review interval of a new course starts counting:
+// its start date, or now if it has already started
+func reviewBase(input models.MedicineInput, now time.Time) time.Time {
+	if input.StartDate.After(now) {
+		return input.StartDate
+	}
+	return now
+}
+
+// firstReviewAt returns when a new course is first due for review, nil if it
+// isn't reviewed
+func firstReviewAt(input models.MedicineInput, now time.Time) *time.Time {
+	if input.ReviewIntervalDays == nil {
+		return nil
+	}
+	at := reviewBase(input, now).AddDate(0, 0, *input.ReviewIntervalDays)
+	return &at
+}
+
+// phasesHaveTimes reports whether every phase sets its own times of day
+func phasesHaveTimes(phases []models.DosePhaseInput) bool {
+	for _, p := range phases {
+		if len(p.TimeOfDay) == 0 {
+			return false
+		}
+	}
+	return len(phases) > 0
+}
+
+// dosePhases converts validated phase input into stored phases
+func dosePhases(input []models.DosePhaseInput) models.DosePhases {
+	phases := make(models.DosePhases, 0, len(input))
+	for _, p := range input {
+		// Already validated, so the times always parse
+		times, _ := models.ParseTimesOfDay(p.TimeOfDay)
+		phases = append(phases, models.DosePhase{
+			StartDate: p.StartDate,
+			EndDate:   p.EndDate,
+			Dosage:    p.Dosage,
+			TimeOfDay: times,
+		})
+	}
+	return phases
+}
@@ -19,9 +19,11 @@ func setupTestDB(t *testing.T) {
 	// Initialize test database
 	database.InitDB()
 
-	// Clear the medicines table
+	// Clear the medicines and patients tables
 	_, err := database.DB.Exec("DELETE FROM medicines")
 	assert.NoError(t, err)
+	_, err = database.DB.Exec("DELETE FROM patients")
+	assert.NoError(t, err)
 }
 
 func TestGetMedicines(t *testing.T) {
@@ -45,14 +47,58 @@ func TestGetMedicines(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code)
 
 	// Parse response
-	var medicines []models.Medicine
-	err = json.Unmarshal(rr.Body.Bytes(), &medicines)
+	var page medicinePage
+	err = json.Unmarshal(rr.Body.Bytes(), &page)
 	assert.NoError(t, err)
 
 	// Verify response
-	assert.Equal(t, 1, len(medicines))
-	assert.Equal(t, medicine.Name, medicines[0].Name)
-	assert.Equal(t, medicine.Dosage, medicines[0].Dosage)
+	assert.Equal(t, 1, page.Total)
+	assert.Nil(t, page.NextCursor)
+	assert.Equal(t, 1, len(page.Data))
+	assert.Equal(t, medicine.Name, page.Data[0].Name)
+	assert.Equal(t, medicine.Dosage, page.Data[0].Dosage)
+}
+
+func TestGetMedicinesPagination(t *testing.T) {
+	setupTestDB(t)
+
+	createMultipleTestMedicines(t, 5)
+
+	// Walk the pages sorted by name
+	var names []string
+	cursor := ""
+	for pages := 0; pages < 3; pages++ {
+		url := "/api/medicines?sort=name&limit=2"
+		if cursor != "" {
+			url += "&cursor=" + cursor
+		}
+		page := getMedicinePage(t, url)
+		assert.Equal(t, 5, page.Total)
+		for _, m := range page.Data {
+			names = append(names, m.Name)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+	assert.Equal(t, []string{"Amoxicillin", "Aspirin", "Ibuprofen", "Metformin", "Paracetamol"}, names)
+
+	// Name filters ignore case
+	page := getMedicinePage(t, "/api/medicines?name=IN&sort=-name")
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, "Metformin", page.Data[0].Name)
+	assert.Equal(t, "Amoxicillin", page.Data[2].Name)
+
+	page = getMedicinePage(t, "/api/medicines?active_on="+time.Now().AddDate(0, 0, 60).Format("2006-01-02"))
+	assert.Equal(t, 0, page.Total)
+	assert.Empty(t, page.Data)
+
+	req, err := http.NewRequest("GET", "/api/medicines?sort=dosage", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetMedicines).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
 }
 
 func TestGetMedicine(t *testing.T) {
@@ -135,6 +181,184 @@ func TestCreateMedicine(t *testing.T) {
 	assert.NotZero(t, response.ID)
 }
 
+func TestCreateMedicineTimeOfDay(t *testing.T) {
+	setupTestDB(t)
+
+	input := models.MedicineInput{
+		Name:      "Test Medicine",
+		Dosage:    "100mg",
+		Frequency: "Twice daily",
+		TimeOfDay: []string{"20:00", "8:00", "08:00"},
+		StartDate: time.Now(),
+		EndDate:   time.Now().AddDate(0, 0, 7),
+	}
+
+	// Times are normalized, sorted and de-duplicated
+	rr := postMedicine(t, input)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"08:00", "20:00"}, response["time_of_day"])
+
+	// Invalid times are rejected
+	for _, invalid := range []string{"8am", "25:99"} {
+		input.TimeOfDay = []string{"08:00", invalid}
+		rr = postMedicine(t, input)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestCreateMedicineDosage(t *testing.T) {
+	setupTestDB(t)
+
+	input := models.MedicineInput{
+		Name:      "Salbutamol",
+		Dosage:    "2 puffs",
+		Frequency: "Twice daily",
+		TimeOfDay: []string{"08:00", "20:00"},
+		StartDate: time.Now(),
+		EndDate:   time.Now().AddDate(0, 0, 7),
+	}
+
+	// The structured dosage is returned alongside the original string
+	rr := postMedicine(t, input)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var response models.Medicine
+	err := json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "2 puffs", response.Dosage)
+	assert.Equal(t, 2.0, *response.DosageAmount)
+	assert.Equal(t, "puffs", response.DosageUnit)
+	assert.Equal(t, "inhaler", response.DosageForm)
+
+	// Unparseable dosages are rejected
+	input.Dosage = "a couple of puffs"
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCreateMedicinePhases(t *testing.T) {
+	setupTestDB(t)
+
+	start := time.Now().Truncate(24 * time.Hour)
+	input := models.MedicineInput{
+		Name:      "Prednisolone",
+		Frequency: "Once daily",
+		TimeOfDay: []string{"08:00"},
+		Phases: []models.DosePhaseInput{
+			{StartDate: start, EndDate: start.AddDate(0, 0, 2), Dosage: "40mg"},
+			{StartDate: start.AddDate(0, 0, 3), EndDate: start.AddDate(0, 0, 5), Dosage: "30mg"},
+		},
+	}
+
+	// Dosage and course dates default to those of the phases
+	rr := postMedicine(t, input)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var response models.Medicine
+	err := json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "40mg", response.Dosage)
+	assert.Len(t, response.Phases, 2)
+	assert.True(t, response.EndDate.Equal(start.AddDate(0, 0, 5)))
+
+	// Overlapping phases are rejected
+	input.Phases[1].StartDate = start.AddDate(0, 0, 1)
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPauseAndResumeMedicine(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+	assert.Equal(t, models.StatusActive, medicine.Status)
+
+	rr := postMedicineAction(t, PauseMedicine, medicine.ID, `{"reason": "Side effects"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var paused models.Medicine
+	err := json.Unmarshal(rr.Body.Bytes(), &paused)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusPaused, paused.Status)
+	assert.Equal(t, "Side effects", paused.PauseReason)
+
+	rr = postMedicineAction(t, PauseMedicine, medicine.ID, "")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Medicines can be filtered by status
+	page := getMedicinePage(t, "/api/medicines?status=active,upcoming")
+	assert.Equal(t, 0, page.Total)
+	assert.Empty(t, page.Data)
+
+	rr = postMedicineAction(t, ResumeMedicine, medicine.ID, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var resumed models.Medicine
+	err = json.Unmarshal(rr.Body.Bytes(), &resumed)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusActive, resumed.Status)
+	assert.Nil(t, resumed.PausedAt)
+
+	rr = postMedicineAction(t, ResumeMedicine, medicine.ID, "")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestIndefiniteMedicineReview(t *testing.T) {
+	setupTestDB(t)
+
+	input := models.MedicineInput{
+		Name:      "Atorvastatin",
+		Dosage:    "20mg",
+		Frequency: "Once daily",
+		TimeOfDay: []string{"21:00"},
+		StartDate: time.Now(),
+	}
+
+	// Without an end date the course is indefinite and reviewed every 180 days
+	rr := postMedicine(t, input)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var medicine models.Medicine
+	err := json.Unmarshal(rr.Body.Bytes(), &medicine)
+	assert.NoError(t, err)
+	assert.Nil(t, medicine.EndDate)
+	assert.Equal(t, models.StatusActive, medicine.Status)
+	assert.Equal(t, models.DefaultReviewIntervalDays, *medicine.ReviewIntervalDays)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 180), *medicine.NextReviewAt, time.Minute)
+
+	rr = postMedicineAction(t, ReviewMedicine, medicine.ID, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var reviewed models.Medicine
+	err = json.Unmarshal(rr.Body.Bytes(), &reviewed)
+	assert.NoError(t, err)
+	assert.NotNil(t, reviewed.LastReviewedAt)
+	assert.WithinDuration(t, reviewed.LastReviewedAt.AddDate(0, 0, 180), *reviewed.NextReviewAt, time.Second)
+
+	// Ending the course drops the default review
+	rr = patchMedicine(t, medicine.ID, "application/merge-patch+json",
+		fmt.Sprintf(`{"end_date": %q}`, time.Now().AddDate(0, 0, 30).Format(time.RFC3339)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var ended models.Medicine
+	err = json.Unmarshal(rr.Body.Bytes(), &ended)
+	assert.NoError(t, err)
+	assert.NotNil(t, ended.EndDate)
+	assert.Nil(t, ended.ReviewIntervalDays)
+	assert.Nil(t, ended.NextReviewAt)
+
+	// Fixed courses aren't reviewed unless asked to be
+	rr = postMedicineAction(t, ReviewMedicine, createTestMedicine(t).ID, "")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	input.ReviewIntervalDays = new(int)
+	rr = postMedicine(t, input)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
 func TestUpdateMedicine(t *testing.T) {
 	setupTestDB(t)
 
@@ -186,6 +410,144 @@ func TestUpdateMedicine(t *testing.T) {
 	assert.Equal(t, input.Name, response.Name)
 	assert.Equal(t, input.Dosage, response.Dosage)
 	assert.Equal(t, medicine.ID, response.ID)
+
+	// Stock only changes through inventory adjustments
+	input.StockQuantity = new(float64)
+	body, err = json.Marshal(input)
+	assert.NoError(t, err)
+	req, err = http.NewRequest("PUT", fmt.Sprintf("/api/medicines/%d", medicine.ID), bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, mux.SetURLVars(req, vars))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPatchMedicine(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+
+	// A merge patch changes only the fields it names
+	rr := patchMedicine(t, medicine.ID, "application/merge-patch+json", `{"notes": "Take with food", "end_date": null}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var patched models.Medicine
+	err := json.Unmarshal(rr.Body.Bytes(), &patched)
+	assert.NoError(t, err)
+	assert.Equal(t, "Take with food", patched.Notes)
+	assert.Equal(t, medicine.Name, patched.Name)
+	assert.Equal(t, medicine.TimeOfDay, patched.TimeOfDay)
+	assert.Nil(t, patched.EndDate)
+
+	rr = patchMedicine(t, medicine.ID, "application/json-patch+json", `[
+		{"op": "test", "path": "/dosage", "value": "100mg"},
+		{"op": "replace", "path": "/dosage", "value": "200mg"},
+		{"op": "add", "path": "/time_of_day/-", "value": "21:00"}
+	]`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	err = json.Unmarshal(rr.Body.Bytes(), &patched)
+	assert.NoError(t, err)
+	assert.Equal(t, "200mg", patched.Dosage)
+	assert.Len(t, patched.TimeOfDay, 2)
+
+	// A failed test means the medicine changed since it was read
+	rr = patchMedicine(t, medicine.ID, "application/json-patch+json", `[{"op": "test", "path": "/dosage", "value": "100mg"}]`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// The patched medicine must still be valid
+	rr = patchMedicine(t, medicine.ID, "application/merge-patch+json", `{"name": null}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Stock only changes through inventory adjustments
+	rr = patchMedicine(t, medicine.ID, "application/merge-patch+json", `{"stock_quantity": 30}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = patchMedicine(t, medicine.ID, "text/plain", `notes`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+
+	rr = patchMedicine(t, 0, "application/merge-patch+json", `{}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestMedicineETags(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+	etag := medicineETag(medicine)
+	vars := map[string]string{"id": fmt.Sprintf("%d", medicine.ID)}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/medicines/%d", medicine.ID), nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetMedicine).ServeHTTP(rr, mux.SetURLVars(req, vars))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	// Unchanged medicines aren't sent again
+	req.Header.Set("If-None-Match", "W/"+etag)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetMedicine).ServeHTTP(rr, mux.SetURLVars(req, vars))
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+
+	// Each change gives a new ETag
+	req, err = http.NewRequest("PATCH", "", bytes.NewBufferString(`{"notes": "Changed"}`))
+	assert.NoError(t, err)
+	req.Header.Set("If-Match", etag)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(PatchMedicine).ServeHTTP(rr, mux.SetURLVars(req, vars))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	newETag := rr.Header().Get("ETag")
+	assert.NotEqual(t, etag, newETag)
+
+	// Editing or deleting with the old ETag fails
+	req, err = http.NewRequest("PATCH", "", bytes.NewBufferString(`{"notes": "Overwritten"}`))
+	assert.NoError(t, err)
+	req.Header.Set("If-Match", etag)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(PatchMedicine).ServeHTTP(rr, mux.SetURLVars(req, vars))
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	req, err = http.NewRequest("DELETE", "", nil)
+	assert.NoError(t, err)
+	req.Header.Set("If-Match", etag)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(DeleteMedicine).ServeHTTP(rr, mux.SetURLVars(req, vars))
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	req.Header.Set("If-Match", newETag)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(DeleteMedicine).ServeHTTP(rr, mux.SetURLVars(req, vars))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+}
+
+func TestMedicineETagIgnoresStatus(t *testing.T) {
+	upcoming := models.Medicine{Version: 3, Status: models.StatusUpcoming}
+	active := models.Medicine{Version: 3, Status: models.StatusActive}
+	assert.Equal(t, `"3"`, medicineETag(upcoming))
+	assert.Equal(t, medicineETag(upcoming), medicineETag(active))
+}
+
+func TestETagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"1"`, `"1"`, false))
+	assert.True(t, etagMatches(`"0", "1"`, `"1"`, false))
+	assert.True(t, etagMatches("*", `"1"`, false))
+	assert.False(t, etagMatches(`"2"`, `"1"`, false))
+	assert.False(t, etagMatches(`W/"1"`, `"1"`, false))
+	assert.True(t, etagMatches(`W/"1"`, `"1"`, true))
+	assert.False(t, etagMatches("", `"1"`, true))
+}
+
+func patchMedicine(t *testing.T, medicineID int, contentType, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("PATCH", fmt.Sprintf("/api/medicines/%d", medicineID), bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicineID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(PatchMedicine).ServeHTTP(rr, req)
+	return rr
 }
 
 func TestDeleteMedicine(t *testing.T) {
@@ -214,9 +576,9 @@ func TestDeleteMedicine(t *testing.T) {
 	// Check status code
 	assert.Equal(t, http.StatusNoContent, rr.Code)
 
-	// Verify medicine is deleted
+	// Verify medicine is in the trash
 	var count int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM medicines WHERE id = $1", medicine.ID).Scan(&count)
+	err = database.DB.QueryRow("SELECT COUNT(*) FROM medicines WHERE id = $1 AND deleted_at IS NULL", medicine.ID).Scan(&count)
 	assert.NoError(t, err)
 	assert.Equal(t, 0, count)
 }
@@ -251,6 +613,45 @@ func TestCreateMultipleMedicines(t *testing.T) {
 	}
 }
 
+// Helper function to send a medicine to the create handler
+func postMedicine(t *testing.T, input models.MedicineInput) *httptest.ResponseRecorder {
+	body, err := json.Marshal(input)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", "/api/medicines", bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(CreateMedicine).ServeHTTP(rr, req)
+	return rr
+}
+
+// Helper function to call a POST /api/medicines/{id}/... action handler
+func postMedicineAction(t *testing.T, handler http.HandlerFunc, medicineID int, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", fmt.Sprintf("/api/medicines/%d", medicineID), bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicineID)})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func getMedicinePage(t *testing.T, url string) medicinePage {
+	req, err := http.NewRequest("GET", url, nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetMedicines).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var page medicinePage
+	err = json.Unmarshal(rr.Body.Bytes(), &page)
+	assert.NoError(t, err)
+	return page
+}
+
 // Helper function to create a test medicine
 func createTestMedicine(t *testing.T) models.Medicine {
 	input := models.MedicineInput{
@@ -266,11 +667,10 @@ func createTestMedicine(t *testing.T) models.Medicine {
 	timeOfDayJSON, err := json.Marshal(input.TimeOfDay)
 	assert.NoError(t, err)
 
-	var medicine models.Medicine
-	err = database.DB.QueryRow(`
+	medicine, err := database.ScanMedicine(database.DB.QueryRow(`
 		INSERT INTO medicines (name, dosage, frequency, time_of_day, start_date, end_date, notes, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING *`,
+		RETURNING `+database.MedicineColumns,
 		input.Name,
 		input.Dosage,
 		input.Frequency,
@@ -280,18 +680,7 @@ func createTestMedicine(t *testing.T) models.Medicine {
 		input.Notes,
 		time.Now(),
 		time.Now(),
-	).Scan(
-		&medicine.ID,
-		&medicine.Name,
-		&medicine.Dosage,
-		&medicine.Frequency,
-		&medicine.TimeOfDay,
-		&medicine.StartDate,
-		&medicine.EndDate,
-		&medicine.Notes,
-		&medicine.CreatedAt,
-		&medicine.UpdatedAt,
-	)
+	))
 	assert.NoError(t, err)
 
 	return medicine
@@ -367,10 +756,10 @@ func createMultipleTestMedicines(t *testing.T, count int) []models.Medicine {
 		timeOfDayJSON, err := json.Marshal(input.TimeOfDay)
 		assert.NoError(t, err)
 
-		err = database.DB.QueryRow(`
+		medicines[i], err = database.ScanMedicine(database.DB.QueryRow(`
 			INSERT INTO medicines (name, dosage, frequency, time_of_day, start_date, end_date, notes, created_at, updated_at)
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-			RETURNING *`,
+			RETURNING `+database.MedicineColumns,
 			input.Name,
 			input.Dosage,
 			input.Frequency,
@@ -380,18 +769,7 @@ func createMultipleTestMedicines(t *testing.T, count int) []models.Medicine {
 			input.Notes,
 			time.Now(),
 			time.Now(),
-		).Scan(
-			&medicines[i].ID,
-			&medicines[i].Name,
-			&medicines[i].Dosage,
-			&medicines[i].Frequency,
-			&medicines[i].TimeOfDay,
-			&medicines[i].StartDate,
-			&medicines[i].EndDate,
-			&medicines[i].Notes,
-			&medicines[i].CreatedAt,
-			&medicines[i].UpdatedAt,
-		)
+		))
 		assert.NoError(t, err)
 	}
 
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/models"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes for GET /api/medicines
+const (
+	defaultMedicinePageSize = 50
+	maxMedicinePageSize     = 200
+)
+
+// medicinePage is a page of medicines returned by GET /api/medicines
+type medicinePage struct {
+	Data       []models.Medicine `json:"data"`
+	NextCursor *string           `json:"next_cursor"` // Pass as ?cursor= for the next page, null on the last page
+	Total      int               `json:"total"`       // Medicines matching the filters across all pages
+}
+
+// medicineSortColumns maps the fields medicines can be sorted by to their SQL
+// expressions. Indefinite courses sort as ending after every other course.
+var medicineSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"start_date": "start_date",
+	"end_date":   "COALESCE(end_date, 'infinity')",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// medicineSortFields lists the sort fields in the order they are documented
+var medicineSortFields = []string{"id", "name", "start_date", "end_date", "created_at", "updated_at"}
+
+// medicineCursor marks the last medicine of a page. Pages are ordered by the
+// sort field and then by ID, so the next page starts after this pair.
+type medicineCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// encode returns the cursor as an opaque URL-safe string
+func (c medicineCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeMedicineCursor parses a cursor made by encode
+func decodeMedicineCursor(s string) (medicineCursor, error) {
+	var c medicineCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.Sort == "" {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// medicineSortValue returns the value of a medicine's sort field as the text
+// Postgres compares it with
+func medicineSortValue(m models.Medicine, field string) string {
+	switch field {
+	case "name":
+		return m.Name
+	case "start_date":
+		return m.StartDate.Format(time.RFC3339Nano)
+	case "end_date":
+		if m.EndDate == nil {
+			return "infinity"
+		}
+		return m.EndDate.Format(time.RFC3339Nano)
+	case "created_at":
+		return m.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return m.UpdatedAt.Format(time.RFC3339Nano)
+	default:
+		return strconv.Itoa(m.ID)
+	}
+}
+
+// medicineListQuery is a parsed GET /api/medicines request
+type medicineListQuery struct {
+	conditions []string      // SQL conditions ANDed together
+	args       []interface{} // Arguments of the conditions
+	sortField  string
+	descending bool
+	limit      int
+	cursor     *medicineCursor
+}
+
+// parseMedicineListQuery reads the filters, sort order and page of a
+// GET /api/medicines request
+func parseMedicineListQuery(values url.Values, now time.Time) (medicineListQuery, error) {
+	q := medicineListQuery{
+		conditions: []string{"deleted_at IS NULL"},
+		sortField:  "created_at",
+		descending: true,
+		limit:      defaultMedicinePageSize,
+	}
+	arg := func(v interface{}) string {
+		q.args = append(q.args, v)
+		return fmt.Sprintf("$%d", len(q.args))
+	}
+
+	// Status conditions expect the current time as $1
+	if s := values.Get("status"); s != "" {
+		arg(now)
+		var statuses []string
+		for _, status := range strings.Split(s, ",") {
+			condition, ok := statusConditions[strings.TrimSpace(status)]
+			if !ok {
+				return q, fmt.Errorf("status must be one of %s", strings.Join(models.MedicineStatuses, ", "))
+			}
+			statuses = append(statuses, condition)
+		}
+		q.conditions = append(q.conditions, "("+strings.Join(statuses, " OR ")+")")
+	}
+
+	if name := values.Get("name"); name != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(name)
+		q.conditions = append(q.conditions, "name ILIKE "+arg("%"+escaped+"%"))
+	}
+
+	if s := values.Get("patient_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return q, fmt.Errorf("patient_id must be a number")
+		}
+		q.conditions = append(q.conditions, "patient_id = "+arg(id))
+	}
+
+	if s := values.Get("active_on"); s != "" {
+		day, err := parseDateParam("active_on", s)
+		if err != nil {
+			return q, err
+		}
+		q.conditions = append(q.conditions, fmt.Sprintf(
+			"start_date < %s AND (end_date IS NULL OR end_date + INTERVAL '1 day' > %s)",
+			arg(day.AddDate(0, 0, 1)), arg(day)))
+	}
+
+	dateFilters := []struct{ param, condition string }{
+		{"start_from", "start_date >= "},
+		{"start_to", "start_date <= "},
+		{"end_from", "end_date >= "},
+		{"end_to", "end_date <= "},
+	}
+	for _, f := range dateFilters {
+		if s := values.Get(f.param); s != "" {
+			t, err := parseDateParam(f.param, s)
+			if err != nil {
+				return q, err
+			}
+			q.conditions = append(q.conditions, f.condition+arg(t))
+		}
+	}
+
+	if s := values.Get("sort"); s != "" {
+		q.descending = strings.HasPrefix(s, "-")
+		q.sortField = strings.TrimPrefix(s, "-")
+		if _, ok := medicineSortColumns[q.sortField]; !ok {
+			return q, fmt.Errorf("sort must be one of %s, optionally prefixed with - for descending order",
+				strings.Join(medicineSortFields, ", "))
+		}
+	}
+
+	if s := values.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxMedicinePageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxMedicinePageSize)
+		}
+		q.limit = n
+	}
+
+	if s := values.Get("cursor"); s != "" {
+		c, err := decodeMedicineCursor(s)
+		if err != nil {
+			return q, err
+		}
+		if c.Sort != q.sortSpec() {
+			return q, fmt.Errorf("cursor was made for sort %s", c.Sort)
+		}
+		q.cursor = &c
+	}
+	return q, nil
+}
+
+// sortSpec returns the sort as given in ?sort=
+func (q medicineListQuery) sortSpec() string {
+	if q.descending {
+		return "-" + q.sortField
+	}
+	return q.sortField
+}
+
+// where returns the WHERE clause of the filters, without the cursor
+func (q medicineListQuery) where() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// orderBy returns the ORDER BY clause of the sort, with ID breaking ties
+func (q medicineListQuery) orderBy() string {
+	column := medicineSortColumns[q.sortField]
+	if q.descending {
+		return fmt.Sprintf(" ORDER BY %s DESC, id DESC", column)
+	}
+	return fmt.Sprintf(" ORDER BY %s ASC, id ASC", column)
+}
+
+// pageSQL returns the clauses selecting one page after the filters, along with
+// all of the query's arguments. One extra row is fetched to tell whether there
+// is a next page.
+func (q medicineListQuery) pageSQL() (string, []interface{}) {
+	column := medicineSortColumns[q.sortField]
+	op := ">"
+	if q.descending {
+		op = "<"
+	}
+
+	conditions := q.conditions
+	args := q.args
+	if q.cursor != nil {
+		args = append(args[:len(args):len(args)], q.cursor.Value, q.cursor.ID)
+		value, id := fmt.Sprintf("$%d", len(args)-1), fmt.Sprintf("$%d", len(args))
+		conditions = append(conditions[:len(conditions):len(conditions)], fmt.Sprintf(
+			"(%s %s %s OR (%s = %s AND id %s %s))", column, op, value, column, value, op, id))
+	}
+
+	clause := ""
+	if len(conditions) > 0 {
+		clause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	clause += q.orderBy() + fmt.Sprintf(" LIMIT %d", q.limit+1)
+	return clause, args
+}
+
+// parseDateParam parses a query parameter given as a date or an RFC 3339 time
+func parseDateParam(name, s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 time", name)
+	}
+	return t, nil
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMedicineListQuery(t *testing.T) {
+	now := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	q, err := parseMedicineListQuery(url.Values{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "-created_at", q.sortSpec())
+	assert.Equal(t, defaultMedicinePageSize, q.limit)
+	assert.Equal(t, " WHERE deleted_at IS NULL", q.where())
+
+	// Status conditions come first so the current time is $1
+	values := url.Values{"name": {"50%"}, "status": {"active"}, "patient_id": {"4"}, "end_to": {"2024-04-01"}}
+	q, err = parseMedicineListQuery(values, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{now, `%50\%%`, 4, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}, q.args)
+	assert.Contains(t, q.where(), "name ILIKE $2 AND patient_id = $3 AND end_date <= $4")
+
+	for _, bad := range []url.Values{
+		{"sort": {"dosage"}},
+		{"limit": {"0"}},
+		{"limit": {"201"}},
+		{"status": {"finished"}},
+		{"active_on": {"yesterday"}},
+		{"patient_id": {"me"}},
+		{"cursor": {"not a cursor"}},
+	} {
+		_, err := parseMedicineListQuery(bad, now)
+		assert.Error(t, err, bad.Encode())
+	}
+}
+
+func TestMedicineListCursor(t *testing.T) {
+	now := time.Now()
+	cursor := medicineCursor{Sort: "-name", Value: "Aspirin", ID: 12}
+
+	q, err := parseMedicineListQuery(url.Values{"sort": {"-name"}, "limit": {"2"}, "cursor": {cursor.encode()}}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, *q.cursor)
+
+	clause, args := q.pageSQL()
+	assert.Equal(t, " WHERE deleted_at IS NULL AND (name < $1 OR (name = $1 AND id < $2)) ORDER BY name DESC, id DESC LIMIT 3", clause)
+	assert.Equal(t, []interface{}{"Aspirin", 12}, args)
+
+	// A cursor can only continue the sort it was made for
+	_, err = parseMedicineListQuery(url.Values{"sort": {"name"}, "cursor": {cursor.encode()}}, now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// patientColumns lists the patients columns in the order expected by scanPatient
+const patientColumns = "id, name, timezone, created_at, updated_at"
+
+// scanPatient reads a patient selected with patientColumns
+func scanPatient(s database.Scanner) (models.Patient, error) {
+	var p models.Patient
+	err := s.Scan(&p.ID, &p.Name, &p.Timezone, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// GetPatients handles GET /api/patients
+// Returns a list of all patients
+func GetPatients(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query("SELECT " + patientColumns + " FROM patients ORDER BY name")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	patients := []models.Patient{}
+	for rows.Next() {
+		p, err := scanPatient(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		patients = append(patients, p)
+	}
+
+	respondWithJSON(w, http.StatusOK, patients)
+}
+
+// GetPatient handles GET /api/patients/{pid}
+// Returns a specific patient by ID
+func GetPatient(w http.ResponseWriter, r *http.Request) {
+	pid := mux.Vars(r)["pid"]
+
+	patient, err := scanPatient(database.DB.QueryRow("SELECT "+patientColumns+" FROM patients WHERE id = $1", pid))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Patient not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, patient)
+}
+
+// CreatePatient handles POST /api/patients
+// Creates a new patient record
+func CreatePatient(w http.ResponseWriter, r *http.Request) {
+	var input models.PatientInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validatePatientInput(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	patient, err := scanPatient(database.DB.QueryRow(`
+		INSERT INTO patients (name, timezone, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+patientColumns,
+		input.Name, input.Timezone, time.Now(), time.Now(),
+	))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating patient")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, patient)
+}
+
+// UpdatePatient handles PUT /api/patients/{pid}
+// Updates an existing patient record
+func UpdatePatient(w http.ResponseWriter, r *http.Request) {
+	pid := mux.Vars(r)["pid"]
+
+	var input models.PatientInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validatePatientInput(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	patient, err := scanPatient(database.DB.QueryRow(`
+		UPDATE patients
+		SET name = $1, timezone = $2, updated_at = $3
+		WHERE id = $4
+		RETURNING `+patientColumns,
+		input.Name, input.Timezone, time.Now(), pid,
+	))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Patient not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, patient)
+}
+
+// GetQuietHours handles GET /api/patients/{pid}/quiet-hours
+// Returns the patient's quiet hours windows
+func GetQuietHours(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT start_time, end_time FROM quiet_hours WHERE patient_id = $1 ORDER BY start_time", pid)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	quietHours := []models.QuietHours{}
+	for rows.Next() {
+		var q models.QuietHours
+		if err := rows.Scan(&q.StartTime, &q.EndTime); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		quietHours = append(quietHours, q)
+	}
+
+	respondWithJSON(w, http.StatusOK, quietHours)
+}
+
+// UpdateQuietHours handles PUT /api/patients/{pid}/quiet-hours
+// Replaces the patient's quiet hours windows
+func UpdateQuietHours(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var input []models.QuietHours
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := reminders.ValidateQuietHours(input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Store bounds as zero-padded "HH:MM" so windows sort and compare as text
+	for i, q := range input {
+		start, _ := models.ParseClockTime(q.StartTime)
+		end, _ := models.ParseClockTime(q.EndTime)
+		input[i] = models.QuietHours{StartTime: start.String(), EndTime: end.String()}
+	}
+	sort.Slice(input, func(i, j int) bool { return input[i].StartTime < input[j].StartTime })
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM quiet_hours WHERE patient_id = $1", pid); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating quiet hours")
+		return
+	}
+	for _, q := range input {
+		_, err := tx.Exec("INSERT INTO quiet_hours (patient_id, start_time, end_time) VALUES ($1, $2, $3)",
+			pid, q.StartTime, q.EndTime)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating quiet hours")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating quiet hours")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, input)
+}
+
+// PreviewQuietHours handles GET /api/patients/{pid}/quiet-hours/preview
+// Returns the upcoming reminders that quiet hours would defer, suppress or override.
+// The look-ahead defaults to 24 hours and can be changed with ?hours=N.
+func PreviewQuietHours(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	hours := 24
+	if h := r.URL.Query().Get("hours"); h != "" {
+		parsed, err := strconv.Atoi(h)
+		if err != nil || parsed <= 0 || parsed > 24*31 {
+			respondWithError(w, http.StatusBadRequest, "hours must be between 1 and 744")
+			return
+		}
+		hours = parsed
+	}
+
+	now := time.Now()
+	upcoming, err := reminders.Upcoming(now, now.Add(time.Duration(hours)*time.Hour), pid)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error computing reminders")
+		return
+	}
+
+	affected := []reminders.Reminder{}
+	for _, rem := range upcoming {
+		if rem.QuietHoursAction != "" {
+			affected = append(affected, rem)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, affected)
+}
+
+// GetTravel handles GET /api/patients/{pid}/travel
+// Returns the patient's current travel plan
+func GetTravel(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var travel models.Travel
+	err := database.DB.QueryRow(
+		"SELECT timezone, start_at, end_at, max_shift_minutes FROM patient_travel WHERE patient_id = $1", pid,
+	).Scan(&travel.Timezone, &travel.StartAt, &travel.EndAt, &travel.MaxShiftMinutes)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Patient is not travelling")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, travel)
+}
+
+// UpdateTravel handles PUT /api/patients/{pid}/travel
+// Turns on travel mode, replacing any existing travel plan
+func UpdateTravel(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var input models.Travel
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if input.MaxShiftMinutes == 0 {
+		input.MaxShiftMinutes = reminders.DefaultMaxShiftMinutes
+	}
+	if err := reminders.ValidateTravel(input); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO patient_travel (patient_id, timezone, start_at, end_at, max_shift_minutes)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (patient_id) DO UPDATE
+		SET timezone = EXCLUDED.timezone, start_at = EXCLUDED.start_at,
+			end_at = EXCLUDED.end_at, max_shift_minutes = EXCLUDED.max_shift_minutes`,
+		pid, input.Timezone, input.StartAt, input.EndAt, input.MaxShiftMinutes)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating travel")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, input)
+}
+
+// DeleteTravel handles DELETE /api/patients/{pid}/travel
+// Turns off travel mode
+func DeleteTravel(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := database.DB.Exec("DELETE FROM patient_travel WHERE patient_id = $1", pid); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting travel")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAnchors handles GET /api/patients/{pid}/anchors
+// Returns when each of the patient's anchor events happens, using the default
+// time for events the patient hasn't set
+func GetAnchors(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := database.DB.Query("SELECT event, time FROM patient_anchors WHERE patient_id = $1", pid)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	anchors := models.Anchors{}
+	for rows.Next() {
+		var event, clockTime string
+		if err := rows.Scan(&event, &clockTime); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		t, err := models.ParseClockTime(clockTime)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		anchors[event] = t
+	}
+
+	respondWithJSON(w, http.StatusOK, anchors.WithDefaults())
+}
+
+// UpdateAnchors handles PUT /api/patients/{pid}/anchors
+// Replaces the times of the patient's anchor events, e.g. {"breakfast": "08:00"}.
+// Events left out go back to their default times.
+func UpdateAnchors(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var input map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	anchors := models.Anchors{}
+	for event, value := range input {
+		if err := models.ValidateAnchorEvent(event); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		t, err := models.ParseClockTime(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		anchors[event] = t
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM patient_anchors WHERE patient_id = $1", pid); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating anchors")
+		return
+	}
+	for event, t := range anchors {
+		_, err := tx.Exec("INSERT INTO patient_anchors (patient_id, event, time) VALUES ($1, $2, $3)",
+			pid, event, t.String())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating anchors")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating anchors")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, anchors.WithDefaults())
+}
+
+// patientFromRequest resolves the {pid} route variable to an existing patient ID.
+// It writes an error response and returns false when the patient does not exist.
+func patientFromRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	pid, err := strconv.Atoi(mux.Vars(r)["pid"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Patient not found")
+		return 0, false
+	}
+
+	exists, err := patientExists(pid)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return 0, false
+	}
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "Patient not found")
+		return 0, false
+	}
+	return pid, true
+}
+
+// patientExists reports whether a patient with the given ID exists
+func patientExists(id int) (bool, error) {
+	var found int
+	err := database.DB.QueryRow("SELECT 1 FROM patients WHERE id = $1", id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func validatePatientInput(input *models.PatientInput) error {
+	if input.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if input.Timezone == "" {
+		input.Timezone = "UTC"
+	}
+	return reminders.ValidateTimezone(input.Timezone)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePatient(t *testing.T) {
+	setupTestDB(t)
+
+	body, err := json.Marshal(models.PatientInput{Name: "Jane Doe"})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", "/api/patients", bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(CreatePatient).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var response models.Patient
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane Doe", response.Name)
+	assert.Equal(t, "UTC", response.Timezone)
+	assert.NotZero(t, response.ID)
+}
+
+func TestCreatePatientInvalidTimezone(t *testing.T) {
+	setupTestDB(t)
+
+	body, err := json.Marshal(models.PatientInput{Name: "Jane Doe", Timezone: "Mars/Olympus"})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("POST", "/api/patients", bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(CreatePatient).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestUpdateTravel(t *testing.T) {
+	setupTestDB(t)
+
+	patient := createTestPatient(t)
+
+	body, err := json.Marshal(models.Travel{Timezone: "Asia/Tokyo", StartAt: time.Now()})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("/api/patients/%d/travel", patient.ID), bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"pid": fmt.Sprintf("%d", patient.ID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(UpdateTravel).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// The default daily shift is filled in
+	var response models.Travel
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, reminders.DefaultMaxShiftMinutes, response.MaxShiftMinutes)
+
+	var timezone string
+	err = database.DB.QueryRow("SELECT timezone FROM patient_travel WHERE patient_id = $1", patient.ID).Scan(&timezone)
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", timezone)
+}
+
+func TestUpdateAnchors(t *testing.T) {
+	setupTestDB(t)
+
+	patient := createTestPatient(t)
+
+	body, err := json.Marshal(map[string]string{"breakfast": "09:15"})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("/api/patients/%d/anchors", patient.ID), bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"pid": fmt.Sprintf("%d", patient.ID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(UpdateAnchors).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Events that weren't set keep their defaults
+	var anchors map[string]string
+	err = json.Unmarshal(rr.Body.Bytes(), &anchors)
+	assert.NoError(t, err)
+	assert.Equal(t, "09:15", anchors["breakfast"])
+	assert.Equal(t, "22:00", anchors["bedtime"])
+
+	// Reminders resolve relative times with the patient's anchors
+	clock, err := reminders.LoadClock(&patient.ID)
+	assert.NoError(t, err)
+	m := models.Medicine{
+		StartDate:     time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC),
+		EndDate:       timePtr(time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)),
+		RelativeTimes: models.RelativeTimes{{Event: models.AnchorBreakfast, OffsetMinutes: -15}},
+	}
+	expanded, err := reminders.Expand(m, m.StartDate, m.EndDate.Add(24*time.Hour), clock)
+	assert.NoError(t, err)
+	assert.Len(t, expanded, 1)
+	assert.Equal(t, 9, expanded[0].ScheduledAt.Hour())
+}
+
+func TestUpdateQuietHours(t *testing.T) {
+	setupTestDB(t)
+
+	patient := createTestPatient(t)
+
+	// Invalid windows are rejected
+	rr := putQuietHours(t, patient.ID, []models.QuietHours{{StartTime: "22:00", EndTime: "7am"}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = putQuietHours(t, patient.ID, []models.QuietHours{{StartTime: "22:00", EndTime: "07:00"}})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Replacing the rules removes the previous ones
+	rr = putQuietHours(t, patient.ID, []models.QuietHours{{StartTime: "23:00", EndTime: "06:00"}})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM quiet_hours WHERE patient_id = $1", patient.ID).Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Times are stored and returned zero-padded, in start order
+	rr = putQuietHours(t, patient.ID, []models.QuietHours{{StartTime: "22:00", EndTime: "6:30"}, {StartTime: "8:00", EndTime: "9:00"}})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var saved []models.QuietHours
+	err = json.Unmarshal(rr.Body.Bytes(), &saved)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.QuietHours{{StartTime: "08:00", EndTime: "09:00"}, {StartTime: "22:00", EndTime: "06:30"}}, saved)
+
+	var start string
+	err = database.DB.QueryRow("SELECT start_time FROM quiet_hours WHERE patient_id = $1 ORDER BY start_time LIMIT 1", patient.ID).Scan(&start)
+	assert.NoError(t, err)
+	assert.Equal(t, "08:00", start)
+}
+
+func TestPreviewQuietHours(t *testing.T) {
+	setupTestDB(t)
+
+	patient := createTestPatient(t)
+	rr := putQuietHours(t, patient.ID, []models.QuietHours{{StartTime: "00:00", EndTime: "06:00"}})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// A misentered 03:00 dose should be deferred to the end of the window
+	_, err := database.DB.Exec(`
+		INSERT INTO medicines (name, dosage, frequency, time_of_day, start_date, end_date, notes, patient_id)
+		VALUES ('Aspirin', '100mg', 'Once daily', '["03:00", "12:00"]', $1, $2, '', $3)`,
+		time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 7), patient.ID)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/patients/%d/quiet-hours/preview?hours=48", patient.ID), nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"pid": fmt.Sprintf("%d", patient.ID)})
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(PreviewQuietHours).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var affected []reminders.Reminder
+	err = json.Unmarshal(rr.Body.Bytes(), &affected)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, affected)
+	for _, r := range affected {
+		assert.Equal(t, models.QuietHoursDefer, r.QuietHoursAction)
+		assert.Equal(t, 3, r.ScheduledAt.UTC().Hour())
+		assert.Equal(t, 6, r.DeliverAt.UTC().Hour())
+	}
+}
+
+// Helper function to create a test patient
+func createTestPatient(t *testing.T) models.Patient {
+	patient, err := scanPatient(database.DB.QueryRow(
+		"INSERT INTO patients (name) VALUES ($1) RETURNING "+patientColumns, "Test Patient",
+	))
+	assert.NoError(t, err)
+
+	return patient
+}
+
+// Helper function to replace a patient's quiet hours through the handler
+func putQuietHours(t *testing.T, patientID int, rules []models.QuietHours) *httptest.ResponseRecorder {
+	body, err := json.Marshal(rules)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("/api/patients/%d/quiet-hours", patientID), bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"pid": fmt.Sprintf("%d", patientID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(UpdateQuietHours).ServeHTTP(rr, req)
+	return rr
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// refillAlertColumns lists the refill_alerts columns in the order expected by scanRefillAlert
+const refillAlertColumns = "id, medicine_id, stock_quantity, run_out_at, raised_at, acknowledged_at"
+
+// scanRefillAlert reads a refill alert selected with refillAlertColumns
+func scanRefillAlert(s database.Scanner) (models.RefillAlert, error) {
+	var a models.RefillAlert
+	err := s.Scan(&a.ID, &a.MedicineID, &a.StockQuantity, &a.RunOutAt, &a.RaisedAt, &a.AcknowledgedAt)
+	return a, err
+}
+
+// GetRefillAlerts handles GET /api/refill-alerts
+// Returns the open refill alerts, or every alert with ?status=all
+func GetRefillAlerts(w http.ResponseWriter, r *http.Request) {
+	// Alerts of deleted medicines stay hidden until they are restored
+	query := "SELECT " + refillAlertColumns + `
+		FROM refill_alerts
+		WHERE medicine_id IN (SELECT id FROM medicines WHERE deleted_at IS NULL)`
+	switch r.URL.Query().Get("status") {
+	case "", "open":
+		query += " AND acknowledged_at IS NULL"
+	case "all":
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be open or all")
+		return
+	}
+
+	respondWithRefillAlerts(w, query+" ORDER BY run_out_at")
+}
+
+// GetMedicineRefillAlerts handles GET /api/medicines/{id}/refill-alerts
+// Returns every refill alert raised for a medicine, most recent first
+func GetMedicineRefillAlerts(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !findMedicine(w, id) {
+		return
+	}
+
+	respondWithRefillAlerts(w,
+		"SELECT "+refillAlertColumns+" FROM refill_alerts WHERE medicine_id = $1 ORDER BY raised_at DESC", id)
+}
+
+// AcknowledgeRefillAlert handles POST /api/medicines/{id}/refill-alerts/acknowledge
+// Closes the medicine's open refill alert. A positive quantity is added to the
+// stock as a refill in the same step. No new alert is raised until the stock
+// has been above the refill threshold again, even without a refill.
+func AcknowledgeRefillAlert(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	// The quantity is optional, so an empty body is allowed
+	var input models.RefillAcknowledgeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && err != io.EOF {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if input.Quantity < 0 {
+		respondWithError(w, http.StatusBadRequest, "quantity must not be negative")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	medicine, ok := lockMedicine(w, tx, id)
+	if !ok {
+		return
+	}
+
+	alert, err := scanRefillAlert(tx.QueryRow(`
+		UPDATE refill_alerts
+		SET acknowledged_at = $1
+		WHERE medicine_id = $2 AND acknowledged_at IS NULL
+		RETURNING `+refillAlertColumns,
+		time.Now(), medicine.ID,
+	))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No open refill alert")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error acknowledging refill alert")
+		return
+	}
+
+	if input.Quantity > 0 {
+		balance := input.Quantity
+		if medicine.StockQuantity != nil {
+			balance += *medicine.StockQuantity
+		}
+		if err := updateStock(tx, r, medicine, balance); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating stock")
+			return
+		}
+		if err := recordInventoryChange(tx, alert.MedicineID, nil, input.Quantity, balance, "refill"); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating stock")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error acknowledging refill alert")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, alert)
+}
+
+// respondWithRefillAlerts runs a refill alert query and writes the results
+func respondWithRefillAlerts(w http.ResponseWriter, query string, args ...interface{}) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	alerts := []models.RefillAlert{}
+	for rows.Next() {
+		a, err := scanRefillAlert(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		alerts = append(alerts, a)
+	}
+
+	respondWithJSON(w, http.StatusOK, alerts)
+}
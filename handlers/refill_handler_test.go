@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/inventory"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingNotifier collects notifications instead of sending them
+type recordingNotifier struct {
+	sent []reminders.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification reminders.Notification) error {
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func TestRefillAlertLifecycle(t *testing.T) {
+	setupTestDB(t)
+
+	// Two doses left of a daily medicine with a week's threshold
+	medicine := createTestMedicine(t)
+	_, err := database.DB.Exec("UPDATE medicines SET stock_quantity = 2 WHERE id = $1", medicine.ID)
+	assert.NoError(t, err)
+
+	notifier := &recordingNotifier{}
+	checker := inventory.NewRefillChecker(notifier, time.Hour)
+
+	// Repeated checks raise a single alert for the refill cycle
+	assert.NoError(t, checker.Check(context.Background(), time.Now()))
+	assert.NoError(t, checker.Check(context.Background(), time.Now()))
+	assert.Equal(t, 1, len(notifier.sent))
+	assert.Equal(t, reminders.NotificationRefill, notifier.sent[0].Kind)
+
+	// Acknowledging with the refill quantity closes the alert and adds the stock
+	body, err := json.Marshal(models.RefillAcknowledgeInput{Quantity: 30})
+	assert.NoError(t, err)
+	req, err := http.NewRequest("POST", fmt.Sprintf("/api/medicines/%d/refill-alerts/acknowledge", medicine.ID), bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicine.ID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(AcknowledgeRefillAlert).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var alert models.RefillAlert
+	err = json.Unmarshal(rr.Body.Bytes(), &alert)
+	assert.NoError(t, err)
+	assert.NotNil(t, alert.AcknowledgedAt)
+
+	var stock float64
+	err = database.DB.QueryRow("SELECT stock_quantity FROM medicines WHERE id = $1", medicine.ID).Scan(&stock)
+	assert.NoError(t, err)
+	assert.Equal(t, 32.0, stock)
+
+	// With enough stock no new alert is raised
+	assert.NoError(t, checker.Check(context.Background(), time.Now()))
+	assert.Equal(t, 1, len(notifier.sent))
+}
+
+func TestAcknowledgeRefillAlertWithoutRefill(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+	_, err := database.DB.Exec("UPDATE medicines SET stock_quantity = 2 WHERE id = $1", medicine.ID)
+	assert.NoError(t, err)
+
+	notifier := &recordingNotifier{}
+	checker := inventory.NewRefillChecker(notifier, time.Hour)
+	assert.NoError(t, checker.Check(context.Background(), time.Now()))
+	assert.Equal(t, 1, len(notifier.sent))
+
+	// The body is optional
+	req, err := http.NewRequest("POST", fmt.Sprintf("/api/medicines/%d/refill-alerts/acknowledge", medicine.ID), bytes.NewBuffer(nil))
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicine.ID)})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(AcknowledgeRefillAlert).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// The stock is still low, but the cycle's alert isn't raised again
+	assert.NoError(t, checker.Check(context.Background(), time.Now()))
+	assert.Equal(t, 1, len(notifier.sent))
+
+	// Once the stock has been above the threshold, the next shortage is a new cycle
+	_, err = database.DB.Exec("UPDATE medicines SET stock_quantity = 30 WHERE id = $1", medicine.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, checker.Check(context.Background(), time.Now()))
+	_, err = database.DB.Exec("UPDATE medicines SET stock_quantity = 2 WHERE id = $1", medicine.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, checker.Check(context.Background(), time.Now()))
+	assert.Equal(t, 2, len(notifier.sent))
+}
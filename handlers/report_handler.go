@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"medicine-reminder/report"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Limits of GET /api/patients/{pid}/report.pdf
+const (
+	defaultReportDays = 30
+	maxReportDays     = 366
+	reportMissedDoses = 20 // Most recent missed doses listed
+)
+
+// GetPatientReport handles GET /api/patients/{pid}/report.pdf
+// Returns a printable PDF of the patient's current medicines, their adherence
+// over ?from= to ?to= (the last 30 days by default) and their most recent
+// missed doses. A date-only to includes that day.
+func GetPatientReport(w http.ResponseWriter, r *http.Request) {
+	pid, ok := patientFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	clock, err := reminders.LoadClock(&pid)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error loading the patient's time zone")
+		return
+	}
+	from, to, err := reportPeriod(r, now, clock.Location(now))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	patient, err := scanPatient(database.DB.QueryRow("SELECT "+patientColumns+" FROM patients WHERE id = $1", pid))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT "+database.MedicineColumns+" FROM medicines WHERE patient_id = $1 AND deleted_at IS NULL ORDER BY name, id", pid)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+	var medicines []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		medicines = append(medicines, m)
+	}
+
+	doses, err := reportDoses(pid, from.Add(-report.MatchWindow), to.Add(report.MatchWindow))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	versions, err := reportVersions(pid, from, to)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	rep := report.Report{
+		Patient:     patient,
+		GeneratedAt: now,
+		Location:    clock.Location(now),
+		From:        from,
+		To:          to,
+	}
+	for _, m := range medicines {
+		if m.Status == models.StatusActive || m.Status == models.StatusPaused {
+			rep.Medicines = append(rep.Medicines, m)
+		}
+
+		scheduled, err := expandVersions(m, versions[m.ID], from, to, clock)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error computing reminders")
+			return
+		}
+		if len(scheduled) == 0 {
+			continue
+		}
+		adherence, missed := report.Match(m, scheduled, doses[m.ID])
+		rep.Adherence = append(rep.Adherence, adherence)
+		rep.Missed = append(rep.Missed, missed...)
+	}
+	sort.Slice(rep.Missed, func(i, j int) bool { return rep.Missed[i].ScheduledAt.After(rep.Missed[j].ScheduledAt) })
+	if len(rep.Missed) > reportMissedDoses {
+		rep.Missed = rep.Missed[:reportMissedDoses]
+	}
+
+	var out bytes.Buffer
+	if err := report.Render(&out, rep); err != nil {
+		log.Printf("Error rendering report for patient %d: %v", pid, err)
+		respondWithError(w, http.StatusInternalServerError, "Error rendering report")
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="medication-report-%d.pdf"`, pid))
+	w.WriteHeader(http.StatusOK)
+	out.WriteTo(w)
+}
+
+// medicineVersion is a medicine as recorded in the audit log, in effect from
+// the time of the change until the next one
+type medicineVersion struct {
+	from     time.Time
+	medicine models.Medicine
+}
+
+// reportVersions returns the versions of a patient's medicines in effect during
+// [from, to), oldest first and keyed by medicine ID: the last one recorded
+// before from and every one recorded after it
+func reportVersions(pid int, from, to time.Time) (map[int][]medicineVersion, error) {
+	rows, err := database.DB.Query(`
+		SELECT medicine_id, changed_at, snapshot FROM (
+			SELECT DISTINCT ON (medicine_id) id, medicine_id, changed_at, snapshot
+			FROM medicine_audit
+			WHERE medicine_id IN (SELECT id FROM medicines WHERE patient_id = $1) AND changed_at < $2
+			ORDER BY medicine_id, id DESC
+		) earlier
+		UNION ALL
+		SELECT medicine_id, changed_at, snapshot FROM (
+			SELECT id, medicine_id, changed_at, snapshot
+			FROM medicine_audit
+			WHERE medicine_id IN (SELECT id FROM medicines WHERE patient_id = $1) AND changed_at >= $2 AND changed_at < $3
+		) recorded
+		ORDER BY medicine_id, changed_at`,
+		pid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int][]medicineVersion)
+	for rows.Next() {
+		var id int
+		var v medicineVersion
+		var snapshot []byte
+		if err := rows.Scan(&id, &v.from, &snapshot); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(snapshot, &v.medicine); err != nil {
+			return nil, err
+		}
+		versions[id] = append(versions[id], v)
+	}
+	return versions, rows.Err()
+}
+
+// expandVersions returns the reminders of a medicine within [from, to) as it
+// was scheduled at the time, so earlier schedules and pauses since resumed
+// aren't counted as missed doses. The first version also covers the time
+// before it was recorded, and m, the medicine as it is now, is used when it
+// has no recorded versions.
+func expandVersions(m models.Medicine, versions []medicineVersion, from, to time.Time, clock reminders.Clock) ([]reminders.Reminder, error) {
+	if len(versions) == 0 {
+		return reminders.Expand(m, from, to, clock)
+	}
+
+	scheduled := []reminders.Reminder{}
+	for i, v := range versions {
+		start, end := from, to
+		if i > 0 && v.from.After(start) {
+			start = v.from
+		}
+		if i+1 < len(versions) && versions[i+1].from.Before(end) {
+			end = versions[i+1].from
+		}
+		expanded, err := reminders.Expand(v.medicine, start, end, clock)
+		if err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, expanded...)
+	}
+	return scheduled, nil
+}
+
+// reportPeriod reads the ?from= and ?to= period of a report. Dates without a
+// time are days in loc, the patient's time zone. The period ends no later than
+// now, since later doses can't have been missed yet.
+func reportPeriod(r *http.Request, now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	values := r.URL.Query()
+
+	to := now
+	if s := values.Get("to"); s != "" {
+		t, dateOnly, err := parseReportBound("to", s, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		if t.Before(to) {
+			to = t
+		}
+	}
+
+	from := to.AddDate(0, 0, -defaultReportDays)
+	if s := values.Get("from"); s != "" {
+		t, _, err := parseReportBound("from", s, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to and before now")
+	}
+	if to.Sub(from) > maxReportDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("the period can be at most %d days", maxReportDays)
+	}
+	return from, to, nil
+}
+
+// parseReportBound parses a date or time bounding a report, reporting whether
+// it was a date, which is taken as midnight in loc
+func parseReportBound(name, s string, loc *time.Location) (time.Time, bool, error) {
+	t, err := parseDateParam(name, s)
+	if err != nil {
+		return t, false, err
+	}
+	if len(s) != len("2006-01-02") {
+		return t, false, nil
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc), true, nil
+}
+
+// reportDoses returns the doses of a patient's medicines taken or scheduled
+// within [from, to), keyed by medicine ID
+func reportDoses(pid int, from, to time.Time) (map[int][]models.Dose, error) {
+	rows, err := database.DB.Query(`
+		SELECT `+doseColumns+` FROM doses
+		WHERE medicine_id IN (SELECT id FROM medicines WHERE patient_id = $1)
+		AND ((taken_at >= $2 AND taken_at < $3) OR (scheduled_at >= $2 AND scheduled_at < $3))`,
+		pid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	doses := make(map[int][]models.Dose)
+	for rows.Next() {
+		d, err := scanDose(rows)
+		if err != nil {
+			return nil, err
+		}
+		doses[d.MedicineID] = append(doses[d.MedicineID], d)
+	}
+	return doses, rows.Err()
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPatientReport(t *testing.T) {
+	setupTestDB(t)
+	patient := createTestPatient(t)
+
+	// A finished course with one of three doses taken
+	rr := postMedicine(t, models.MedicineInput{
+		Name:      "Amoxicillin",
+		Dosage:    "500mg",
+		Frequency: "Once daily",
+		TimeOfDay: []string{"09:00"},
+		StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		PatientID: &patient.ID,
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var finished models.Medicine
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &finished))
+	_, err := database.DB.Exec("INSERT INTO doses (medicine_id, status, scheduled_at, taken_at) VALUES ($1, $2, $3, $4)",
+		finished.ID, models.DoseTaken, time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 9, 20, 0, 0, time.UTC))
+	assert.NoError(t, err)
+
+	// A current medicine
+	rr = postMedicine(t, models.MedicineInput{
+		Name:      "Metformin",
+		Dosage:    "850mg",
+		Frequency: "Twice daily",
+		TimeOfDay: []string{"08:00", "20:00"},
+		StartDate: time.Now().AddDate(0, 0, -1),
+		Notes:     "With meals",
+		PatientID: &patient.ID,
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = getPatientReport(t, patient.ID, "?from=2024-03-01&to=2024-03-03")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+
+	pdf := rr.Body.String()
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-"))
+	for _, text := range []string{
+		"(Patient: Test Patient)",
+		"(Metformin)",
+		"(With meals)",
+		"(Adherence, 1 Mar 2024 to 3 Mar 2024)",
+		"(33%)",
+		"(Sun 3 Mar 2024 09:00)",
+		"(Fri 1 Mar 2024 09:00)",
+	} {
+		assert.Contains(t, pdf, text)
+	}
+	assert.NotContains(t, pdf, "(Sat 2 Mar 2024 09:00)")
+	// The finished course is only in the adherence summary
+	assert.Equal(t, 3, strings.Count(pdf, "(Amoxicillin)"))
+}
+
+func TestGetPatientReportInvalid(t *testing.T) {
+	setupTestDB(t)
+	patient := createTestPatient(t)
+
+	for _, query := range []string{"?from=yesterday", "?from=2024-03-05&to=2024-03-01", "?from=2020-01-01&to=2024-01-01"} {
+		rr := getPatientReport(t, patient.ID, query)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	rr := getPatientReport(t, patient.ID+1000, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestReportPeriodDatesInPatientZone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	req, err := http.NewRequest("GET", "/api/patients/1/report.pdf?from=2024-03-01&to=2024-03-03", nil)
+	assert.NoError(t, err)
+	from, to, err := reportPeriod(req, now, loc)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, loc), from)
+	assert.Equal(t, time.Date(2024, 3, 4, 0, 0, 0, 0, loc), to)
+
+	// Times keep their own offset
+	req, err = http.NewRequest("GET", "/api/patients/1/report.pdf?from=2024-03-01T00:00:00Z", nil)
+	assert.NoError(t, err)
+	from, to, err = reportPeriod(req, now, loc)
+	assert.NoError(t, err)
+	assert.True(t, from.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, now, to)
+}
+
+func TestExpandVersions(t *testing.T) {
+	clock, err := reminders.NewClock("UTC", nil)
+	assert.NoError(t, err)
+	morning, err := models.ParseTimesOfDay([]string{"09:00"})
+	assert.NoError(t, err)
+	twice, err := models.ParseTimesOfDay([]string{"09:00", "21:00"})
+	assert.NoError(t, err)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	// Once daily from 1 Mar, paused on the 3rd, resumed twice daily on the 5th
+	created := models.Medicine{ID: 1, TimeOfDay: morning, StartDate: day(1)}
+	paused := created
+	paused.PausedAt = timePtr(day(3))
+	resumed := created
+	resumed.TimeOfDay = twice
+	versions := []medicineVersion{{day(1), created}, {day(3), paused}, {day(5).Add(12 * time.Hour), resumed}}
+
+	scheduled, err := expandVersions(resumed, versions, day(1), day(7), clock)
+	assert.NoError(t, err)
+	var times []string
+	for _, r := range scheduled {
+		times = append(times, r.ScheduledAt.Format("2 15:04"))
+	}
+	assert.Equal(t, []string{"1 09:00", "2 09:00", "5 21:00", "6 09:00", "6 21:00"}, times)
+
+	// Without recorded versions the current schedule is used
+	scheduled, err = expandVersions(resumed, nil, day(1), day(2), clock)
+	assert.NoError(t, err)
+	assert.Len(t, scheduled, 2)
+}
+
+func getPatientReport(t *testing.T, patientID int, query string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/patients/%d/report.pdf%s", patientID, query), nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"pid": fmt.Sprintf("%d", patientID)})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetPatientReport).ServeHTTP(rr, req)
+	return rr
+}
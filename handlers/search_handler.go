@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"database/sql"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/search"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultMedicineSearchLimit is how many medicines a search returns unless ?limit= says otherwise
+const defaultMedicineSearchLimit = 20
+
+// headlineOptions makes ts_headline mark matches like the in-memory search
+const headlineOptions = "StartSel=" + search.HighlightStart + ", StopSel=" + search.HighlightEnd +
+	", MaxWords=35, MinWords=15"
+
+// SearchMedicines handles GET /api/medicines/search?q=
+// Returns the medicines whose name, dosage or notes match a web-style search
+// (e.g. `crushed "after meals" -liquid`), best match first, with the matching
+// words of each field highlighted. Results can be limited to a patient with
+// ?patient_id= and their number with ?limit=N (20 by default).
+func SearchMedicines(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if strings.TrimSpace(q) == "" {
+		respondWithError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	limit := defaultMedicineSearchLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	var patientID *int
+	if s := r.URL.Query().Get("patient_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "patient_id must be a number")
+			return
+		}
+		patientID = &id
+	}
+
+	var results []search.Result
+	var err error
+	if database.FullTextSearch {
+		results, err = fullTextSearch(q, patientID, limit)
+	} else {
+		results, err = memorySearch(q, patientID, limit)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, results)
+}
+
+// fullTextSearch searches medicines with Postgres full-text search
+func fullTextSearch(q string, patientID *int, limit int) ([]search.Result, error) {
+	query := "SELECT " + database.MedicineColumns + `,
+			ts_rank(search_vector, query),
+			CASE WHEN to_tsvector('english', name) @@ query THEN ts_headline('english', name, query, $2) END,
+			CASE WHEN to_tsvector('english', dosage) @@ query THEN ts_headline('english', dosage, query, $2) END,
+			CASE WHEN to_tsvector('english', coalesce(notes, '')) @@ query
+				THEN ts_headline('english', coalesce(notes, ''), query, $2) END
+		FROM medicines, websearch_to_tsquery('english', $1) AS query
+		WHERE search_vector @@ query AND deleted_at IS NULL`
+	args := []interface{}{q, headlineOptions}
+	if patientID != nil {
+		query += " AND patient_id = $3"
+		args = append(args, *patientID)
+	}
+	query += " ORDER BY ts_rank(search_vector, query) DESC, id LIMIT " + strconv.Itoa(limit)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []search.Result{}
+	for rows.Next() {
+		var rank float64
+		var name, dosage, notes sql.NullString
+		m, err := database.ScanMedicine(extraScanner{rows, []interface{}{&rank, &name, &dosage, &notes}})
+		if err != nil {
+			return nil, err
+		}
+
+		result := search.Result{Medicine: m, Rank: rank, Highlights: map[string]string{}}
+		for field, headline := range map[string]sql.NullString{"name": name, "dosage": dosage, "notes": notes} {
+			// Excluded words match without marking anything
+			if strings.Contains(headline.String, search.HighlightStart) {
+				result.Highlights[field] = headline.String
+			}
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// memorySearch searches medicines in memory for stores without full-text search
+func memorySearch(q string, patientID *int, limit int) ([]search.Result, error) {
+	query := "SELECT " + database.MedicineColumns + " FROM medicines WHERE deleted_at IS NULL"
+	var args []interface{}
+	if patientID != nil {
+		query += " AND patient_id = $1"
+		args = append(args, *patientID)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var medicines []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			return nil, err
+		}
+		medicines = append(medicines, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return search.Search(medicines, search.Parse(q), limit), nil
+}
+
+// extraScanner scans a row holding a medicine followed by extra columns
+type extraScanner struct {
+	scanner database.Scanner
+	extra   []interface{}
+}
+
+// Scan reads the medicine's columns into dest and the rest into the extra destinations
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.scanner.Scan(append(dest, s.extra...)...)
+}
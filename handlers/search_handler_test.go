@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/search"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchMedicines(t *testing.T) {
+	setupTestDB(t)
+
+	for _, m := range []struct{ name, notes string }{
+		{"Metformin", "Take with meals"},
+		{"Paracetamol", "May be crushed and mixed with food"},
+		{"Omeprazole", "Take before breakfast, do not crush"},
+	} {
+		rr := postMedicine(t, models.MedicineInput{
+			Name:      m.name,
+			Dosage:    "500mg",
+			Frequency: "Once daily",
+			TimeOfDay: []string{"08:00"},
+			StartDate: time.Now(),
+			EndDate:   time.Now().AddDate(0, 0, 7),
+			Notes:     m.notes,
+		})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	// Postgres and the in-memory fallback find the same medicines
+	fullTextSearch := database.FullTextSearch
+	defer func() { database.FullTextSearch = fullTextSearch }()
+	for _, fullText := range []bool{true, false} {
+		database.FullTextSearch = fullText
+
+		results := searchMedicines(t, "after meals")
+		assert.Len(t, results, 1)
+		assert.Equal(t, "Metformin", results[0].Name)
+		assert.Equal(t, "Take with <mark>meals</mark>", results[0].Highlights["notes"])
+
+		results = searchMedicines(t, "crushed -food")
+		assert.Len(t, results, 1)
+		assert.Equal(t, "Omeprazole", results[0].Name)
+	}
+}
+
+func TestSearchMedicinesRequiresQuery(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/medicines/search?q=+", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SearchMedicines).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func searchMedicines(t *testing.T, q string) []search.Result {
+	req, err := http.NewRequest("GET", "/api/medicines/search?q="+url.QueryEscape(q), nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(SearchMedicines).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var results []search.Result
+	err = json.Unmarshal(rr.Body.Bytes(), &results)
+	assert.NoError(t, err)
+	return results
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/trash"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// trashedMedicine is a deleted medicine along with when it will be purged
+type trashedMedicine struct {
+	models.Medicine
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// GetTrash handles GET /api/medicines/trash
+// Returns the deleted medicines that can still be restored, most recently
+// deleted first, optionally limited to a patient with ?patient_id=
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	query := "SELECT " + database.MedicineColumns + " FROM medicines WHERE deleted_at IS NOT NULL"
+	var args []interface{}
+	if s := r.URL.Query().Get("patient_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "patient_id must be a number")
+			return
+		}
+		query += " AND patient_id = $1"
+		args = append(args, id)
+	}
+	query += " ORDER BY deleted_at DESC, id DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	medicines := []trashedMedicine{}
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error scanning database result")
+			return
+		}
+		medicines = append(medicines, trashedMedicine{Medicine: m, PurgeAt: trash.PurgeAt(*m.DeletedAt)})
+	}
+
+	respondWithJSON(w, http.StatusOK, medicines)
+}
+
+// RestoreMedicine handles POST /api/medicines/{id}/restore
+// Takes a deleted medicine out of the trash. With If-Match, the medicine is
+// only restored if it still has that ETag.
+func RestoreMedicine(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	medicine, err := database.ScanMedicine(tx.QueryRow(
+		"SELECT "+database.MedicineColumns+" FROM medicines WHERE id = $1 FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Medicine not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if medicine.DeletedAt == nil {
+		respondWithError(w, http.StatusConflict, "Medicine is not deleted")
+		return
+	}
+	if !checkIfMatch(w, r, medicine) {
+		return
+	}
+
+	restored, err := database.ScanMedicine(tx.QueryRow(`
+		UPDATE medicines
+		SET deleted_at = NULL, updated_at = $1, version = version + 1
+		WHERE id = $2
+		RETURNING `+database.MedicineColumns,
+		time.Now(), medicine.ID,
+	))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring medicine")
+		return
+	}
+	if err := recordMedicineAudit(tx, r, models.AuditRestore, &medicine, restored); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring medicine")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring medicine")
+		return
+	}
+
+	w.Header().Set("ETag", medicineETag(restored))
+	respondWithJSON(w, http.StatusOK, restored)
+}
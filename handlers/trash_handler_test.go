@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/trash"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAndRestoreMedicine(t *testing.T) {
+	setupTestDB(t)
+
+	medicine := createTestMedicine(t)
+
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("/api/medicines/%d", medicine.ID), nil)
+	assert.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprintf("%d", medicine.ID)})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(DeleteMedicine).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Deleted medicines are hidden everywhere but the trash
+	assert.Equal(t, 0, getMedicinePage(t, "/api/medicines").Total)
+	rr = postMedicineAction(t, PauseMedicine, medicine.ID, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	trashed := getTrash(t)
+	assert.Len(t, trashed, 1)
+	assert.Equal(t, medicine.ID, trashed[0].ID)
+	assert.NotNil(t, trashed[0].DeletedAt)
+	assert.Equal(t, trashed[0].DeletedAt.Add(trash.Retention), trashed[0].PurgeAt)
+
+	rr = postMedicineAction(t, RestoreMedicine, medicine.ID, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var restored models.Medicine
+	err = json.Unmarshal(rr.Body.Bytes(), &restored)
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, medicine.Version+2, restored.Version)
+	assert.Equal(t, 1, getMedicinePage(t, "/api/medicines").Total)
+	assert.Empty(t, getTrash(t))
+
+	rr = postMedicineAction(t, RestoreMedicine, medicine.ID, "")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = postMedicineAction(t, RestoreMedicine, 999999, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPurgeDeletedMedicines(t *testing.T) {
+	setupTestDB(t)
+
+	kept := createTestMedicine(t)
+	expired := createTestMedicine(t)
+	_, err := database.DB.Exec("UPDATE medicines SET deleted_at = NOW() - INTERVAL '31 days' WHERE id = $1", expired.ID)
+	assert.NoError(t, err)
+
+	n, err := trash.NewPurger(time.Hour).Purge(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	var count int
+	err = database.DB.QueryRow("SELECT COUNT(*) FROM medicines WHERE id IN ($1, $2)", kept.ID, expired.ID).Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func getTrash(t *testing.T) []trashedMedicine {
+	req, err := http.NewRequest("GET", "/api/medicines/trash", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetTrash).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var trashed []trashedMedicine
+	err = json.Unmarshal(rr.Body.Bytes(), &trashed)
+	assert.NoError(t, err)
+	return trashed
+}
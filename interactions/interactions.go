@@ -0,0 +1,165 @@
+// Package interactions checks medicines against a local drug–drug interaction
+// dataset. The dataset is a JSON file that can be replaced and reloaded without
+// rebuilding the server.
+package interactions
+
+import (
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/catalog"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// Severity is how serious an interaction is
+type Severity string
+
+// Supported severities, from least to most serious
+const (
+	Minor    Severity = "minor"
+	Moderate Severity = "moderate"
+	Severe   Severity = "severe"
+)
+
+// rank orders severities so the most serious can be reported first
+var rank = map[Severity]int{Minor: 1, Moderate: 2, Severe: 3}
+
+// Rank orders severities from least (1) to most (3) serious, giving 0 for
+// unknown severities
+func Rank(s Severity) int {
+	return rank[s]
+}
+
+// Interaction is a known interaction between two drugs
+type Interaction struct {
+	Drugs       [2]string `json:"drugs"` // Generic drug names
+	Severity    Severity  `json:"severity"`
+	Description string    `json:"description"`
+}
+
+// Dataset is a set of interactions along with brand names of the drugs involved
+type Dataset struct {
+	Version      string            `json:"version"`
+	Aliases      map[string]string `json:"aliases"` // Brand or alternative name to generic name
+	Interactions []Interaction     `json:"interactions"`
+}
+
+// Load reads and validates a dataset from a JSON file
+func Load(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var d Dataset
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := d.normalize(); err != nil {
+		return nil, fmt.Errorf("invalid interaction dataset %s: %v", path, err)
+	}
+	return &d, nil
+}
+
+// normalize validates the dataset and lower-cases its drug names
+func (d *Dataset) normalize() error {
+	aliases := make(map[string]string, len(d.Aliases))
+	for alias, generic := range d.Aliases {
+		aliases[catalog.NormalizeName(alias)] = catalog.NormalizeName(generic)
+	}
+	d.Aliases = aliases
+
+	for i, in := range d.Interactions {
+		for j, drug := range in.Drugs {
+			if catalog.NormalizeName(drug) == "" {
+				return fmt.Errorf("interaction %d is missing a drug name", i)
+			}
+			d.Interactions[i].Drugs[j] = catalog.NormalizeName(drug)
+		}
+		if _, ok := rank[in.Severity]; !ok {
+			return fmt.Errorf("interaction %d has an unknown severity %q", i, in.Severity)
+		}
+	}
+	return nil
+}
+
+// current is the dataset used by Current
+var current atomic.Pointer[Dataset]
+
+// Current returns the dataset in use, which is empty until one is loaded
+func Current() *Dataset {
+	if d := current.Load(); d != nil {
+		return d
+	}
+	return &Dataset{}
+}
+
+// SetCurrent replaces the dataset in use
+func SetCurrent(d *Dataset) {
+	current.Store(d)
+}
+
+// LoadCurrent loads a dataset from a JSON file and puts it in use. The dataset
+// in use is kept when the file can't be loaded.
+func LoadCurrent(path string) error {
+	d, err := Load(path)
+	if err != nil {
+		return err
+	}
+	SetCurrent(d)
+	return nil
+}
+
+// Drugs returns the generic names of the dataset's drugs mentioned in a
+// medicine name, e.g. "Coumadin 5mg" gives "warfarin"
+func (d *Dataset) Drugs(name string) []string {
+	found := map[string]bool{}
+
+	for alias, generic := range d.Aliases {
+		if catalog.Mentions(name, alias) {
+			found[generic] = true
+		}
+	}
+	for _, in := range d.Interactions {
+		for _, drug := range in.Drugs {
+			if catalog.Mentions(name, drug) {
+				found[drug] = true
+			}
+		}
+	}
+
+	drugs := make([]string, 0, len(found))
+	for drug := range found {
+		drugs = append(drugs, drug)
+	}
+	sort.Strings(drugs)
+	return drugs
+}
+
+// Between returns the interactions between two medicines, identified by name,
+// most serious first
+func (d *Dataset) Between(a, b string) []Interaction {
+	drugsA, drugsB := d.Drugs(a), d.Drugs(b)
+
+	var found []Interaction
+	for _, in := range d.Interactions {
+		if (contains(drugsA, in.Drugs[0]) && contains(drugsB, in.Drugs[1])) ||
+			(contains(drugsA, in.Drugs[1]) && contains(drugsB, in.Drugs[0])) {
+			found = append(found, in)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return rank[found[i].Severity] > rank[found[j].Severity]
+	})
+	return found
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package interactions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTestDataset(t *testing.T) *Dataset {
+	d, err := Load(filepath.Join("testdata", "interactions.json"))
+	assert.NoError(t, err)
+	return d
+}
+
+func TestDrugs(t *testing.T) {
+	d := loadTestDataset(t)
+
+	assert.Equal(t, []string{"warfarin"}, d.Drugs("Coumadin 5mg"))
+	assert.Equal(t, []string{"aspirin"}, d.Drugs("Aspirin (low dose)"))
+	assert.Equal(t, []string{"calcium carbonate"}, d.Drugs("Calcium Carbonate 500mg"))
+	assert.Empty(t, d.Drugs("Calcium"))
+	assert.Empty(t, d.Drugs("Paracetamol"))
+}
+
+func TestBetween(t *testing.T) {
+	d := loadTestDataset(t)
+
+	found := d.Between("Coumadin", "aspirin 81mg")
+	assert.Len(t, found, 1)
+	assert.Equal(t, Severe, found[0].Severity)
+	assert.Equal(t, [2]string{"warfarin", "aspirin"}, found[0].Drugs)
+
+	// Order of the medicines doesn't matter
+	assert.Equal(t, found, d.Between("aspirin 81mg", "Coumadin"))
+
+	assert.Empty(t, d.Between("Paracetamol", "Aspirin"))
+}
+
+func TestLoadInvalidSeverity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interactions.json")
+	err := os.WriteFile(path, []byte(`{"interactions": [{"drugs": ["a", "b"], "severity": "fatal"}]}`), 0o644)
+	assert.NoError(t, err)
+
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestLoadCurrentKeepsDatasetOnError(t *testing.T) {
+	d := loadTestDataset(t)
+	SetCurrent(d)
+
+	err := LoadCurrent(filepath.Join("testdata", "missing.json"))
+	assert.Error(t, err)
+	assert.Same(t, d, Current())
+}
+
+func TestBundledDataset(t *testing.T) {
+	d, err := Load(filepath.Join("..", "data", "interactions.json"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, d.Between("Coumadin", "Advil"))
+}
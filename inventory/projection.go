@@ -0,0 +1,84 @@
+// Package inventory projects a medicine's supply against its dose schedule
+package inventory
+
+import (
+	"math"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"time"
+)
+
+// Horizon is how far ahead run-out dates are projected
+const Horizon = 366 * 24 * time.Hour
+
+// DailyUsage returns the units used per day on the medicine's schedule at the
+// given time. Medicines with dose phases use the phase in effect then, or the
+// next one to start, since they have no doses outside their phases.
+func DailyUsage(m models.Medicine, at time.Time, loc *time.Location) float64 {
+	doses := len(m.TimeOfDay) + len(m.RelativeTimes)
+	if len(m.Phases) > 0 {
+		p, ok := m.PhaseOn(at, loc)
+		if !ok {
+			if p, ok = nextPhase(m, at); !ok {
+				return 0
+			}
+		}
+		if len(p.TimeOfDay) > 0 {
+			doses = len(p.TimeOfDay)
+		}
+	}
+	return float64(doses) * m.UnitsPerDose
+}
+
+// nextPhase returns the first of the medicine's phases to start after at
+func nextPhase(m models.Medicine, at time.Time) (models.DosePhase, bool) {
+	var next models.DosePhase
+	found := false
+	for _, p := range m.Phases {
+		if p.StartDate.After(at) && (!found || p.StartDate.Before(next.StartDate)) {
+			next, found = p, true
+		}
+	}
+	return next, found
+}
+
+// DaysOfSupply returns how many days the current stock lasts at the usage of
+// the given time, or nil when stock isn't tracked or the schedule uses nothing
+func DaysOfSupply(m models.Medicine, at time.Time, loc *time.Location) *float64 {
+	usage := DailyUsage(m, at, loc)
+	if m.StockQuantity == nil || usage == 0 {
+		return nil
+	}
+	days := *m.StockQuantity / usage
+	return &days
+}
+
+// RemainingDoses returns how many whole doses the current stock covers
+func RemainingDoses(m models.Medicine) int {
+	if m.StockQuantity == nil || m.UnitsPerDose <= 0 {
+		return 0
+	}
+	// Allow for NUMERIC rounding when stock is an exact multiple of the dose
+	return int(math.Floor(*m.StockQuantity/m.UnitsPerDose + 1e-9))
+}
+
+// RunOutAt returns the first dose scheduled after now that the stock can't cover.
+// It returns nil when stock isn't tracked or lasts until the course ends or
+// beyond the projection horizon.
+func RunOutAt(m models.Medicine, now time.Time, clock reminders.Clock) (*time.Time, error) {
+	if m.StockQuantity == nil {
+		return nil, nil
+	}
+
+	upcoming, err := reminders.Expand(m, now, now.Add(Horizon), clock)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := RemainingDoses(m)
+	if remaining >= len(upcoming) {
+		return nil, nil
+	}
+	runOut := upcoming[remaining].ScheduledAt
+	return &runOut, nil
+}
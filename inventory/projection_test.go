@@ -0,0 +1,88 @@
+package inventory
+
+import (
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testMedicine(t *testing.T, stock float64, unitsPerDose float64, times ...string) models.Medicine {
+	timesOfDay, err := models.ParseTimesOfDay(times)
+	assert.NoError(t, err)
+
+	return models.Medicine{
+		ID:            1,
+		TimeOfDay:     timesOfDay,
+		StartDate:     time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       timePtr(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)),
+		StockQuantity: &stock,
+		UnitsPerDose:  unitsPerDose,
+	}
+}
+
+func utcClock(t *testing.T) reminders.Clock {
+	clock, err := reminders.NewClock("UTC", nil)
+	assert.NoError(t, err)
+	return clock
+}
+
+func TestDaysOfSupply(t *testing.T) {
+	m := testMedicine(t, 30, 2, "08:00", "20:00")
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 4.0, DailyUsage(m, now, time.UTC))
+	assert.Equal(t, 7.5, *DaysOfSupply(m, now, time.UTC))
+	assert.Equal(t, 15, RemainingDoses(m))
+
+	m.StockQuantity = nil
+	assert.Nil(t, DaysOfSupply(m, now, time.UTC))
+}
+
+func TestDailyUsageWithPhases(t *testing.T) {
+	m := testMedicine(t, 30, 1, "08:00", "20:00")
+	morning, err := models.ParseTimesOfDay([]string{"08:00"})
+	assert.NoError(t, err)
+	m.Phases = models.DosePhases{
+		{StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC), Dosage: "20mg"},
+		{StartDate: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), Dosage: "10mg", TimeOfDay: morning},
+	}
+
+	// Phases without their own times use the medicine's
+	assert.Equal(t, 2.0, DailyUsage(m, time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC), time.UTC))
+	assert.Equal(t, 1.0, DailyUsage(m, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), time.UTC))
+	assert.Equal(t, 30.0, *DaysOfSupply(m, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), time.UTC))
+
+	// Before the first phase its usage applies; after the last nothing is used
+	assert.Equal(t, 2.0, DailyUsage(m, time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC), time.UTC))
+	assert.Equal(t, 0.0, DailyUsage(m, time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC), time.UTC))
+	assert.Nil(t, DaysOfSupply(m, time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC), time.UTC))
+}
+
+func TestRunOutAt(t *testing.T) {
+	// Five doses left, taken at 08:00 and 20:00
+	m := testMedicine(t, 5, 1, "08:00", "20:00")
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	runOut, err := RunOutAt(m, now, utcClock(t))
+	assert.NoError(t, err)
+
+	// 10th 20:00, 11th 08:00 and 20:00, 12th 08:00 and 20:00 are covered
+	assert.Equal(t, time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC), *runOut)
+}
+
+func TestRunOutAtAfterCourseEnds(t *testing.T) {
+	m := testMedicine(t, 100, 1, "08:00")
+	m.EndDate = timePtr(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	runOut, err := RunOutAt(m, now, utcClock(t))
+	assert.NoError(t, err)
+	assert.Nil(t, runOut)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,155 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"time"
+)
+
+// NeedsRefill returns the projected run-out time when the medicine's supply runs
+// out within its refill threshold
+func NeedsRefill(m models.Medicine, now time.Time, clock reminders.Clock) (*time.Time, error) {
+	runOutAt, err := RunOutAt(m, now, clock)
+	if err != nil || runOutAt == nil {
+		return nil, err
+	}
+
+	threshold := time.Duration(m.RefillThresholdDays) * 24 * time.Hour
+	if runOutAt.Sub(now) > threshold {
+		return nil, nil
+	}
+	return runOutAt, nil
+}
+
+// RefillChecker periodically raises refill alerts for medicines running low
+type RefillChecker struct {
+	notifier reminders.Notifier
+	interval time.Duration
+}
+
+// NewRefillChecker creates a checker that looks for low stock every interval
+func NewRefillChecker(notifier reminders.Notifier, interval time.Duration) *RefillChecker {
+	return &RefillChecker{notifier: notifier, interval: interval}
+}
+
+// Run checks for low stock until ctx is cancelled
+func (c *RefillChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Check(ctx, time.Now()); err != nil {
+			log.Printf("Error checking refills: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check raises an alert for every tracked medicine that needs a refill and
+// doesn't already have an alert this refill cycle, notifying the patient of
+// new alerts. Medicines found with enough stock end their refill cycle.
+func (c *RefillChecker) Check(ctx context.Context, now time.Time) error {
+	rows, err := database.DB.Query("SELECT "+database.MedicineColumns+`
+		FROM medicines
+		WHERE deleted_at IS NULL AND stock_quantity IS NOT NULL AND (end_date IS NULL OR end_date >= $1)
+			AND NOT EXISTS (
+				SELECT 1 FROM refill_alerts a
+				WHERE a.medicine_id = medicines.id AND a.acknowledged_at IS NULL
+			)`, now)
+	if err != nil {
+		return err
+	}
+
+	var medicines []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		medicines = append(medicines, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range medicines {
+		clock, err := reminders.LoadClock(m.PatientID)
+		if err != nil {
+			return err
+		}
+
+		runOutAt, err := NeedsRefill(m, now, clock)
+		if err != nil {
+			return err
+		}
+		if runOutAt == nil {
+			if err := endRefillCycle(m.ID, now); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raised, err := raiseRefillAlert(m, *runOutAt, now)
+		if err != nil {
+			return err
+		}
+		if !raised {
+			continue
+		}
+
+		err = c.notifier.Notify(ctx, reminders.Notification{
+			Kind:       reminders.NotificationRefill,
+			PatientID:  m.PatientID,
+			MedicineID: m.ID,
+			Message: fmt.Sprintf("Refill %s: %g left, runs out %s",
+				m.Name, *m.StockQuantity, runOutAt.In(clock.Location(*runOutAt)).Format(time.RFC1123)),
+			At: *runOutAt,
+		})
+		if err != nil {
+			log.Printf("Error sending refill alert for medicine %d: %v", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// raiseRefillAlert opens a refill alert for the medicine, reporting false when
+// the current refill cycle already has one. An acknowledged alert still counts
+// until its cycle ends, so acknowledging without restocking doesn't raise
+// another straight away.
+func raiseRefillAlert(m models.Medicine, runOutAt, now time.Time) (bool, error) {
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO refill_alerts (medicine_id, stock_quantity, run_out_at, raised_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM refill_alerts WHERE medicine_id = $1 AND cycle_ended_at IS NULL)
+		ON CONFLICT (medicine_id) WHERE acknowledged_at IS NULL DO NOTHING
+		RETURNING id`,
+		m.ID, *m.StockQuantity, runOutAt, now,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// endRefillCycle ends the medicine's refill cycle once its stock is above the
+// refill threshold again, so the next shortage raises a new alert
+func endRefillCycle(medicineID int, now time.Time) error {
+	_, err := database.DB.Exec(`
+		UPDATE refill_alerts SET cycle_ended_at = $1
+		WHERE medicine_id = $2 AND acknowledged_at IS NOT NULL AND cycle_ended_at IS NULL`,
+		now, medicineID)
+	return err
+}
@@ -0,0 +1,32 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeedsRefill(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// Ten daily doses last well beyond a seven day threshold
+	m := testMedicine(t, 10, 1, "08:00")
+	m.RefillThresholdDays = 7
+	runOut, err := NeedsRefill(m, now, utcClock(t))
+	assert.NoError(t, err)
+	assert.Nil(t, runOut)
+
+	// Five daily doses run out inside it
+	m = testMedicine(t, 5, 1, "08:00")
+	m.RefillThresholdDays = 7
+	runOut, err = NeedsRefill(m, now, utcClock(t))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 16, 8, 0, 0, 0, time.UTC), *runOut)
+
+	// Untracked stock never needs a refill
+	m.StockQuantity = nil
+	runOut, err = NeedsRefill(m, now, utcClock(t))
+	assert.NoError(t, err)
+	assert.Nil(t, runOut)
+}
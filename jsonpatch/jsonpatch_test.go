@@ -0,0 +1,75 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Example from RFC 7396 section 3
+	doc := `{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"},
+		"tags": ["example", "sample"], "content": "This will be unchanged"}`
+	patch := `{"title": "Hello!", "phoneNumber": "+01-123-456-7890",
+		"author": {"familyName": null}, "tags": ["example"]}`
+
+	result, err := MergePatch([]byte(doc), []byte(patch))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Hello!", "author": {"givenName": "John"}, "tags": ["example"],
+		"content": "This will be unchanged", "phoneNumber": "+01-123-456-7890"}`, string(result))
+
+	_, err = MergePatch([]byte(doc), []byte(`{"title": `))
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`},
+		{`{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`},
+		{`{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo": ["bar", ["abc", "def"]]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`},
+		{`{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`},
+		{
+			`{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			`{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{`{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`},
+		{`{"foo": {"bar": 1}}`, `[{"op": "copy", "from": "/foo", "path": "/baz"}, {"op": "replace", "path": "/baz/bar", "value": 2}]`, `{"foo": {"bar": 1}, "baz": {"bar": 2}}`},
+		{`{"a/b": 1, "m~n": 2}`, `[{"op": "remove", "path": "/a~1b"}, {"op": "test", "path": "/m~0n", "value": 2.0}]`, `{"m~n": 2}`},
+		{`{"foo": null}`, `[{"op": "test", "path": "/foo", "value": null}]`, `{"foo": null}`},
+	}
+	for _, tt := range tests {
+		result, err := Apply([]byte(tt.doc), []byte(tt.patch))
+		if assert.NoError(t, err, tt.patch) {
+			assert.JSONEq(t, tt.want, string(result), tt.patch)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	doc := []byte(`{"foo": "bar", "list": [1, 2]}`)
+
+	_, err := Apply(doc, []byte(`[{"op": "test", "path": "/foo", "value": "baz"}]`))
+	assert.True(t, errors.Is(err, ErrTestFailed))
+
+	for _, patch := range []string{
+		`[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+		`[{"op": "remove", "path": "/missing"}]`,
+		`[{"op": "replace", "path": "/list/2", "value": 3}]`,
+		`[{"op": "add", "path": "/list/01", "value": 3}]`,
+		`[{"op": "add", "path": "/foo"}]`,
+		`[{"op": "move", "from": "/list", "path": "/list/0"}]`,
+		`[{"op": "frobnicate", "path": "/foo"}]`,
+		`[{"op": "add", "path": "foo", "value": 1}]`,
+		`{"op": "add"}`,
+	} {
+		_, err := Apply(doc, []byte(patch))
+		assert.Error(t, err, patch)
+		assert.False(t, errors.Is(err, ErrTestFailed), patch)
+	}
+}
@@ -2,10 +2,21 @@
 package main
 
 import (
+	"context"
 	"log"
+	"medicine-reminder/catalog"
 	"medicine-reminder/database"
 	"medicine-reminder/handlers"
+	"medicine-reminder/interactions"
+	"medicine-reminder/inventory"
+	"medicine-reminder/reminders"
+	"medicine-reminder/trash"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // Embed time zone data so patient time zones work on minimal hosts
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -14,13 +25,60 @@ import (
 // setupRouter configures and returns the API router with all route handlers
 func setupRouter() *mux.Router {
 	router := mux.NewRouter()
+	router.Use(handlers.WithRequestID)
 
 	// API Routes
 	router.HandleFunc("/api/medicines", handlers.GetMedicines).Methods("GET")
 	router.HandleFunc("/api/medicines", handlers.CreateMedicine).Methods("POST")
+	// Fixed paths under /api/medicines must be registered before /api/medicines/{id}
+	router.HandleFunc("/api/medicines/as-needed", handlers.GetAsNeededDoseStatus).Methods("GET")
+	router.HandleFunc("/api/medicines/search", handlers.SearchMedicines).Methods("GET")
+	router.HandleFunc("/api/medicines/trash", handlers.GetTrash).Methods("GET")
+	router.HandleFunc("/api/medicines/batch", handlers.BatchMedicines).Methods("POST")
+	router.HandleFunc("/api/medicines/export.csv", handlers.ExportMedicinesCSV).Methods("GET")
+	router.HandleFunc("/api/medicines/import", handlers.ImportMedicines).Methods("POST")
 	router.HandleFunc("/api/medicines/{id}", handlers.GetMedicine).Methods("GET")
 	router.HandleFunc("/api/medicines/{id}", handlers.UpdateMedicine).Methods("PUT")
+	router.HandleFunc("/api/medicines/{id}", handlers.PatchMedicine).Methods("PATCH")
 	router.HandleFunc("/api/medicines/{id}", handlers.DeleteMedicine).Methods("DELETE")
+	router.HandleFunc("/api/medicines/{id}/pause", handlers.PauseMedicine).Methods("POST")
+	router.HandleFunc("/api/medicines/{id}/resume", handlers.ResumeMedicine).Methods("POST")
+	router.HandleFunc("/api/medicines/{id}/review", handlers.ReviewMedicine).Methods("POST")
+	router.HandleFunc("/api/medicines/{id}/restore", handlers.RestoreMedicine).Methods("POST")
+	router.HandleFunc("/api/medicines/{id}/history", handlers.GetMedicineHistory).Methods("GET")
+	router.HandleFunc("/api/medicines/{id}/doses", handlers.GetDoses).Methods("GET")
+	router.HandleFunc("/api/medicines/{id}/doses", handlers.LogDose).Methods("POST")
+	router.HandleFunc("/api/medicines/{id}/dose-status", handlers.GetDoseStatus).Methods("GET")
+	router.HandleFunc("/api/medicines/{id}/inventory", handlers.GetInventory).Methods("GET")
+	router.HandleFunc("/api/medicines/{id}/inventory/adjustments", handlers.AdjustInventory).Methods("POST")
+	router.HandleFunc("/api/medicines/{id}/refill-alerts", handlers.GetMedicineRefillAlerts).Methods("GET")
+	router.HandleFunc("/api/medicines/{id}/refill-alerts/acknowledge", handlers.AcknowledgeRefillAlert).Methods("POST")
+	router.HandleFunc("/api/refill-alerts", handlers.GetRefillAlerts).Methods("GET")
+	router.HandleFunc("/api/audit", handlers.GetAuditLog).Methods("GET")
+
+	router.HandleFunc("/api/drugs", handlers.SearchDrugs).Methods("GET")
+	router.HandleFunc("/api/drugs/{drug_id}", handlers.GetDrug).Methods("GET")
+
+	router.HandleFunc("/api/patients", handlers.GetPatients).Methods("GET")
+	router.HandleFunc("/api/patients", handlers.CreatePatient).Methods("POST")
+	router.HandleFunc("/api/patients/{pid}", handlers.GetPatient).Methods("GET")
+	router.HandleFunc("/api/patients/{pid}", handlers.UpdatePatient).Methods("PUT")
+	router.HandleFunc("/api/patients/{pid}/quiet-hours", handlers.GetQuietHours).Methods("GET")
+	router.HandleFunc("/api/patients/{pid}/quiet-hours", handlers.UpdateQuietHours).Methods("PUT")
+	router.HandleFunc("/api/patients/{pid}/quiet-hours/preview", handlers.PreviewQuietHours).Methods("GET")
+	router.HandleFunc("/api/patients/{pid}/travel", handlers.GetTravel).Methods("GET")
+	router.HandleFunc("/api/patients/{pid}/travel", handlers.UpdateTravel).Methods("PUT")
+	router.HandleFunc("/api/patients/{pid}/travel", handlers.DeleteTravel).Methods("DELETE")
+	router.HandleFunc("/api/patients/{pid}/anchors", handlers.GetAnchors).Methods("GET")
+	router.HandleFunc("/api/patients/{pid}/anchors", handlers.UpdateAnchors).Methods("PUT")
+	router.HandleFunc("/api/patients/{pid}/interactions", handlers.GetPatientInteractions).Methods("GET")
+	router.HandleFunc("/api/patients/{pid}/allergies", handlers.GetAllergies).Methods("GET")
+	router.HandleFunc("/api/patients/{pid}/allergies", handlers.UpdateAllergies).Methods("PUT")
+	router.HandleFunc("/api/patients/{pid}/report.pdf", handlers.GetPatientReport).Methods("GET")
+
+	router.HandleFunc("/fhir", handlers.ImportFHIRBundle).Methods("POST")
+	router.HandleFunc("/fhir/MedicationStatement", handlers.SearchMedicationStatements).Methods("GET")
+	router.HandleFunc("/fhir/MedicationStatement/{id}", handlers.GetMedicationStatement).Methods("GET")
 
 	return router
 }
@@ -29,16 +87,72 @@ func setupRouter() *mux.Router {
 func setupCORS(router *mux.Router) http.Handler {
 	return cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match",
+			"X-Actor", "X-Request-ID"},
+		ExposedHeaders: []string{"ETag", "X-Request-ID"},
 	}).Handler(router)
 }
 
+// Local datasets, reloaded on SIGHUP
+const (
+	interactionsFile = "data/interactions.json"
+	catalogFile      = "data/drugs.json"
+)
+
+// loadDatasets loads the interaction dataset and drug catalog now and again
+// whenever the process receives SIGHUP, so they can be updated without a restart
+func loadDatasets() {
+	load := func() {
+		if err := interactions.LoadCurrent(interactionsFile); err != nil {
+			log.Printf("Error loading interaction dataset: %v", err)
+		} else {
+			log.Printf("Loaded interaction dataset version %s", interactions.Current().Version)
+		}
+		if err := catalog.LoadCurrent(catalogFile); err != nil {
+			log.Printf("Error loading drug catalog: %v", err)
+		} else {
+			log.Printf("Loaded drug catalog version %s", catalog.Current().Version)
+		}
+	}
+	load()
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			load()
+		}
+	}()
+}
+
 func main() {
+	loadDatasets()
+
 	// Initialize database connection
 	database.InitDB()
 	defer database.DB.Close()
 
+	// Start sending reminders, refill alerts and review reminders in the background
+	notifier := reminders.LogNotifier{}
+	dispatcher := reminders.NewDispatcher(notifier, time.Minute)
+	go dispatcher.Run(context.Background())
+	refillChecker := inventory.NewRefillChecker(notifier, time.Hour)
+	go refillChecker.Run(context.Background())
+	reviewChecker := reminders.NewReviewChecker(notifier, time.Hour)
+	go reviewChecker.Run(context.Background())
+
+	// Purge deleted medicines once they have been in the trash for the
+	// retention period, TRASH_RETENTION_DAYS (30 by default)
+	if days := os.Getenv("TRASH_RETENTION_DAYS"); days != "" {
+		retention, err := trash.ParseRetention(days)
+		if err != nil {
+			log.Fatalf("Invalid TRASH_RETENTION_DAYS: %v", err)
+		}
+		trash.Retention = retention
+	}
+	go trash.NewPurger(time.Hour).Run(context.Background())
+
 	// Setup router and CORS
 	router := setupRouter()
 	corsHandler := setupCORS(router)
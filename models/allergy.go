@@ -0,0 +1,25 @@
+package models
+
+// Allergy severities. Medicines matching a severe allergy are refused unless
+// the allergy is acknowledged; milder allergies only raise a warning.
+const (
+	AllergyMild     = "mild"
+	AllergyModerate = "moderate"
+	AllergySevere   = "severe"
+)
+
+// Allergy is a substance or drug class a patient is allergic to
+type Allergy struct {
+	Allergen string `json:"allergen" db:"allergen"` // Drug, ingredient or class, e.g. "penicillin"
+	Severity string `json:"severity" db:"severity"` // "mild", "moderate" or "severe"; defaults to "severe"
+	Reaction string `json:"reaction" db:"reaction"` // Optional description, e.g. "hives"
+}
+
+// AllergyWarning reports that a medicine matches one of the patient's allergies
+type AllergyWarning struct {
+	Allergen string `json:"allergen"`
+	Severity string `json:"severity"`
+	Reaction string `json:"reaction,omitempty"`
+	Drug     string `json:"drug"`       // Catalog drug or medicine name that matched
+	Matched  string `json:"matched_on"` // What matched, e.g. "class penicillin"
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Anchor events are daily routine events that dose times can be relative to
+const (
+	AnchorWake      = "wake"
+	AnchorBreakfast = "breakfast"
+	AnchorLunch     = "lunch"
+	AnchorDinner    = "dinner"
+	AnchorBedtime   = "bedtime"
+)
+
+// DefaultAnchors gives the time of each anchor event for patients who haven't set their own
+var DefaultAnchors = Anchors{
+	AnchorWake:      7 * 60,
+	AnchorBreakfast: 7*60 + 30,
+	AnchorLunch:     12*60 + 30,
+	AnchorDinner:    18*60 + 30,
+	AnchorBedtime:   22 * 60,
+}
+
+// Anchors maps anchor events to the time of day they happen for a patient
+type Anchors map[string]ClockTime
+
+// Time returns when an event happens, falling back to the default time
+func (a Anchors) Time(event string) ClockTime {
+	if t, ok := a[event]; ok {
+		return t
+	}
+	return DefaultAnchors[event]
+}
+
+// WithDefaults returns the anchors with every event not set given its default time
+func (a Anchors) WithDefaults() Anchors {
+	all := make(Anchors, len(DefaultAnchors))
+	for event := range DefaultAnchors {
+		all[event] = a.Time(event)
+	}
+	return all
+}
+
+// ValidateAnchorEvent checks that event is a known anchor event
+func ValidateAnchorEvent(event string) error {
+	if _, ok := DefaultAnchors[event]; !ok {
+		return fmt.Errorf("event %q must be one of %q, %q, %q, %q or %q", event,
+			AnchorWake, AnchorBreakfast, AnchorLunch, AnchorDinner, AnchorBedtime)
+	}
+	return nil
+}
+
+// RelativeTime is a dose time relative to an anchor event, e.g. 30 minutes
+// before breakfast is {"event": "breakfast", "offset_minutes": -30}
+type RelativeTime struct {
+	Event         string `json:"event"`
+	OffsetMinutes int    `json:"offset_minutes"` // Negative for before the event
+}
+
+// Validate checks that the event is known and the offset is under 12 hours
+func (r RelativeTime) Validate() error {
+	if err := ValidateAnchorEvent(r.Event); err != nil {
+		return err
+	}
+	if r.OffsetMinutes < -720 || r.OffsetMinutes > 720 {
+		return fmt.Errorf("offset minutes must be between -720 and 720")
+	}
+	return nil
+}
+
+// Resolve returns the time of day of the dose given the patient's anchors
+func (r RelativeTime) Resolve(anchors Anchors) ClockTime {
+	const minutesPerDay = 24 * 60
+	minutes := (int(anchors.Time(r.Event)) + r.OffsetMinutes) % minutesPerDay
+	if minutes < 0 {
+		minutes += minutesPerDay
+	}
+	return ClockTime(minutes)
+}
+
+// RelativeTimes is a list of anchor-relative dose times, stored as a JSONB array
+type RelativeTimes []RelativeTime
+
+// Value encodes the times as a JSON array for storage
+func (r RelativeTimes) Value() (driver.Value, error) {
+	if r == nil {
+		r = RelativeTimes{}
+	}
+	data, err := json.Marshal(r)
+	return string(data), err
+}
+
+// Scan decodes a JSON array of relative times read from the database
+func (r *RelativeTimes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into RelativeTimes", src)
+	}
+
+	var times RelativeTimes
+	if err := json.Unmarshal(data, &times); err != nil {
+		return err
+	}
+	*r = times
+	return nil
+}
+
+// DoseTimes returns the medicine's times of day together with its relative
+// times resolved against the patient's anchors, sorted and de-duplicated
+func (m Medicine) DoseTimes(anchors Anchors) TimesOfDay {
+	times := append(TimesOfDay(nil), m.TimeOfDay...)
+	for _, r := range m.RelativeTimes {
+		times = append(times, r.Resolve(anchors))
+	}
+	return times.Normalize()
+}
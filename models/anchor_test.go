@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelativeTimeResolve(t *testing.T) {
+	anchors := Anchors{AnchorBreakfast: 8 * 60}
+
+	// 30 minutes before the patient's breakfast
+	before := RelativeTime{Event: AnchorBreakfast, OffsetMinutes: -30}
+	assert.Equal(t, "07:30", before.Resolve(anchors).String())
+
+	// Events the patient hasn't set use the defaults
+	bedtime := RelativeTime{Event: AnchorBedtime}
+	assert.Equal(t, "22:00", bedtime.Resolve(anchors).String())
+
+	// Offsets wrap around midnight
+	late := RelativeTime{Event: AnchorBedtime, OffsetMinutes: 180}
+	assert.Equal(t, "01:00", late.Resolve(anchors).String())
+}
+
+func TestRelativeTimeValidate(t *testing.T) {
+	assert.NoError(t, RelativeTime{Event: AnchorDinner, OffsetMinutes: 15}.Validate())
+	assert.Error(t, RelativeTime{Event: "brunch"}.Validate())
+	assert.Error(t, RelativeTime{Event: AnchorDinner, OffsetMinutes: 800}.Validate())
+}
+
+func TestDoseTimes(t *testing.T) {
+	times, err := ParseTimesOfDay([]string{"12:00", "07:30"})
+	assert.NoError(t, err)
+
+	m := Medicine{
+		TimeOfDay: times,
+		RelativeTimes: RelativeTimes{
+			{Event: AnchorBreakfast, OffsetMinutes: -30},
+			{Event: AnchorBedtime},
+		},
+	}
+
+	// Resolved times are merged with the fixed ones without duplicates
+	anchors := Anchors{AnchorBreakfast: 8 * 60}
+	assert.Equal(t, []string{"07:30", "12:00", "22:00"}, m.DoseTimes(anchors).Strings())
+}
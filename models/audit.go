@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Actions recorded in the medicine audit log
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+
+	// AuditPurge records a deleted medicine being removed for good once its
+	// retention period has passed
+	AuditPurge = "purge"
+	// AuditReviewReminder records a medicine's review reminder being sent
+	AuditReviewReminder = "review_reminder"
+
+	// AuditBaseline records the state of a medicine that predates the audit log
+	AuditBaseline = "baseline"
+)
+
+// FieldChange is the value of a medicine field before and after a change, as
+// it appears in the medicine's JSON. Old is null for a created medicine.
+type FieldChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+// AuditEntry records a change made to a medicine. Entries are never changed
+// or removed, even when the medicine is purged.
+type AuditEntry struct {
+	ID         int64                  `json:"id" db:"id"`                   // Unique identifier, increasing with every change
+	MedicineID int                    `json:"medicine_id" db:"medicine_id"` // Medicine that changed
+	Action     string                 `json:"action" db:"action"`           // create, update, delete, restore, purge, review_reminder or baseline
+	Actor      string                 `json:"actor" db:"actor"`             // Who made the change, from the X-Actor header
+	RequestID  string                 `json:"request_id" db:"request_id"`   // Request that made the change
+	ChangedAt  time.Time              `json:"changed_at" db:"changed_at"`   // When the change was made
+	Changes    map[string]FieldChange `json:"changes" db:"changes"`         // Changed fields by JSON name
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// Dose statuses record what happened to a scheduled dose
+const (
+	DoseTaken   = "taken"   // The dose was taken
+	DoseSkipped = "skipped" // The dose was deliberately not taken
+)
+
+// Dose represents a logged dose of a medicine
+type Dose struct {
+	ID          int        `json:"id" db:"id"`                     // Unique identifier for the dose
+	MedicineID  int        `json:"medicine_id" db:"medicine_id"`   // Medicine the dose belongs to
+	Status      string     `json:"status" db:"status"`             // Whether the dose was taken or skipped
+	ScheduledAt *time.Time `json:"scheduled_at" db:"scheduled_at"` // Reminder the dose answers, if any
+	TakenAt     time.Time  `json:"taken_at" db:"taken_at"`         // When the dose was taken or skipped
+	Notes       string     `json:"notes" db:"notes"`               // Additional notes about the dose
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`     // When the record was created
+
+	OverrideReason string `json:"override_reason" db:"override_reason"` // Why the dose limits were overridden, if they were
+}
+
+// DoseInput represents the expected input format for logging a dose
+type DoseInput struct {
+	Status      string     `json:"status"`       // Defaults to "taken" when empty
+	ScheduledAt *time.Time `json:"scheduled_at"` // Optional
+	TakenAt     time.Time  `json:"taken_at"`     // Defaults to now when empty
+	Notes       string     `json:"notes"`
+
+	OverrideReason string `json:"override_reason"` // Required to take a dose that breaks the medicine's dose limits
+}
+
+// DoseStatus reports where a medicine stands against its dose limits
+type DoseStatus struct {
+	MedicineID             int        `json:"medicine_id"`
+	Name                   string     `json:"name"`
+	Dosage                 string     `json:"dosage"`
+	DosesLast24h           int        `json:"doses_last_24h"`            // Doses taken in the past 24 hours
+	MaxDosesPer24h         *int       `json:"max_doses_per_24h"`         // Limit on doses in any 24 hours
+	MinDoseIntervalMinutes *int       `json:"min_dose_interval_minutes"` // Limit on time between doses
+	LastTakenAt            *time.Time `json:"last_taken_at"`             // Most recent dose taken
+	NextAllowedAt          time.Time  `json:"next_allowed_at"`           // Earliest time the next dose stays within the limits
+}
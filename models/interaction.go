@@ -0,0 +1,12 @@
+package models
+
+// InteractionWarning reports a known interaction between two of a patient's medicines
+type InteractionWarning struct {
+	MedicineID        int       `json:"medicine_id,omitempty"` // Zero for a medicine that hasn't been saved
+	MedicineName      string    `json:"medicine_name"`
+	OtherMedicineID   int       `json:"other_medicine_id"`
+	OtherMedicineName string    `json:"other_medicine_name"`
+	Drugs             [2]string `json:"drugs"`    // Generic names of the interacting drugs
+	Severity          string    `json:"severity"` // "minor", "moderate" or "severe"
+	Description       string    `json:"description"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// InventoryTransaction records a change to a medicine's stock
+type InventoryTransaction struct {
+	ID           int       `json:"id" db:"id"`                       // Unique identifier for the transaction
+	MedicineID   int       `json:"medicine_id" db:"medicine_id"`     // Medicine whose stock changed
+	DoseID       *int      `json:"dose_id" db:"dose_id"`             // Dose that used the stock, if any
+	Change       float64   `json:"change" db:"change"`               // Units added (positive) or removed (negative)
+	BalanceAfter float64   `json:"balance_after" db:"balance_after"` // Stock after the change
+	Reason       string    `json:"reason" db:"reason"`               // Why the stock changed
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`       // When the change was recorded
+}
+
+// InventoryAdjustmentInput represents the expected input format for a manual stock adjustment
+type InventoryAdjustmentInput struct {
+	Change float64 `json:"change"` // Units to add (e.g. a refill) or remove (e.g. lost pills)
+	Reason string  `json:"reason"`
+}
+
+// Inventory summarizes a medicine's supply
+type Inventory struct {
+	MedicineID     int                    `json:"medicine_id"`
+	StockQuantity  *float64               `json:"stock_quantity"`  // Units on hand, nil when not tracked
+	UnitsPerDose   float64                `json:"units_per_dose"`  // Units used by each dose
+	DailyUsage     float64                `json:"daily_usage"`     // Units used per day on the current schedule
+	DaysOfSupply   *float64               `json:"days_of_supply"`  // How many days the stock lasts at the daily usage
+	RunOutAt       *time.Time             `json:"run_out_at"`      // First scheduled dose the stock can't cover, if before the course ends
+	RecentActivity []InventoryTransaction `json:"recent_activity"` // Most recent stock changes
+}
@@ -7,18 +7,69 @@ import (
 
 // Medicine represents a medication record in the system
 type Medicine struct {
-	ID        int       `json:"id" db:"id"`                   // Unique identifier for the medicine
-	Name      string    `json:"name" db:"name"`               // Name of the medicine
-	Dosage    string    `json:"dosage" db:"dosage"`           // Dosage amount (e.g., "500mg")
-	Frequency string    `json:"frequency" db:"frequency"`     // How often to take (e.g., "3 times a day")
-	TimeOfDay string    `json:"time_of_day" db:"time_of_day"` // JSON array of times to take the medicine
-	StartDate time.Time `json:"start_date" db:"start_date"`   // When to start taking the medicine
-	EndDate   time.Time `json:"end_date" db:"end_date"`       // When to stop taking the medicine
-	Notes     string    `json:"notes" db:"notes"`             // Additional notes or instructions
-	CreatedAt time.Time `json:"created_at" db:"created_at"`   // When the record was created
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`   // When the record was last updated
+	ID        int        `json:"id" db:"id"`                   // Unique identifier for the medicine
+	Name      string     `json:"name" db:"name"`               // Name of the medicine
+	Dosage    string     `json:"dosage" db:"dosage"`           // Dosage amount (e.g., "500mg")
+	Frequency string     `json:"frequency" db:"frequency"`     // How often to take (e.g., "3 times a day")
+	TimeOfDay TimesOfDay `json:"time_of_day" db:"time_of_day"` // Sorted times of day to take the medicine
+	StartDate time.Time  `json:"start_date" db:"start_date"`   // When to start taking the medicine
+	EndDate   *time.Time `json:"end_date" db:"end_date"`       // When to stop taking the medicine, nil for an indefinite course
+	Notes     string     `json:"notes" db:"notes"`             // Additional notes or instructions
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`   // When the record was created
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`   // When the record was last updated
+
+	PatientID        *int   `json:"patient_id" db:"patient_id"`                 // Patient taking the medicine, if any
+	QuietHoursPolicy string `json:"quiet_hours_policy" db:"quiet_hours_policy"` // How reminders behave during quiet hours
+	ScheduleType     string `json:"schedule_type" db:"schedule_type"`           // How times of day follow time zone changes
+
+	StockQuantity *float64 `json:"stock_quantity" db:"stock_quantity"` // Units on hand, nil when supply isn't tracked
+	UnitsPerDose  float64  `json:"units_per_dose" db:"units_per_dose"` // Units used by each dose (e.g. 2 tablets)
+
+	RefillThresholdDays int `json:"refill_threshold_days" db:"refill_threshold_days"` // Days of supply left that trigger a refill alert
+
+	DosageAmount *float64 `json:"dosage_amount" db:"dosage_amount"` // Amount parsed from Dosage, nil if it couldn't be parsed
+	DosageUnit   string   `json:"dosage_unit" db:"dosage_unit"`     // Unit parsed from Dosage (e.g. "mg", "puffs")
+	DosageForm   string   `json:"dosage_form" db:"dosage_form"`     // Form parsed from Dosage (e.g. "tablet"), if stated
+
+	AsNeeded               bool `json:"as_needed" db:"as_needed"`                                 // Taken when needed (PRN) rather than on a schedule
+	MaxDosesPer24h         *int `json:"max_doses_per_24h" db:"max_doses_per_24h"`                 // Most doses allowed in any 24 hours, if limited
+	MinDoseIntervalMinutes *int `json:"min_dose_interval_minutes" db:"min_dose_interval_minutes"` // Least time allowed between doses, if limited
+
+	DrugID *string `json:"drug_id" db:"drug_id"` // Drug catalog entry for the medicine, if linked
+
+	Phases DosePhases `json:"phases" db:"phases"` // Tapering or titration phases in date order; empty for a single dosage
+
+	RelativeTimes RelativeTimes `json:"relative_times" db:"relative_times"` // Dose times relative to the patient's anchor events
+
+	PausedAt    *time.Time `json:"paused_at" db:"paused_at"`       // When the course was paused, nil unless paused
+	PauseReason string     `json:"pause_reason" db:"pause_reason"` // Why the course was paused
+	Status      string     `json:"status"`                         // Lifecycle status computed when the medicine is read
+
+	ReviewIntervalDays *int       `json:"review_interval_days" db:"review_interval_days"` // Days between medication reviews, nil if not reviewed
+	NextReviewAt       *time.Time `json:"next_review_at" db:"next_review_at"`             // When the next review is due
+	LastReviewedAt     *time.Time `json:"last_reviewed_at" db:"last_reviewed_at"`         // When the medicine was last reviewed
+
+	Version int `json:"version" db:"version"` // Incremented on every change; the basis of the medicine's ETag
+
+	DeletedAt *time.Time `json:"deleted_at" db:"deleted_at"` // When the medicine was moved to the trash, nil unless deleted
 }
 
+// DefaultReviewIntervalDays is how often indefinite courses are reviewed unless set
+const DefaultReviewIntervalDays = 180
+
+// Quiet hours policies control what happens to a reminder that falls inside a quiet hours window
+const (
+	QuietHoursDefer    = "defer"    // Deliver the reminder when the window ends
+	QuietHoursSuppress = "suppress" // Drop the reminder entirely
+	QuietHoursOverride = "override" // Deliver anyway, for critical medicines
+)
+
+// Schedule types control how a medicine's times of day react to time zone changes
+const (
+	ScheduleWallClock     = "wall_clock"     // Follow the patient's local clock, including travel and DST
+	ScheduleFixedInterval = "fixed_interval" // Keep exactly 24 hours between doses regardless of local time
+)
+
 // MedicineInput represents the expected input format for creating/updating a medicine
 type MedicineInput struct {
 	Name      string    `json:"name"`
@@ -26,6 +77,62 @@ type MedicineInput struct {
 	Frequency string    `json:"frequency"`
 	TimeOfDay []string  `json:"time_of_day"` // Array of times before conversion to JSON
 	StartDate time.Time `json:"start_date"`
-	EndDate   time.Time `json:"end_date"`
+	EndDate   time.Time `json:"end_date,omitzero"` // Omitted for an indefinite course
 	Notes     string    `json:"notes"`
+
+	PatientID        *int   `json:"patient_id"`
+	QuietHoursPolicy string `json:"quiet_hours_policy"` // Defaults to "defer" when empty
+	ScheduleType     string `json:"schedule_type"`      // Defaults to "wall_clock" when empty
+
+	StockQuantity *float64 `json:"stock_quantity"` // Initial stock; only used when creating a medicine
+	UnitsPerDose  float64  `json:"units_per_dose"` // Defaults to 1 when empty
+
+	RefillThresholdDays *int `json:"refill_threshold_days"` // Defaults to 7 when omitted
+
+	AsNeeded               bool `json:"as_needed"` // As-needed medicines may omit time_of_day
+	MaxDosesPer24h         *int `json:"max_doses_per_24h"`
+	MinDoseIntervalMinutes *int `json:"min_dose_interval_minutes"`
+
+	DrugID *string `json:"drug_id"` // Must name a drug in the catalog when set
+
+	Phases []DosePhaseInput `json:"phases"` // Optional tapering or titration phases
+
+	RelativeTimes []RelativeTime `json:"relative_times"` // Optional times relative to anchor events, e.g. before breakfast
+
+	ReviewIntervalDays *int `json:"review_interval_days"` // Defaults to 180 for indefinite courses
+
+	AcknowledgeInteractions bool `json:"acknowledge_interactions"` // Required to save a medicine with severe interactions
+	AcknowledgeAllergies    bool `json:"acknowledge_allergies"`    // Required to save a medicine matching a severe allergy
+}
+
+// Medicine lifecycle statuses
+const (
+	StatusUpcoming  = "upcoming"  // The course hasn't started yet
+	StatusActive    = "active"    // The course is under way
+	StatusCompleted = "completed" // The course's last day has passed
+	StatusPaused    = "paused"    // The course was paused and reminders are held
+)
+
+// MedicineStatuses lists every lifecycle status
+var MedicineStatuses = []string{StatusUpcoming, StatusActive, StatusCompleted, StatusPaused}
+
+// StatusAt returns the medicine's lifecycle status at now. The end date's day
+// is part of the course, and a course that has ended is completed even if it
+// was paused. Indefinite courses are never completed.
+func (m Medicine) StatusAt(now time.Time) string {
+	switch {
+	case m.EndDate != nil && !now.Before(m.EndDate.Add(24*time.Hour)):
+		return StatusCompleted
+	case m.PausedAt != nil:
+		return StatusPaused
+	case now.Before(m.StartDate):
+		return StatusUpcoming
+	default:
+		return StatusActive
+	}
+}
+
+// PauseInput represents the expected input format for pausing a medicine
+type PauseInput struct {
+	Reason string `json:"reason"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusAt(t *testing.T) {
+	end := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	m := Medicine{
+		StartDate: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   &end,
+	}
+
+	assert.Equal(t, StatusUpcoming, m.StatusAt(time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, StatusActive, m.StatusAt(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)))
+
+	// The end date's day is still part of the course
+	assert.Equal(t, StatusActive, m.StatusAt(time.Date(2024, 3, 20, 18, 0, 0, 0, time.UTC)))
+	assert.Equal(t, StatusCompleted, m.StatusAt(time.Date(2024, 3, 21, 0, 0, 0, 0, time.UTC)))
+
+	pausedAt := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+	m.PausedAt = &pausedAt
+	assert.Equal(t, StatusPaused, m.StatusAt(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, StatusCompleted, m.StatusAt(time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestStatusAtIndefinite(t *testing.T) {
+	m := Medicine{StartDate: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)}
+
+	assert.Equal(t, StatusUpcoming, m.StatusAt(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, StatusActive, m.StatusAt(time.Date(2034, 3, 10, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Patient represents a person whose medicines are being tracked
+type Patient struct {
+	ID        int       `json:"id" db:"id"`                 // Unique identifier for the patient
+	Name      string    `json:"name" db:"name"`             // Display name of the patient
+	Timezone  string    `json:"timezone" db:"timezone"`     // IANA time zone of the patient's home
+	CreatedAt time.Time `json:"created_at" db:"created_at"` // When the record was created
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // When the record was last updated
+}
+
+// PatientInput represents the expected input format for creating/updating a patient
+type PatientInput struct {
+	Name     string `json:"name"`
+	Timezone string `json:"timezone"` // Defaults to "UTC" when empty
+}
+
+// Travel describes a patient's stay in another time zone. Wall clock doses move
+// toward the destination's local times by at most MaxShiftMinutes per day, and
+// move back the same way once the patient returns.
+type Travel struct {
+	Timezone        string     `json:"timezone" db:"timezone"`                   // IANA time zone of the destination
+	StartAt         time.Time  `json:"start_at" db:"start_at"`                   // When the patient arrives
+	EndAt           *time.Time `json:"end_at" db:"end_at"`                       // When the patient returns home, if known
+	MaxShiftMinutes int        `json:"max_shift_minutes" db:"max_shift_minutes"` // Largest daily change to dose times
+}
+
+// QuietHours represents a daily do-not-disturb window for a patient.
+// A window whose end is before its start wraps past midnight (e.g. 22:00-07:00).
+type QuietHours struct {
+	StartTime string `json:"start_time" db:"start_time"` // Window start as "HH:MM"
+	EndTime   string `json:"end_time" db:"end_time"`     // Window end as "HH:MM"
+}
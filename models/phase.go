@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DosePhase is a stretch of a tapering or titration schedule with its own
+// dosage and times, e.g. 40mg daily for 3 days then 30mg for 3 days
+type DosePhase struct {
+	StartDate time.Time  `json:"start_date"`  // First day of the phase
+	EndDate   time.Time  `json:"end_date"`    // Last day of the phase
+	Dosage    string     `json:"dosage"`      // Dosage taken during the phase
+	TimeOfDay TimesOfDay `json:"time_of_day"` // Times during the phase; the medicine's times when empty
+}
+
+// DosePhaseInput represents the expected input format for a dose phase
+type DosePhaseInput struct {
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Dosage    string    `json:"dosage"`
+	TimeOfDay []string  `json:"time_of_day"` // Optional, defaults to the medicine's times
+}
+
+// DosePhases is an ordered list of dose phases, stored as a JSONB array
+type DosePhases []DosePhase
+
+// Value encodes the phases as a JSON array for storage
+func (p DosePhases) Value() (driver.Value, error) {
+	if p == nil {
+		p = DosePhases{}
+	}
+	data, err := json.Marshal(p)
+	return string(data), err
+}
+
+// Scan decodes a JSON array of phases read from the database
+func (p *DosePhases) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into DosePhases", src)
+	}
+
+	var phases DosePhases
+	if err := json.Unmarshal(data, &phases); err != nil {
+		return err
+	}
+	*p = phases
+	return nil
+}
+
+// PhaseOn returns the phase covering a calendar day, comparing dates in loc
+func (m Medicine) PhaseOn(day time.Time, loc *time.Location) (DosePhase, bool) {
+	d := dateOf(day.In(loc))
+	for _, p := range m.Phases {
+		if !d.Before(dateOf(p.StartDate.In(loc))) && !d.After(dateOf(p.EndDate.In(loc))) {
+			return p, true
+		}
+	}
+	return DosePhase{}, false
+}
+
+// dateOf truncates t to midnight UTC on its calendar date so dates in
+// different locations compare by day
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
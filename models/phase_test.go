@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDosePhasesValueAndScan(t *testing.T) {
+	times, err := ParseTimesOfDay([]string{"20:00", "08:00"})
+	assert.NoError(t, err)
+
+	phases := DosePhases{{
+		StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		Dosage:    "40mg",
+		TimeOfDay: times,
+	}}
+
+	value, err := phases.Value()
+	assert.NoError(t, err)
+
+	var scanned DosePhases
+	assert.NoError(t, scanned.Scan([]byte(value.(string))))
+	assert.Equal(t, phases, scanned)
+
+	// No phases are stored as an empty array
+	value, err = DosePhases(nil).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", value)
+}
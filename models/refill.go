@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultRefillThresholdDays is how many days of supply trigger a refill alert by default
+const DefaultRefillThresholdDays = 7
+
+// RefillAlert is raised once per refill cycle when a medicine is running low
+type RefillAlert struct {
+	ID             int        `json:"id" db:"id"`                           // Unique identifier for the alert
+	MedicineID     int        `json:"medicine_id" db:"medicine_id"`         // Medicine that is running low
+	StockQuantity  float64    `json:"stock_quantity" db:"stock_quantity"`   // Stock when the alert was raised
+	RunOutAt       time.Time  `json:"run_out_at" db:"run_out_at"`           // Projected first dose the stock can't cover
+	RaisedAt       time.Time  `json:"raised_at" db:"raised_at"`             // When the alert was raised
+	AcknowledgedAt *time.Time `json:"acknowledged_at" db:"acknowledged_at"` // When the refill was recorded, nil while open
+}
+
+// RefillAcknowledgeInput represents the expected input format for acknowledging a refill alert
+type RefillAcknowledgeInput struct {
+	Quantity float64 `json:"quantity"` // Units received, added to stock when positive
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ClockTime is a time of day with minute precision, written as "HH:MM"
+type ClockTime int
+
+// ParseClockTime parses an "HH:MM" or "H:MM" time of day on a 24-hour clock
+func ParseClockTime(s string) (ClockTime, error) {
+	hh, mm, found := strings.Cut(s, ":")
+	if !found || len(hh) < 1 || len(hh) > 2 || len(mm) != 2 || !isDigits(hh) || !isDigits(mm) {
+		return 0, fmt.Errorf("time of day %q must be in HH:MM format", s)
+	}
+
+	hour, _ := strconv.Atoi(hh)
+	minute, _ := strconv.Atoi(mm)
+	if hour > 23 || minute > 59 {
+		return 0, fmt.Errorf("time of day %q is out of range", s)
+	}
+	return ClockTime(hour*60 + minute), nil
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Hour returns the hour of the day, 0-23
+func (c ClockTime) Hour() int {
+	return int(c) / 60
+}
+
+// Minute returns the minute of the hour, 0-59
+func (c ClockTime) Minute() int {
+	return int(c) % 60
+}
+
+// String formats the time of day as "HH:MM"
+func (c ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", c.Hour(), c.Minute())
+}
+
+// MarshalJSON encodes the time of day as an "HH:MM" string
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON decodes an "HH:MM" string
+func (c *ClockTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseClockTime(s)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// TimesOfDay is a sorted, de-duplicated list of dose times, stored as a JSONB array
+type TimesOfDay []ClockTime
+
+// ParseTimesOfDay parses, sorts and de-duplicates a list of "HH:MM" times
+func ParseTimesOfDay(values []string) (TimesOfDay, error) {
+	times := make(TimesOfDay, 0, len(values))
+	for _, v := range values {
+		t, err := ParseClockTime(v)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times.Normalize(), nil
+}
+
+// Normalize returns the times sorted with duplicates removed
+func (t TimesOfDay) Normalize() TimesOfDay {
+	sorted := append(TimesOfDay(nil), t...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	normalized := sorted[:0]
+	for i, c := range sorted {
+		if i == 0 || c != sorted[i-1] {
+			normalized = append(normalized, c)
+		}
+	}
+	return normalized
+}
+
+// Strings returns the times formatted as "HH:MM"
+func (t TimesOfDay) Strings() []string {
+	values := make([]string, len(t))
+	for i, c := range t {
+		values[i] = c.String()
+	}
+	return values
+}
+
+// Value encodes the times as a JSON array for storage
+func (t TimesOfDay) Value() (driver.Value, error) {
+	data, err := json.Marshal(t.Strings())
+	return string(data), err
+}
+
+// Scan decodes a JSON array of times read from the database
+func (t *TimesOfDay) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into TimesOfDay", src)
+	}
+
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	parsed, err := ParseTimesOfDay(values)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClockTime(t *testing.T) {
+	c, err := ParseClockTime("08:05")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, c.Hour())
+	assert.Equal(t, 5, c.Minute())
+
+	// Single digit hours are normalized
+	c, err = ParseClockTime("8:30")
+	assert.NoError(t, err)
+	assert.Equal(t, "08:30", c.String())
+
+	for _, invalid := range []string{"", "8am", "25:99", "24:00", "12:60", "12:5", "123:00", "-1:00", "08:00:00", " 08:00"} {
+		_, err := ParseClockTime(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseTimesOfDay(t *testing.T) {
+	times, err := ParseTimesOfDay([]string{"20:00", "8:00", "14:00", "08:00"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"08:00", "14:00", "20:00"}, times.Strings())
+
+	_, err = ParseTimesOfDay([]string{"08:00", "8am"})
+	assert.Error(t, err)
+}
+
+func TestTimesOfDayJSON(t *testing.T) {
+	times, err := ParseTimesOfDay([]string{"20:00", "08:00"})
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(times)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `["08:00", "20:00"]`, string(data))
+
+	var decoded TimesOfDay
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, times, decoded)
+	assert.Error(t, json.Unmarshal([]byte(`["25:00"]`), &decoded))
+}
+
+func TestTimesOfDayScan(t *testing.T) {
+	var times TimesOfDay
+	assert.NoError(t, times.Scan([]byte(`["20:00", "08:00", "08:00"]`)))
+	assert.Equal(t, []string{"08:00", "20:00"}, times.Strings())
+
+	value, err := times.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `["08:00","20:00"]`, value)
+}
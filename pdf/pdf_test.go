@@ -0,0 +1,82 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTo(t *testing.T) {
+	doc := New("Report (draft)")
+	page := doc.AddPage()
+	page.Text(50, 60, Bold, 16, "Medication report")
+	page.Line(50, 70, 545, 70, 0.5)
+	doc.AddPage().Text(50, 60, Regular, 10, `Take 1 (one) tablet \ day`)
+
+	var out bytes.Buffer
+	_, err := doc.WriteTo(&out)
+	assert.NoError(t, err)
+	pdf := out.String()
+
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	assert.Contains(t, pdf, "/Count 2")
+	assert.Contains(t, pdf, "/Title (Report \\(draft\\))")
+	assert.Contains(t, pdf, "BT /F2 16 Tf 50 782 Td (Medication report) Tj ET")
+	assert.Contains(t, pdf, "0.5 w 50 772 m 545 772 l S")
+	assert.Contains(t, pdf, `(Take 1 \(one\) tablet \\ day)`)
+
+	// Every cross-reference entry points at its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	assert.NotNil(t, startxref)
+	xref, _ := strconv.Atoi(startxref[1])
+	assert.True(t, strings.HasPrefix(pdf[xref:], "xref\n0 10\n"))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllStringSubmatch(pdf[xref:], -1)
+	assert.Len(t, entries, 9)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		assert.True(t, strings.HasPrefix(pdf[offset:], fmt.Sprintf("%d 0 obj\n", i+1)), "object %d", i+1)
+	}
+
+	// Stream lengths match their content
+	for _, m := range regexp.MustCompile(`(?s)<< /Length (\d+) >>\nstream\n(.*?)endstream`).FindAllStringSubmatch(pdf, -1) {
+		length, _ := strconv.Atoi(m[1])
+		assert.Equal(t, length, len(m[2]))
+	}
+}
+
+func TestWriteToEmpty(t *testing.T) {
+	var out bytes.Buffer
+	_, err := New("").WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "/Count 1")
+}
+
+func TestEncode(t *testing.T) {
+	assert.Equal(t, "Caf\xe9 \x96 5\xb5g ?", encode("Café – 5µg 💊"))
+}
+
+func TestWidth(t *testing.T) {
+	assert.InDelta(t, 5.56, Width("a", Regular, 10), 0.001)
+	assert.InDelta(t, 6.11, Width("b", Bold, 10), 0.001)
+	assert.InDelta(t, 50+55.6, Width("Il", Regular, 100)+Width("aa", Regular, 50), 0.001)
+}
+
+func TestWrap(t *testing.T) {
+	width := Width("Take with food", Regular, 10)
+	assert.Equal(t, []string{"Take with food", "and water"}, Wrap("Take with food and water", Regular, 10, width))
+	assert.Equal(t, []string{"First", "", "Second"}, Wrap("First\n\nSecond", Regular, 10, 100))
+	assert.Equal(t, []string{""}, Wrap("", Regular, 10, 100))
+
+	// Words longer than a line are broken
+	lines := Wrap("Hydroxychloroquine", Regular, 10, Width("Hydroxy", Regular, 10))
+	assert.Equal(t, "Hydroxychloroquine", strings.Join(lines, ""))
+	for _, line := range lines {
+		assert.LessOrEqual(t, Width(line, Regular, 10), Width("Hydroxy", Regular, 10))
+	}
+}
@@ -0,0 +1,147 @@
+package reminders
+
+import (
+	"fmt"
+	"medicine-reminder/models"
+	"time"
+)
+
+// DefaultMaxShiftMinutes is how far dose times move per day during travel when not specified
+const DefaultMaxShiftMinutes = 60
+
+// Clock resolves times of day to instants for a patient, taking their home
+// time zone and any travel into account
+type Clock struct {
+	home    *time.Location
+	travel  *models.Travel
+	dest    *time.Location
+	anchors models.Anchors // Times of the patient's anchor events, nil for the defaults
+}
+
+// NewClock creates a clock for a patient living in home, optionally travelling
+func NewClock(home string, travel *models.Travel) (Clock, error) {
+	homeLoc, err := time.LoadLocation(home)
+	if err != nil {
+		return Clock{}, fmt.Errorf("unknown time zone %q", home)
+	}
+
+	clock := Clock{home: homeLoc}
+	if travel != nil {
+		destLoc, err := time.LoadLocation(travel.Timezone)
+		if err != nil {
+			return Clock{}, fmt.Errorf("unknown time zone %q", travel.Timezone)
+		}
+		clock.travel = travel
+		clock.dest = destLoc
+	}
+	return clock, nil
+}
+
+// WithAnchors returns a copy of the clock that resolves relative dose times
+// against the given anchor event times
+func (c Clock) WithAnchors(anchors models.Anchors) Clock {
+	c.anchors = anchors
+	return c
+}
+
+// LocalClock returns a clock in the server's local time zone, used for
+// medicines that are not assigned to a patient
+func LocalClock() Clock {
+	return Clock{home: time.Local}
+}
+
+// ValidateTimezone checks that name is a known IANA time zone
+func ValidateTimezone(name string) error {
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("unknown time zone %q", name)
+	}
+	return nil
+}
+
+// ValidateTravel checks that a travel plan is well-formed
+func ValidateTravel(travel models.Travel) error {
+	if err := ValidateTimezone(travel.Timezone); err != nil {
+		return err
+	}
+	if travel.StartAt.IsZero() {
+		return fmt.Errorf("start_at is required")
+	}
+	if travel.EndAt != nil && travel.EndAt.Before(travel.StartAt) {
+		return fmt.Errorf("end_at must be after start_at")
+	}
+	if travel.MaxShiftMinutes < 0 {
+		return fmt.Errorf("max_shift_minutes must not be negative")
+	}
+	return nil
+}
+
+// Location returns the time zone the patient is in at t
+func (c Clock) Location(t time.Time) *time.Location {
+	if c.travel == nil || t.Before(c.travel.StartAt) {
+		return c.home
+	}
+	if c.travel.EndAt != nil && !t.Before(*c.travel.EndAt) {
+		return c.home
+	}
+	return c.dest
+}
+
+// WallClock returns the instant of a wall clock dose on the given home calendar day.
+// Outside travel this is the time of day in the home zone; during travel it moves
+// gradually toward the same time of day in the destination zone.
+func (c Clock) WallClock(day time.Time, minutes int) time.Time {
+	home := time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, c.home)
+	if c.travel == nil {
+		return home
+	}
+
+	dest := time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, c.dest)
+	return home.Add(-c.shift(day, home.Sub(dest)))
+}
+
+// shift returns how much of delta has been applied to doses by the given home day
+func (c Clock) shift(day time.Time, delta time.Duration) time.Duration {
+	step := time.Duration(c.travel.MaxShiftMinutes) * time.Minute
+	if c.travel.MaxShiftMinutes == 0 {
+		step = DefaultMaxShiftMinutes * time.Minute
+	}
+
+	startDay := startOfDay(c.travel.StartAt.In(c.home))
+	if day.Before(startDay) {
+		return 0
+	}
+
+	sign := time.Duration(1)
+	if delta < 0 {
+		sign, delta = -1, -delta
+	}
+
+	// Move toward the destination by one step per day, starting on the day of arrival
+	if c.travel.EndAt == nil || day.Before(startOfDay(c.travel.EndAt.In(c.home))) {
+		return sign * minDuration(delta, time.Duration(daysBetween(startDay, day)+1)*step)
+	}
+
+	// Then move back home by one step per day, starting on the day of return
+	endDay := startOfDay(c.travel.EndAt.In(c.home))
+	reached := minDuration(delta, time.Duration(daysBetween(startDay, endDay))*step)
+	remaining := reached - time.Duration(daysBetween(endDay, day)+1)*step
+	if remaining < 0 {
+		remaining = 0
+	}
+	return sign * remaining
+}
+
+// daysBetween counts calendar days from a to b, both at midnight in the same zone
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	// Compare as UTC dates so DST transitions don't produce fractional days
+	return int(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,60 @@
+package reminders
+
+import (
+	"medicine-reminder/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWallClockTravel(t *testing.T) {
+	// Flying from London to New York, five hours behind, for a week
+	arrive := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	leave := arrive.AddDate(0, 0, 7)
+	clock, err := NewClock("Europe/London", &models.Travel{
+		Timezone:        "America/New_York",
+		StartAt:         arrive,
+		EndAt:           &leave,
+		MaxShiftMinutes: 120,
+	})
+	assert.NoError(t, err)
+
+	london, _ := time.LoadLocation("Europe/London")
+	day := func(d int) time.Time { return time.Date(2024, 6, d, 0, 0, 0, 0, london) }
+	hoursLater := func(d int) float64 {
+		home := time.Date(2024, 6, d, 8, 0, 0, 0, london)
+		return clock.WallClock(day(d), 8*60).Sub(home).Hours()
+	}
+
+	// Doses move two hours per day until they reach 08:00 in New York
+	assert.Equal(t, 0.0, hoursLater(9))
+	assert.Equal(t, 2.0, hoursLater(10))
+	assert.Equal(t, 4.0, hoursLater(11))
+	assert.Equal(t, 5.0, hoursLater(12))
+	assert.Equal(t, 5.0, hoursLater(16))
+
+	// And move back the same way after returning home
+	assert.Equal(t, 3.0, hoursLater(17))
+	assert.Equal(t, 1.0, hoursLater(18))
+	assert.Equal(t, 0.0, hoursLater(19))
+}
+
+func TestClockLocation(t *testing.T) {
+	arrive := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	clock, err := NewClock("Europe/London", &models.Travel{Timezone: "Asia/Tokyo", StartAt: arrive})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Europe/London", clock.Location(arrive.Add(-time.Hour)).String())
+	assert.Equal(t, "Asia/Tokyo", clock.Location(arrive.Add(time.Hour)).String())
+}
+
+func TestValidateTravel(t *testing.T) {
+	start := time.Now()
+	end := start.Add(-time.Hour)
+
+	assert.NoError(t, ValidateTravel(models.Travel{Timezone: "Asia/Tokyo", StartAt: start}))
+	assert.Error(t, ValidateTravel(models.Travel{Timezone: "Mars/Olympus", StartAt: start}))
+	assert.Error(t, ValidateTravel(models.Travel{Timezone: "Asia/Tokyo"}))
+	assert.Error(t, ValidateTravel(models.Travel{Timezone: "Asia/Tokyo", StartAt: start, EndAt: &end}))
+}
@@ -0,0 +1,274 @@
+package reminders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"time"
+)
+
+// Upcoming returns the reminders whose delivery time falls within [from, to)
+// with quiet hours applied. A patientID of 0 includes every patient.
+func Upcoming(from, to time.Time, patientID int) ([]Reminder, error) {
+	// Deferred reminders may have been scheduled up to a day before they are delivered
+	scheduledFrom := from.Add(-24 * time.Hour)
+
+	query := "SELECT " + database.MedicineColumns + " FROM medicines WHERE deleted_at IS NULL AND (end_date IS NULL OR end_date >= $1)"
+	args := []interface{}{scheduledFrom}
+	if patientID > 0 {
+		query += " AND patient_id = $2"
+		args = append(args, patientID)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var medicines []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			return nil, err
+		}
+		medicines = append(medicines, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	quietHours, err := loadQuietHours(patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	clocks, err := loadClocks(patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reminders []Reminder
+	for _, m := range medicines {
+		clock := LocalClock()
+		var rules []models.QuietHours
+		if m.PatientID != nil {
+			clock = clocks[*m.PatientID]
+			rules = quietHours[*m.PatientID]
+		}
+
+		expanded, err := Expand(m, scheduledFrom, to, clock)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range expanded {
+			r, err = ApplyQuietHours(r, rules, m.QuietHoursPolicy)
+			if err != nil {
+				return nil, err
+			}
+			if r.DeliverAt.Before(from) || !r.DeliverAt.Before(to) {
+				continue
+			}
+			reminders = append(reminders, r)
+		}
+	}
+
+	sortByScheduledAt(reminders)
+	return reminders, nil
+}
+
+// loadQuietHours returns quiet hours rules keyed by patient ID
+func loadQuietHours(patientID int) (map[int][]models.QuietHours, error) {
+	query := "SELECT patient_id, start_time, end_time FROM quiet_hours"
+	var args []interface{}
+	if patientID > 0 {
+		query += " WHERE patient_id = $1"
+		args = append(args, patientID)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make(map[int][]models.QuietHours)
+	for rows.Next() {
+		var id int
+		var q models.QuietHours
+		if err := rows.Scan(&id, &q.StartTime, &q.EndTime); err != nil {
+			return nil, err
+		}
+		rules[id] = append(rules[id], q)
+	}
+	return rules, rows.Err()
+}
+
+// loadClocks returns each patient's clock keyed by patient ID
+func loadClocks(patientID int) (map[int]Clock, error) {
+	query := `
+		SELECT p.id, p.timezone, t.timezone, t.start_at, t.end_at, t.max_shift_minutes
+		FROM patients p
+		LEFT JOIN patient_travel t ON t.patient_id = p.id`
+	var args []interface{}
+	if patientID > 0 {
+		query += " WHERE p.id = $1"
+		args = append(args, patientID)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clocks := make(map[int]Clock)
+	for rows.Next() {
+		var id int
+		var home string
+		var travelZone sql.NullString
+		var startAt, endAt sql.NullTime
+		var maxShift sql.NullInt64
+		if err := rows.Scan(&id, &home, &travelZone, &startAt, &endAt, &maxShift); err != nil {
+			return nil, err
+		}
+
+		var travel *models.Travel
+		if travelZone.Valid {
+			travel = &models.Travel{
+				Timezone:        travelZone.String,
+				StartAt:         startAt.Time,
+				MaxShiftMinutes: int(maxShift.Int64),
+			}
+			if endAt.Valid {
+				travel.EndAt = &endAt.Time
+			}
+		}
+
+		clock, err := NewClock(home, travel)
+		if err != nil {
+			return nil, err
+		}
+		clocks[id] = clock
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	anchors, err := loadAnchors(patientID)
+	if err != nil {
+		return nil, err
+	}
+	for id, a := range anchors {
+		if clock, ok := clocks[id]; ok {
+			clocks[id] = clock.WithAnchors(a)
+		}
+	}
+	return clocks, nil
+}
+
+// loadAnchors returns the anchor event times patients have set, keyed by patient ID
+func loadAnchors(patientID int) (map[int]models.Anchors, error) {
+	query := "SELECT patient_id, event, time FROM patient_anchors"
+	var args []interface{}
+	if patientID > 0 {
+		query += " WHERE patient_id = $1"
+		args = append(args, patientID)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anchors := make(map[int]models.Anchors)
+	for rows.Next() {
+		var id int
+		var event, clockTime string
+		if err := rows.Scan(&id, &event, &clockTime); err != nil {
+			return nil, err
+		}
+		t, err := models.ParseClockTime(clockTime)
+		if err != nil {
+			return nil, err
+		}
+		if anchors[id] == nil {
+			anchors[id] = models.Anchors{}
+		}
+		anchors[id][event] = t
+	}
+	return anchors, rows.Err()
+}
+
+// LoadClock returns the clock for a medicine's patient, or the local clock
+// when the medicine is not assigned to a patient
+func LoadClock(patientID *int) (Clock, error) {
+	if patientID == nil {
+		return LocalClock(), nil
+	}
+
+	clocks, err := loadClocks(*patientID)
+	if err != nil {
+		return Clock{}, err
+	}
+	clock, ok := clocks[*patientID]
+	if !ok {
+		return Clock{}, fmt.Errorf("patient %d not found", *patientID)
+	}
+	return clock, nil
+}
+
+// Dispatcher periodically sends reminders that have become due
+type Dispatcher struct {
+	notifier Notifier
+	interval time.Duration
+	last     time.Time
+}
+
+// NewDispatcher creates a dispatcher that checks for due reminders every interval
+func NewDispatcher(notifier Notifier, interval time.Duration) *Dispatcher {
+	return &Dispatcher{notifier: notifier, interval: interval}
+}
+
+// Run dispatches due reminders until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	d.last = time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := d.dispatch(ctx, d.last, now); err != nil {
+				log.Printf("Error dispatching reminders: %v", err)
+				continue
+			}
+			d.last = now
+		}
+	}
+}
+
+// dispatch sends every non-suppressed reminder due within [from, to)
+func (d *Dispatcher) dispatch(ctx context.Context, from, to time.Time) error {
+	due, err := Upcoming(from, to, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range due {
+		if r.Suppressed() {
+			continue
+		}
+		if err := d.notifier.Notify(ctx, r.Notification()); err != nil {
+			log.Printf("Error sending reminder for medicine %d: %v", r.MedicineID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Notification kinds identify what a notification is about
+const (
+	NotificationDose   = "dose"   // A dose is due
+	NotificationRefill = "refill" // A medicine is running low
+	NotificationReview = "review" // A medicine is due for a medication review
+)
+
+// Notification is a message delivered to a patient
+type Notification struct {
+	Kind       string    `json:"kind"`
+	PatientID  *int      `json:"patient_id"`
+	MedicineID int       `json:"medicine_id"`
+	Message    string    `json:"message"`
+	At         time.Time `json:"at"` // The moment the notification refers to
+}
+
+// Notifier delivers notifications to patients. Dose reminders, refill alerts
+// and review reminders share the same notifier.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier writes notifications to the standard logger
+type LogNotifier struct{}
+
+// Notify logs the notification
+func (LogNotifier) Notify(ctx context.Context, n Notification) error {
+	log.Printf("Notification (%s): %s", n.Kind, n.Message)
+	return nil
+}
+
+// Notification returns the message sent for a dose reminder
+func (r Reminder) Notification() Notification {
+	return Notification{
+		Kind:       NotificationDose,
+		PatientID:  r.PatientID,
+		MedicineID: r.MedicineID,
+		Message:    fmt.Sprintf("Take %s (%s) scheduled at %s", r.Name, r.Dosage, r.ScheduledAt.Format(time.RFC3339)),
+		At:         r.ScheduledAt,
+	}
+}
@@ -0,0 +1,121 @@
+package reminders
+
+import (
+	"fmt"
+	"medicine-reminder/models"
+	"time"
+)
+
+// window is a parsed quiet hours window in minutes since midnight
+type window struct {
+	start int
+	end   int
+}
+
+// parseWindows converts quiet hours rules into windows
+func parseWindows(rules []models.QuietHours) ([]window, error) {
+	windows := make([]window, 0, len(rules))
+	for _, rule := range rules {
+		start, err := models.ParseClockTime(rule.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		end, err := models.ParseClockTime(rule.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window{start: int(start), end: int(end)})
+	}
+	return windows, nil
+}
+
+// ValidateQuietHours checks that every rule has a well-formed, non-empty window
+func ValidateQuietHours(rules []models.QuietHours) error {
+	windows, err := parseWindows(rules)
+	if err != nil {
+		return err
+	}
+	for _, w := range windows {
+		if w.start == w.end {
+			return fmt.Errorf("quiet hours start and end must differ")
+		}
+	}
+	return nil
+}
+
+// ValidatePolicy checks that policy is a known quiet hours policy
+func ValidatePolicy(policy string) error {
+	switch policy {
+	case models.QuietHoursDefer, models.QuietHoursSuppress, models.QuietHoursOverride:
+		return nil
+	}
+	return fmt.Errorf("quiet hours policy must be one of %q, %q or %q",
+		models.QuietHoursDefer, models.QuietHoursSuppress, models.QuietHoursOverride)
+}
+
+// endOf returns when the window containing t ends, or false if t is outside the window
+func (w window) endOf(t time.Time) (time.Time, bool) {
+	minutes := t.Hour()*60 + t.Minute()
+
+	if w.start < w.end {
+		if minutes >= w.start && minutes < w.end {
+			return w.endOn(t, 0), true
+		}
+		return time.Time{}, false
+	}
+
+	// The window wraps past midnight
+	if minutes >= w.start {
+		return w.endOn(t, 1), true
+	}
+	if minutes < w.end {
+		return w.endOn(t, 0), true
+	}
+	return time.Time{}, false
+}
+
+// endOn returns the wall-clock end of the window the given number of days
+// after t's date, in t's location, so days with DST changes end on time
+func (w window) endOn(t time.Time, days int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, w.end/60, w.end%60, 0, 0, t.Location())
+}
+
+// ApplyQuietHours applies the medicine's quiet hours policy to a reminder.
+// Reminders outside every window are returned unchanged.
+func ApplyQuietHours(r Reminder, rules []models.QuietHours, policy string) (Reminder, error) {
+	windows, err := parseWindows(rules)
+	if err != nil {
+		return r, err
+	}
+
+	deliverAt := r.DeliverAt
+	inQuietHours := false
+
+	// Windows may overlap or chain, so keep deferring until no window applies
+	for moved := true; moved; {
+		moved = false
+		for _, w := range windows {
+			if end, ok := w.endOf(deliverAt); ok {
+				inQuietHours = true
+				deliverAt = end
+				moved = true
+			}
+		}
+		if deliverAt.Sub(r.DeliverAt) > 24*time.Hour {
+			break
+		}
+	}
+
+	if !inQuietHours {
+		return r, nil
+	}
+
+	if policy == "" {
+		policy = models.QuietHoursDefer
+	}
+	r.QuietHoursAction = policy
+	if policy == models.QuietHoursDefer {
+		r.DeliverAt = deliverAt
+	}
+	return r, nil
+}
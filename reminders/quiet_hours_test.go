@@ -0,0 +1,84 @@
+package reminders
+
+import (
+	"medicine-reminder/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var overnight = []models.QuietHours{{StartTime: "22:00", EndTime: "07:00"}}
+
+func reminderAt(hour, minute int) Reminder {
+	at := time.Date(2024, 3, 20, hour, minute, 0, 0, time.UTC)
+	return Reminder{MedicineID: 1, ScheduledAt: at, DeliverAt: at}
+}
+
+func TestApplyQuietHoursOutsideWindow(t *testing.T) {
+	r, err := ApplyQuietHours(reminderAt(8, 0), overnight, models.QuietHoursDefer)
+	assert.NoError(t, err)
+
+	assert.Empty(t, r.QuietHoursAction)
+	assert.Equal(t, r.ScheduledAt, r.DeliverAt)
+}
+
+func TestApplyQuietHoursDefer(t *testing.T) {
+	// Before midnight the reminder moves to the next morning
+	r, err := ApplyQuietHours(reminderAt(23, 30), overnight, models.QuietHoursDefer)
+	assert.NoError(t, err)
+	assert.Equal(t, models.QuietHoursDefer, r.QuietHoursAction)
+	assert.Equal(t, time.Date(2024, 3, 21, 7, 0, 0, 0, time.UTC), r.DeliverAt)
+
+	// After midnight it moves to the same morning
+	r, err = ApplyQuietHours(reminderAt(3, 0), overnight, models.QuietHoursDefer)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 20, 7, 0, 0, 0, time.UTC), r.DeliverAt)
+}
+
+func TestApplyQuietHoursDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	// Clocks go forward at 02:00 on 10 March 2024, but the window still ends at 07:00
+	at := time.Date(2024, 3, 10, 1, 30, 0, 0, newYork)
+	r, err := ApplyQuietHours(Reminder{MedicineID: 1, ScheduledAt: at, DeliverAt: at}, overnight, models.QuietHoursDefer)
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2024, 3, 10, 7, 0, 0, 0, newYork).Equal(r.DeliverAt), r.DeliverAt)
+
+	// And back at 02:00 on 3 November
+	at = time.Date(2024, 11, 2, 23, 0, 0, 0, newYork)
+	r, err = ApplyQuietHours(Reminder{MedicineID: 1, ScheduledAt: at, DeliverAt: at}, overnight, models.QuietHoursDefer)
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2024, 11, 3, 7, 0, 0, 0, newYork).Equal(r.DeliverAt), r.DeliverAt)
+}
+
+func TestApplyQuietHoursChainedWindows(t *testing.T) {
+	rules := []models.QuietHours{
+		{StartTime: "13:00", EndTime: "14:00"},
+		{StartTime: "14:00", EndTime: "15:30"},
+	}
+
+	r, err := ApplyQuietHours(reminderAt(13, 15), rules, models.QuietHoursDefer)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 20, 15, 30, 0, 0, time.UTC), r.DeliverAt)
+}
+
+func TestApplyQuietHoursSuppressAndOverride(t *testing.T) {
+	r, err := ApplyQuietHours(reminderAt(3, 0), overnight, models.QuietHoursSuppress)
+	assert.NoError(t, err)
+	assert.True(t, r.Suppressed())
+
+	r, err = ApplyQuietHours(reminderAt(3, 0), overnight, models.QuietHoursOverride)
+	assert.NoError(t, err)
+	assert.False(t, r.Suppressed())
+	assert.Equal(t, models.QuietHoursOverride, r.QuietHoursAction)
+	assert.Equal(t, r.ScheduledAt, r.DeliverAt)
+}
+
+func TestValidateQuietHours(t *testing.T) {
+	assert.NoError(t, ValidateQuietHours(overnight))
+	assert.Error(t, ValidateQuietHours([]models.QuietHours{{StartTime: "25:00", EndTime: "07:00"}}))
+	assert.Error(t, ValidateQuietHours([]models.QuietHours{{StartTime: "07:00", EndTime: "07:00"}}))
+	assert.Error(t, ValidatePolicy("sometimes"))
+}
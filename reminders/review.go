@@ -0,0 +1,113 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"medicine-reminder/audit"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"time"
+)
+
+// ReviewChecker periodically reminds patients of medicines due for a
+// medication review, typically indefinite courses like statins
+type ReviewChecker struct {
+	notifier Notifier
+	interval time.Duration
+}
+
+// NewReviewChecker creates a checker that looks for due reviews every interval
+func NewReviewChecker(notifier Notifier, interval time.Duration) *ReviewChecker {
+	return &ReviewChecker{notifier: notifier, interval: interval}
+}
+
+// Run checks for due reviews until ctx is cancelled
+func (c *ReviewChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Check(ctx, time.Now()); err != nil {
+			log.Printf("Error checking reviews: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check sends a review reminder for every running medicine whose review is
+// due. Each review is reminded once; recording the review schedules the next.
+func (c *ReviewChecker) Check(ctx context.Context, now time.Time) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the due medicines and marking their reminders as sent before
+	// sending them keeps concurrent checks from reminding twice
+	rows, err := tx.Query("SELECT "+database.MedicineColumns+`
+		FROM medicines
+		WHERE deleted_at IS NULL AND next_review_at <= $1
+			AND (review_reminded_at IS NULL OR review_reminded_at < next_review_at)
+			AND (end_date IS NULL OR end_date >= $1)
+		FOR UPDATE SKIP LOCKED`, now)
+	if err != nil {
+		return err
+	}
+
+	var medicines []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		medicines = append(medicines, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range medicines {
+		if _, err := tx.Exec("UPDATE medicines SET review_reminded_at = $1 WHERE id = $2", now, m.ID); err != nil {
+			return err
+		}
+		if err := audit.Record(tx, models.AuditReviewReminder, audit.SystemActor, "review-check", &m, m); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, m := range medicines {
+		clock, err := LoadClock(m.PatientID)
+		if err != nil {
+			return err
+		}
+		if err := c.notifier.Notify(ctx, ReviewNotification(m, clock)); err != nil {
+			log.Printf("Error sending review reminder for medicine %d: %v", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// ReviewNotification returns the message sent when a medicine is due for review
+func ReviewNotification(m models.Medicine, clock Clock) Notification {
+	due := *m.NextReviewAt
+	return Notification{
+		Kind:       NotificationReview,
+		PatientID:  m.PatientID,
+		MedicineID: m.ID,
+		Message: fmt.Sprintf("Time to review %s (%s) with your prescriber: review due %s",
+			m.Name, m.Dosage, due.In(clock.Location(due)).Format("Mon, 02 Jan 2006")),
+		At: due,
+	}
+}
@@ -0,0 +1,27 @@
+package reminders
+
+import (
+	"medicine-reminder/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewNotification(t *testing.T) {
+	clock, err := NewClock("Asia/Tokyo", nil)
+	assert.NoError(t, err)
+
+	// Late evening UTC is already the next day in Tokyo
+	due := time.Date(2024, 9, 16, 20, 0, 0, 0, time.UTC)
+	patientID := 3
+	m := models.Medicine{ID: 7, PatientID: &patientID, Name: "Atorvastatin", Dosage: "20mg", NextReviewAt: &due}
+
+	n := ReviewNotification(m, clock)
+	assert.Equal(t, NotificationReview, n.Kind)
+	assert.Equal(t, 7, n.MedicineID)
+	assert.Equal(t, &patientID, n.PatientID)
+	assert.Equal(t, due, n.At)
+	assert.Contains(t, n.Message, "Atorvastatin (20mg)")
+	assert.Contains(t, n.Message, "Tue, 17 Sep 2024")
+}
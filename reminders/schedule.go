@@ -0,0 +1,162 @@
+// Package reminders expands medicine schedules into concrete reminders and dispatches them
+package reminders
+
+import (
+	"medicine-reminder/models"
+	"sort"
+	"time"
+)
+
+// Reminder is a single scheduled dose of a medicine
+type Reminder struct {
+	MedicineID       int       `json:"medicine_id"`
+	PatientID        *int      `json:"patient_id"`
+	Name             string    `json:"name"`
+	Dosage           string    `json:"dosage"`
+	ScheduledAt      time.Time `json:"scheduled_at"`                 // When the dose is due according to the schedule
+	DeliverAt        time.Time `json:"deliver_at"`                   // When the reminder will actually be sent
+	QuietHoursAction string    `json:"quiet_hours_action,omitempty"` // Policy applied when the dose falls in quiet hours
+}
+
+// Suppressed reports whether the reminder should not be sent at all
+func (r Reminder) Suppressed() bool {
+	return r.QuietHoursAction == models.QuietHoursSuppress
+}
+
+// Expand returns the reminders for a medicine scheduled within [from, to),
+// ordered by scheduled time. Times of day are resolved with the patient's clock
+// and reported in the zone the patient is in at that moment. As-needed
+// medicines have no reminders, and medicines with dose phases are reminded
+// with the dosage and times of the phase each dose falls in. Paused medicines
+// have no reminders from the moment they were paused.
+func Expand(m models.Medicine, from, to time.Time, clock Clock) ([]Reminder, error) {
+	if m.PausedAt != nil && m.PausedAt.Before(to) {
+		to = *m.PausedAt
+	}
+	if m.AsNeeded || !from.Before(to) {
+		return []Reminder{}, nil
+	}
+
+	if len(m.Phases) == 0 {
+		reminders := expandCourse(m, from, to, clock)
+		sortByScheduledAt(reminders)
+		return reminders, nil
+	}
+
+	reminders := []Reminder{}
+	for _, p := range m.Phases {
+		phase := m
+		phase.Dosage = p.Dosage
+		if len(p.TimeOfDay) > 0 {
+			phase.TimeOfDay = p.TimeOfDay
+			phase.RelativeTimes = nil
+		}
+		if p.StartDate.After(phase.StartDate) {
+			phase.StartDate = p.StartDate
+		}
+		if phase.EndDate == nil || p.EndDate.Before(*phase.EndDate) {
+			end := p.EndDate
+			phase.EndDate = &end
+		}
+		reminders = append(reminders, expandCourse(phase, from, to, clock)...)
+	}
+
+	sortByScheduledAt(reminders)
+	return reminders, nil
+}
+
+// expandCourse returns the reminders for the medicine's times of day between
+// its start and end dates, resolving relative times with the patient's anchors
+func expandCourse(m models.Medicine, from, to time.Time, clock Clock) []Reminder {
+	times := m.DoseTimes(clock.anchors)
+	clocks := make([]int, len(times))
+	for i, t := range times {
+		clocks[i] = int(t)
+	}
+
+	var instants []time.Time
+	if m.ScheduleType == models.ScheduleFixedInterval {
+		instants = fixedIntervalInstants(m, clocks, from, to, clock)
+	} else {
+		instants = wallClockInstants(m, clocks, from, to, clock)
+	}
+
+	reminders := make([]Reminder, 0, len(instants))
+	for _, at := range instants {
+		at = at.In(clock.Location(at))
+		reminders = append(reminders, Reminder{
+			MedicineID:  m.ID,
+			PatientID:   m.PatientID,
+			Name:        m.Name,
+			Dosage:      m.Dosage,
+			ScheduledAt: at,
+			DeliverAt:   at,
+		})
+	}
+	return reminders
+}
+
+// wallClockInstants resolves each time of day on every home calendar day of the
+// course. Indefinite courses run until to.
+func wallClockInstants(m models.Medicine, clocks []int, from, to time.Time, clock Clock) []time.Time {
+	firstDay := startOfDay(m.StartDate.In(clock.home))
+	var lastDay *time.Time
+	if m.EndDate != nil {
+		d := startOfDay(m.EndDate.In(clock.home))
+		lastDay = &d
+	}
+
+	// Travel can move a dose more than a day away from its home calendar day
+	var instants []time.Time
+	for day := startOfDay(from.In(clock.home)).AddDate(0, 0, -2); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if day.Before(firstDay) || (lastDay != nil && day.After(*lastDay)) {
+			continue
+		}
+		for _, minutes := range clocks {
+			at := clock.WallClock(day, minutes)
+			if !at.Before(from) && at.Before(to) {
+				instants = append(instants, at)
+			}
+		}
+	}
+	return instants
+}
+
+// fixedIntervalInstants repeats each first-day dose exactly every 24 hours,
+// ignoring DST and travel. Indefinite courses run until to.
+func fixedIntervalInstants(m models.Medicine, clocks []int, from, to time.Time, clock Clock) []time.Time {
+	firstDay := startOfDay(m.StartDate.In(clock.home))
+	courseEnd := to
+	if m.EndDate != nil {
+		courseEnd = startOfDay(m.EndDate.In(clock.home)).AddDate(0, 0, 1)
+	}
+
+	const interval = 24 * time.Hour
+	var instants []time.Time
+	for _, minutes := range clocks {
+		anchor := time.Date(firstDay.Year(), firstDay.Month(), firstDay.Day(), minutes/60, minutes%60, 0, 0, clock.home)
+
+		at := anchor
+		if from.After(anchor) {
+			at = anchor.Add(from.Sub(anchor) / interval * interval)
+		}
+		for ; at.Before(to) && at.Before(courseEnd); at = at.Add(interval) {
+			if !at.Before(from) {
+				instants = append(instants, at)
+			}
+		}
+	}
+	return instants
+}
+
+// startOfDay truncates t to midnight in its own location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// sortByScheduledAt orders reminders chronologically
+func sortByScheduledAt(reminders []Reminder) {
+	sort.SliceStable(reminders, func(i, j int) bool {
+		return reminders[i].ScheduledAt.Before(reminders[j].ScheduledAt)
+	})
+}
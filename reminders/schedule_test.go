@@ -0,0 +1,182 @@
+package reminders
+
+import (
+	"medicine-reminder/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+	m := models.Medicine{
+		ID:        1,
+		Name:      "Paracetamol",
+		Dosage:    "500mg",
+		TimeOfDay: timesOfDay(t, "20:00", "08:00"),
+		StartDate: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC),
+		EndDate:   timePtr(time.Date(2024, 3, 21, 0, 0, 0, 0, time.UTC)),
+	}
+
+	from := time.Date(2024, 3, 19, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 22, 12, 0, 0, 0, time.UTC)
+	reminders, err := Expand(m, from, to, utcClock(t))
+	assert.NoError(t, err)
+
+	// Only doses between the start and end dates are included, in order
+	assert.Equal(t, 4, len(reminders))
+	assert.Equal(t, time.Date(2024, 3, 20, 8, 0, 0, 0, time.UTC), reminders[0].ScheduledAt)
+	assert.Equal(t, time.Date(2024, 3, 20, 20, 0, 0, 0, time.UTC), reminders[1].ScheduledAt)
+	assert.Equal(t, time.Date(2024, 3, 21, 20, 0, 0, 0, time.UTC), reminders[3].ScheduledAt)
+	assert.Equal(t, "Paracetamol", reminders[0].Name)
+}
+
+func TestExpandPhases(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	m := models.Medicine{
+		ID:        1,
+		Dosage:    "40mg",
+		TimeOfDay: timesOfDay(t, "08:00"),
+		StartDate: day(1),
+		EndDate:   timePtr(day(6)),
+		Phases: models.DosePhases{
+			{StartDate: day(1), EndDate: day(3), Dosage: "40mg"},
+			{StartDate: day(4), EndDate: day(5), Dosage: "30mg", TimeOfDay: timesOfDay(t, "08:00", "20:00")},
+			{StartDate: day(6), EndDate: day(6), Dosage: "10mg"},
+		},
+	}
+
+	reminders, err := Expand(m, day(1), day(8), utcClock(t))
+	assert.NoError(t, err)
+
+	// 3 days of 40mg, 2 days of 30mg twice a day, then 10mg once
+	assert.Len(t, reminders, 8)
+	assert.Equal(t, "40mg", reminders[2].Dosage)
+	assert.Equal(t, "30mg", reminders[3].Dosage)
+	assert.Equal(t, time.Date(2024, 3, 4, 20, 0, 0, 0, time.UTC), reminders[4].ScheduledAt)
+	assert.Equal(t, "10mg", reminders[7].Dosage)
+	assert.Equal(t, time.Date(2024, 3, 6, 8, 0, 0, 0, time.UTC), reminders[7].ScheduledAt)
+
+	phase, ok := m.PhaseOn(time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC), time.UTC)
+	assert.True(t, ok)
+	assert.Equal(t, "30mg", phase.Dosage)
+}
+
+func TestExpandRelativeTimes(t *testing.T) {
+	m := models.Medicine{
+		ID:            1,
+		StartDate:     time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC),
+		EndDate:       timePtr(time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)),
+		RelativeTimes: models.RelativeTimes{{Event: models.AnchorBreakfast, OffsetMinutes: -30}},
+	}
+	clock := utcClock(t).WithAnchors(models.Anchors{models.AnchorBreakfast: 9 * 60})
+
+	reminders, err := Expand(m, m.StartDate, m.EndDate.Add(24*time.Hour), clock)
+	assert.NoError(t, err)
+	assert.Len(t, reminders, 1)
+	assert.Equal(t, time.Date(2024, 3, 20, 8, 30, 0, 0, time.UTC), reminders[0].ScheduledAt)
+}
+
+func TestExpandPaused(t *testing.T) {
+	pausedAt := time.Date(2024, 3, 21, 12, 0, 0, 0, time.UTC)
+	m := models.Medicine{
+		ID:        1,
+		TimeOfDay: timesOfDay(t, "08:00", "20:00"),
+		StartDate: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC),
+		EndDate:   timePtr(time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC)),
+		PausedAt:  &pausedAt,
+	}
+
+	// Only the doses before the pause are reminded
+	reminders, err := Expand(m, m.StartDate, m.EndDate.Add(24*time.Hour), utcClock(t))
+	assert.NoError(t, err)
+	assert.Len(t, reminders, 3)
+	assert.True(t, reminders[2].ScheduledAt.Before(pausedAt))
+
+	reminders, err = Expand(m, pausedAt, *m.EndDate, utcClock(t))
+	assert.NoError(t, err)
+	assert.Empty(t, reminders)
+}
+
+func TestExpandIndefinite(t *testing.T) {
+	m := models.Medicine{
+		ID:        1,
+		TimeOfDay: timesOfDay(t, "08:00"),
+		StartDate: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC),
+	}
+
+	// Years into the course, doses are still reminded up to the horizon
+	from := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, scheduleType := range []string{models.ScheduleWallClock, models.ScheduleFixedInterval} {
+		m.ScheduleType = scheduleType
+		reminders, err := Expand(m, from, from.Add(72*time.Hour), utcClock(t))
+		assert.NoError(t, err)
+		assert.Len(t, reminders, 3, scheduleType)
+		assert.Equal(t, time.Date(2030, 1, 1, 8, 0, 0, 0, time.UTC), reminders[0].ScheduledAt, scheduleType)
+	}
+}
+
+func TestExpandAsNeeded(t *testing.T) {
+	m := models.Medicine{
+		ID:        1,
+		TimeOfDay: timesOfDay(t, "08:00"),
+		StartDate: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC),
+		EndDate:   timePtr(time.Date(2024, 3, 21, 0, 0, 0, 0, time.UTC)),
+		AsNeeded:  true,
+	}
+
+	reminders, err := Expand(m, m.StartDate, m.EndDate.Add(24*time.Hour), utcClock(t))
+	assert.NoError(t, err)
+	assert.Empty(t, reminders)
+}
+
+func TestExpandAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	clock, err := NewClock("America/New_York", nil)
+	assert.NoError(t, err)
+
+	// Clocks spring forward on March 10th
+	m := models.Medicine{
+		ID:        1,
+		TimeOfDay: timesOfDay(t, "08:00"),
+		StartDate: time.Date(2024, 3, 9, 0, 0, 0, 0, newYork),
+		EndDate:   timePtr(time.Date(2024, 3, 11, 0, 0, 0, 0, newYork)),
+	}
+	from := m.StartDate
+	to := time.Date(2024, 3, 12, 0, 0, 0, 0, newYork)
+
+	// Wall clock doses stay at 08:00 local time when clocks spring forward
+	m.ScheduleType = models.ScheduleWallClock
+	reminders, err := Expand(m, from, to, clock)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(reminders))
+	for _, r := range reminders {
+		assert.Equal(t, 8, r.ScheduledAt.Hour())
+	}
+
+	// Fixed interval doses stay exactly 24 hours apart, landing at 09:00 local time
+	m.ScheduleType = models.ScheduleFixedInterval
+	reminders, err = Expand(m, from, to, clock)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(reminders))
+	assert.Equal(t, 8, reminders[0].ScheduledAt.Hour())
+	assert.Equal(t, 9, reminders[2].ScheduledAt.Hour())
+	assert.Equal(t, 24*time.Hour, reminders[2].ScheduledAt.Sub(reminders[1].ScheduledAt))
+}
+
+func utcClock(t *testing.T) Clock {
+	clock, err := NewClock("UTC", nil)
+	assert.NoError(t, err)
+	return clock
+}
+
+func timesOfDay(t *testing.T, values ...string) models.TimesOfDay {
+	times, err := models.ParseTimesOfDay(values)
+	assert.NoError(t, err)
+	return times
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
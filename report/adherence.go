@@ -0,0 +1,108 @@
+package report
+
+import (
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"sort"
+	"time"
+)
+
+// MatchWindow is how far from a scheduled time a dose logged without one
+// can be and still count for it
+const MatchWindow = 2 * time.Hour
+
+// Adherence is how a medicine's scheduled doses in a period went
+type Adherence struct {
+	MedicineID int
+	Name       string
+	Scheduled  int
+	Taken      int
+	Skipped    int
+	Missed     int
+}
+
+// Rate returns the share of scheduled doses that were taken, from 0 to 1, and
+// false when no doses were scheduled
+func (a Adherence) Rate() (float64, bool) {
+	if a.Scheduled == 0 {
+		return 0, false
+	}
+	return float64(a.Taken) / float64(a.Scheduled), true
+}
+
+// Add returns the totals of a and b
+func (a Adherence) Add(b Adherence) Adherence {
+	a.Scheduled += b.Scheduled
+	a.Taken += b.Taken
+	a.Skipped += b.Skipped
+	a.Missed += b.Missed
+	return a
+}
+
+// MissedDose is a scheduled dose with no dose logged for it
+type MissedDose struct {
+	MedicineID  int
+	Name        string
+	Dosage      string
+	ScheduledAt time.Time
+}
+
+// Match compares a medicine's scheduled doses with the doses logged for it.
+// A dose logged for a scheduled time counts for that time; other doses count
+// for the nearest unmatched scheduled time within MatchWindow. Doses that
+// match nothing, such as extra or as-needed doses, aren't counted.
+func Match(m models.Medicine, scheduled []reminders.Reminder, doses []models.Dose) (Adherence, []MissedDose) {
+	outcomes := make([]string, len(scheduled))
+	slots := make(map[int64]int, len(scheduled))
+	for i, r := range scheduled {
+		slots[r.ScheduledAt.UnixNano()] = i
+	}
+
+	var unmatched []models.Dose
+	for _, d := range doses {
+		if d.ScheduledAt != nil {
+			if i, ok := slots[d.ScheduledAt.UnixNano()]; ok && outcomes[i] == "" {
+				outcomes[i] = d.Status
+				continue
+			}
+		}
+		unmatched = append(unmatched, d)
+	}
+
+	sort.Slice(unmatched, func(i, j int) bool { return unmatched[i].TakenAt.Before(unmatched[j].TakenAt) })
+	for _, d := range unmatched {
+		nearest := -1
+		for i, r := range scheduled {
+			gap := d.TakenAt.Sub(r.ScheduledAt).Abs()
+			if outcomes[i] != "" || gap > MatchWindow {
+				continue
+			}
+			if nearest < 0 || gap < d.TakenAt.Sub(scheduled[nearest].ScheduledAt).Abs() {
+				nearest = i
+			}
+		}
+		if nearest >= 0 {
+			outcomes[nearest] = d.Status
+		}
+	}
+
+	a := Adherence{MedicineID: m.ID, Name: m.Name, Scheduled: len(scheduled)}
+	var missed []MissedDose
+	for i, outcome := range outcomes {
+		switch outcome {
+		case models.DoseTaken:
+			a.Taken++
+		case models.DoseSkipped:
+			a.Skipped++
+		default:
+			a.Missed++
+			missed = append(missed, MissedDose{
+				MedicineID:  m.ID,
+				Name:        m.Name,
+				Dosage:      scheduled[i].Dosage,
+				ScheduledAt: scheduled[i].ScheduledAt,
+			})
+		}
+	}
+	return a, missed
+}
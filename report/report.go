@@ -0,0 +1,314 @@
+// Package report builds printable medication reports for patients to bring to
+// doctor visits: their current medicines, how well they kept to their
+// schedules over a period and the doses they missed.
+package report
+
+import (
+	"fmt"
+	"io"
+	"medicine-reminder/models"
+	"medicine-reminder/pdf"
+	"strings"
+	"time"
+)
+
+// Report is the content of a patient's medication report
+type Report struct {
+	Patient     models.Patient
+	GeneratedAt time.Time
+	Location    *time.Location    // Zone dates are shown in
+	From, To    time.Time         // Period of the adherence summary
+	Medicines   []models.Medicine // Current medicines
+	Adherence   []Adherence       // Medicines with doses scheduled in the period
+	Missed      []MissedDose      // Most recent first
+}
+
+// Page layout in points
+const (
+	margin       = 50.0
+	contentWidth = pdf.PageWidth - 2*margin
+	footerY      = pdf.PageHeight - 30
+	bottom       = pdf.PageHeight - 60 // Content stops here to leave room for the footer
+
+	textSize    = 9.0
+	lineHeight  = 11.0
+	cellPadding = 3.0
+)
+
+// Date formats
+const (
+	dateFormat     = "2 Jan 2006"
+	dateTimeFormat = "Mon 2 Jan 2006 15:04"
+)
+
+// column is a column of a table
+type column struct {
+	title string
+	width float64
+	right bool // Align to the right, for numbers
+}
+
+// Render writes the report as a PDF
+func Render(w io.Writer, r Report) error {
+	if r.Location == nil {
+		r.Location = time.UTC
+	}
+	title := "Medication report"
+	if r.Patient.Name != "" {
+		title += " for " + r.Patient.Name
+	}
+
+	l := &layout{doc: pdf.New(title)}
+	l.newPage()
+	l.page.Text(margin, l.y+18, pdf.Bold, 18, "Medication report")
+	l.y += 30
+	if r.Patient.Name != "" {
+		l.text(pdf.Regular, 11, "Patient: "+r.Patient.Name)
+	}
+	l.text(pdf.Regular, 11, fmt.Sprintf("Generated %s (%s)",
+		r.GeneratedAt.In(r.Location).Format(dateTimeFormat), r.Location))
+
+	l.heading("Current medicines")
+	if len(r.Medicines) == 0 {
+		l.text(pdf.Regular, textSize+1, "No current medicines.")
+	} else {
+		rows := make([][]string, len(r.Medicines))
+		for i, m := range r.Medicines {
+			rows[i] = medicineRow(m, r.GeneratedAt, r.Location)
+		}
+		l.table([]column{
+			{title: "Medicine", width: 95},
+			{title: "Dosage", width: 65},
+			{title: "Frequency", width: 75},
+			{title: "Times", width: 70},
+			{title: "Course", width: 85},
+			{title: "Notes", width: contentWidth - 390},
+		}, rows)
+	}
+
+	// The period ends just after its last moment, so show the day before a
+	// period that ends at midnight
+	last := r.To.In(r.Location)
+	if last.Equal(startOfDay(last)) {
+		last = last.Add(-time.Nanosecond)
+	}
+	l.heading(fmt.Sprintf("Adherence, %s to %s", r.From.In(r.Location).Format(dateFormat), last.Format(dateFormat)))
+	if len(r.Adherence) == 0 {
+		l.text(pdf.Regular, textSize+1, "No doses were scheduled in this period.")
+	} else {
+		var total Adherence
+		rows := make([][]string, 0, len(r.Adherence)+1)
+		for _, a := range r.Adherence {
+			rows = append(rows, adherenceRow(a.Name, a))
+			total = total.Add(a)
+		}
+		if len(r.Adherence) > 1 {
+			rows = append(rows, adherenceRow("All medicines", total))
+		}
+		l.table([]column{
+			{title: "Medicine", width: contentWidth - 300},
+			{title: "Scheduled", width: 60, right: true},
+			{title: "Taken", width: 60, right: true},
+			{title: "Skipped", width: 60, right: true},
+			{title: "Missed", width: 60, right: true},
+			{title: "Adherence", width: 60, right: true},
+		}, rows)
+		l.text(pdf.Regular, textSize-1, "Adherence is the share of scheduled doses that were logged as taken. "+
+			"As-needed medicines are not included.")
+	}
+
+	l.heading("Recent missed doses")
+	if len(r.Missed) == 0 {
+		l.text(pdf.Regular, textSize+1, "No missed doses in this period.")
+	} else {
+		rows := make([][]string, len(r.Missed))
+		for i, d := range r.Missed {
+			rows[i] = []string{d.ScheduledAt.Format(dateTimeFormat), d.Name, d.Dosage}
+		}
+		l.table([]column{
+			{title: "Scheduled", width: 130},
+			{title: "Medicine", width: 200},
+			{title: "Dosage", width: contentWidth - 330},
+		}, rows)
+	}
+
+	pages := l.doc.Pages()
+	for i, page := range pages {
+		page.Line(margin, footerY-12, pdf.PageWidth-margin, footerY-12, 0.5)
+		page.Text(margin, footerY, pdf.Regular, 8, title)
+		number := fmt.Sprintf("Page %d of %d", i+1, len(pages))
+		page.Text(pdf.PageWidth-margin-pdf.Width(number, pdf.Regular, 8), footerY, pdf.Regular, 8, number)
+	}
+
+	_, err := l.doc.WriteTo(w)
+	return err
+}
+
+// medicineRow returns the cells of a medicine in the current medicines table
+func medicineRow(m models.Medicine, now time.Time, loc *time.Location) []string {
+	name := m.Name
+	if m.Status == models.StatusPaused {
+		name += "\n(paused)"
+	}
+
+	dosage := m.Dosage
+	if p, ok := m.PhaseOn(now, loc); ok {
+		dosage = p.Dosage + fmt.Sprintf("\n(until %s)", p.EndDate.In(loc).Format(dateFormat))
+	}
+
+	times := m.TimeOfDay.Strings()
+	for _, rt := range m.RelativeTimes {
+		times = append(times, describeRelativeTime(rt))
+	}
+	if m.AsNeeded {
+		times = append(times, "As needed")
+		if m.MaxDosesPer24h != nil {
+			times = append(times, fmt.Sprintf("at most %d in 24h", *m.MaxDosesPer24h))
+		}
+	}
+
+	course := "From " + m.StartDate.In(loc).Format(dateFormat)
+	if m.EndDate != nil {
+		course += "\nto " + m.EndDate.In(loc).Format(dateFormat)
+	} else {
+		course += "\nongoing"
+	}
+
+	return []string{name, dosage, m.Frequency, strings.Join(times, "\n"), course, m.Notes}
+}
+
+// describeRelativeTime writes a relative dose time like "30 min before breakfast"
+func describeRelativeTime(r models.RelativeTime) string {
+	switch {
+	case r.OffsetMinutes < 0:
+		return fmt.Sprintf("%d min before %s", -r.OffsetMinutes, r.Event)
+	case r.OffsetMinutes > 0:
+		return fmt.Sprintf("%d min after %s", r.OffsetMinutes, r.Event)
+	default:
+		return "At " + r.Event
+	}
+}
+
+// adherenceRow returns the cells of a row of the adherence table
+func adherenceRow(name string, a Adherence) []string {
+	rate := "–"
+	if r, ok := a.Rate(); ok {
+		rate = fmt.Sprintf("%.0f%%", r*100)
+	}
+	return []string{name, fmt.Sprint(a.Scheduled), fmt.Sprint(a.Taken), fmt.Sprint(a.Skipped), fmt.Sprint(a.Missed), rate}
+}
+
+// startOfDay truncates t to midnight in its own location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// layout places content down the pages of a document, starting new pages as
+// they fill up
+type layout struct {
+	doc  *pdf.Document
+	page *pdf.Page
+	y    float64 // Top of the free space on the page
+}
+
+// newPage starts a new page
+func (l *layout) newPage() {
+	l.page = l.doc.AddPage()
+	l.y = margin
+}
+
+// ensure starts a new page unless height fits on the current one
+func (l *layout) ensure(height float64) {
+	if l.y+height > bottom {
+		l.newPage()
+	}
+}
+
+// text writes a paragraph wrapped to the page width
+func (l *layout) text(font pdf.Font, size float64, s string) {
+	for _, line := range pdf.Wrap(s, font, size, contentWidth) {
+		l.ensure(size + 4)
+		l.y += size + 4
+		l.page.Text(margin, l.y, font, size, line)
+	}
+	l.y += 4
+}
+
+// heading writes a section heading, keeping it on the page with some of the
+// section's content
+func (l *layout) heading(s string) {
+	l.ensure(70)
+	l.y += 22
+	l.page.Text(margin, l.y, pdf.Bold, 13, s)
+	l.y += 8
+}
+
+// table writes rows under a header row, repeating the header on each page
+// the table continues onto. Cells wrap within their columns.
+func (l *layout) table(columns []column, rows [][]string) {
+	header := func() {
+		height := lineHeight + 2*cellPadding
+		l.page.Rect(margin, l.y, contentWidth, height, 0.88)
+		l.cells(columns, headerCells(columns), pdf.Bold)
+	}
+
+	l.ensure(2 * (lineHeight + 2*cellPadding))
+	header()
+	for _, row := range rows {
+		wrapped := wrapRow(columns, row)
+		height := rowHeight(wrapped)
+		if l.y+height > bottom {
+			l.newPage()
+			header()
+		}
+		l.cells(columns, wrapped, pdf.Regular)
+		l.page.Line(margin, l.y, margin+contentWidth, l.y, 0.25)
+	}
+	l.y += 4
+}
+
+// headerCells returns the column titles as cells of one line each
+func headerCells(columns []column) [][]string {
+	cells := make([][]string, len(columns))
+	for i, c := range columns {
+		cells[i] = []string{c.title}
+	}
+	return cells
+}
+
+// wrapRow wraps each cell of a row to its column
+func wrapRow(columns []column, row []string) [][]string {
+	cells := make([][]string, len(columns))
+	for i, c := range columns {
+		cells[i] = pdf.Wrap(row[i], pdf.Regular, textSize, c.width-2*cellPadding)
+	}
+	return cells
+}
+
+// rowHeight returns the height of a row of wrapped cells
+func rowHeight(cells [][]string) float64 {
+	lines := 1
+	for _, c := range cells {
+		lines = max(lines, len(c))
+	}
+	return float64(lines)*lineHeight + 2*cellPadding
+}
+
+// cells writes a row of wrapped cells and moves below it
+func (l *layout) cells(columns []column, cells [][]string, font pdf.Font) {
+	x := margin
+	for i, c := range columns {
+		for j, line := range cells[i] {
+			if line == "" {
+				continue
+			}
+			lx := x + cellPadding
+			if c.right {
+				lx = x + c.width - cellPadding - pdf.Width(line, font, textSize)
+			}
+			l.page.Text(lx, l.y+cellPadding+float64(j+1)*lineHeight-2, font, textSize, line)
+		}
+		x += c.width
+	}
+	l.y += rowHeight(cells)
+}
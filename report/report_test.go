@@ -0,0 +1,130 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"medicine-reminder/models"
+	"medicine-reminder/reminders"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC)
+}
+
+func scheduledAt(times ...time.Time) []reminders.Reminder {
+	var scheduled []reminders.Reminder
+	for _, t := range times {
+		scheduled = append(scheduled, reminders.Reminder{MedicineID: 1, Dosage: "500mg", ScheduledAt: t})
+	}
+	return scheduled
+}
+
+func TestMatch(t *testing.T) {
+	m := models.Medicine{ID: 1, Name: "Metformin"}
+	scheduled := scheduledAt(at(1, 8, 0), at(1, 20, 0), at(2, 8, 0), at(2, 20, 0), at(3, 8, 0))
+	first := at(1, 8, 0)
+	doses := []models.Dose{
+		// Logged for its reminder, although late
+		{Status: models.DoseTaken, ScheduledAt: &first, TakenAt: at(1, 11, 30)},
+		// Logged without a reminder, close enough to the evening dose
+		{Status: models.DoseTaken, TakenAt: at(1, 21, 15)},
+		{Status: models.DoseSkipped, TakenAt: at(2, 7, 50)},
+		// Too far from any scheduled time to count
+		{Status: models.DoseTaken, TakenAt: at(2, 14, 0)},
+	}
+
+	a, missed := Match(m, scheduled, doses)
+	assert.Equal(t, Adherence{MedicineID: 1, Name: "Metformin", Scheduled: 5, Taken: 2, Skipped: 1, Missed: 2}, a)
+	rate, ok := a.Rate()
+	assert.True(t, ok)
+	assert.InDelta(t, 0.4, rate, 0.001)
+
+	assert.Equal(t, []MissedDose{
+		{MedicineID: 1, Name: "Metformin", Dosage: "500mg", ScheduledAt: at(2, 20, 0)},
+		{MedicineID: 1, Name: "Metformin", Dosage: "500mg", ScheduledAt: at(3, 8, 0)},
+	}, missed)
+}
+
+func TestMatchNearest(t *testing.T) {
+	// Hourly doses: a dose between two scheduled times counts for the nearer one
+	scheduled := scheduledAt(at(1, 8, 0), at(1, 9, 0))
+	a, missed := Match(models.Medicine{ID: 1}, scheduled, []models.Dose{
+		{Status: models.DoseTaken, TakenAt: at(1, 8, 50)},
+	})
+	assert.Equal(t, 1, a.Taken)
+	assert.Equal(t, at(1, 8, 0), missed[0].ScheduledAt)
+}
+
+func TestRateWithoutSchedule(t *testing.T) {
+	_, ok := Adherence{}.Rate()
+	assert.False(t, ok)
+}
+
+func TestRender(t *testing.T) {
+	patient := 4
+	end := at(31, 0, 0)
+	r := Report{
+		Patient:     models.Patient{ID: 4, Name: "Ada Lovelace"},
+		GeneratedAt: at(15, 9, 30),
+		From:        at(1, 0, 0),
+		To:          at(15, 0, 0),
+		Medicines: []models.Medicine{
+			{
+				ID: 1, Name: "Metformin", Dosage: "500mg", Frequency: "Twice daily",
+				TimeOfDay: models.TimesOfDay{8 * 60, 20 * 60}, StartDate: at(1, 0, 0), EndDate: &end,
+				Notes: "Take with food (breakfast and dinner)", PatientID: &patient, Status: models.StatusActive,
+			},
+			{
+				ID: 2, Name: "Salbutamol", Dosage: "2 puffs", AsNeeded: true, StartDate: at(1, 0, 0),
+				RelativeTimes: models.RelativeTimes{{Event: models.AnchorBedtime, OffsetMinutes: -30}},
+				Status:        models.StatusPaused,
+			},
+		},
+		Adherence: []Adherence{{MedicineID: 1, Name: "Metformin", Scheduled: 28, Taken: 21, Skipped: 2, Missed: 5}},
+		Missed:    []MissedDose{{MedicineID: 1, Name: "Metformin", Dosage: "500mg", ScheduledAt: at(14, 20, 0)}},
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, Render(&out, r))
+	pdf := out.String()
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-"))
+	for _, text := range []string{
+		"(Medication report)",
+		"(Patient: Ada Lovelace)",
+		"(Metformin)",
+		"(08:00)",
+		"(From 1 Mar 2024)",
+		"(to 31 Mar 2024)",
+		"(ongoing)",
+		"(\\(paused\\))",
+		"(30 min before)",
+		"(Adherence, 1 Mar 2024 to 14 Mar 2024)",
+		"(75%)",
+		"(Thu 14 Mar 2024 20:00)",
+		"(Page 1 of 1)",
+	} {
+		assert.Contains(t, pdf, text)
+	}
+}
+
+func TestRenderPages(t *testing.T) {
+	r := Report{GeneratedAt: at(15, 9, 30), From: at(1, 0, 0), To: at(15, 0, 0)}
+	for i := 0; i < 120; i++ {
+		r.Missed = append(r.Missed, MissedDose{Name: fmt.Sprintf("Medicine %d", i), ScheduledAt: at(14, 8, 0)})
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, Render(&out, r))
+	pdf := out.String()
+	assert.Contains(t, pdf, "(No current medicines.)")
+	assert.Contains(t, pdf, "(Page 4 of 4)")
+	assert.Contains(t, pdf, "(Medicine 119)")
+	assert.NotContains(t, pdf, "() Tj")
+	// The table header is repeated on each page
+	assert.Equal(t, 4, strings.Count(pdf, "(Scheduled)"))
+}
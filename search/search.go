@@ -0,0 +1,293 @@
+// Package search finds medicines by the words of their name, dosage and notes.
+// It follows the database's Postgres full-text search for stores without it:
+// words are matched by their stem, common English words are ignored, quoted
+// phrases must appear in order, "or" gives alternatives and a leading "-"
+// excludes a word.
+package search
+
+import (
+	"medicine-reminder/models"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Highlight markers put around matching words, as with ts_headline
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// maxSnippetWords is the longest highlighted snippet, matching ts_headline's MaxWords
+const maxSnippetWords = 35
+
+// Result is a medicine matching a search
+type Result struct {
+	models.Medicine
+	Rank float64 `json:"rank"` // Higher is a better match; only comparable within one search
+	// Fields containing a match, with matching words marked, keyed by
+	// "name", "dosage" or "notes". Long notes are cut to the matching part.
+	Highlights map[string]string `json:"highlights"`
+}
+
+// fields are the searched fields with their weights, mirroring the A, B and C
+// weights of the search vector and ts_rank's default weight for each
+var fields = []struct {
+	name   string
+	weight float64
+	value  func(models.Medicine) string
+}{
+	{"name", 1.0, func(m models.Medicine) string { return m.Name }},
+	{"dosage", 0.4, func(m models.Medicine) string { return m.Dosage }},
+	{"notes", 0.2, func(m models.Medicine) string { return m.Notes }},
+}
+
+// Query is a parsed search
+type Query struct {
+	clauses []clause
+}
+
+// clause is matched when any of its phrases appears, or for a negated clause
+// when none does. A phrase is a list of stems that must appear consecutively.
+type clause struct {
+	phrases [][]string
+	negated bool
+}
+
+// Empty reports whether the query has nothing to search for, e.g. when it is
+// made only of common words
+func (q Query) Empty() bool {
+	return len(q.clauses) == 0
+}
+
+// Parse parses a web-style search such as `crushed "after meals" -liquid`
+func Parse(s string) Query {
+	var q Query
+	pending := -1 // Index of the clause that an "or" adds to
+	orNext := false
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negated := false
+		if runes[i] == '-' {
+			negated = true
+			i++
+		}
+
+		var text string
+		quoted := i < len(runes) && runes[i] == '"'
+		if quoted {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			text = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			text = string(runes[i:end])
+			i = end
+		}
+
+		if !quoted && !negated && strings.EqualFold(text, "or") {
+			orNext = pending >= 0
+			continue
+		}
+
+		phrase := phraseOf(text)
+		if len(phrase) == 0 {
+			continue
+		}
+		if orNext && !negated {
+			q.clauses[pending].phrases = append(q.clauses[pending].phrases, phrase)
+			orNext = false
+			continue
+		}
+		q.clauses = append(q.clauses, clause{phrases: [][]string{phrase}, negated: negated})
+		pending = -1
+		if !negated {
+			pending = len(q.clauses) - 1
+		}
+		orNext = false
+	}
+	return q
+}
+
+// Search returns the medicines matching the query, best match first and then
+// by ID, with at most limit results
+func Search(medicines []models.Medicine, q Query, limit int) []Result {
+	results := []Result{}
+	if q.Empty() {
+		return results
+	}
+
+	for _, m := range medicines {
+		if r, ok := Match(m, q); ok {
+			results = append(results, r)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Match reports whether a medicine matches the query, ranking and
+// highlighting it when it does
+func Match(m models.Medicine, q Query) (Result, bool) {
+	r := Result{Medicine: m, Highlights: map[string]string{}}
+	if q.Empty() {
+		return r, false
+	}
+
+	docs := make([][]token, len(fields))
+	for i, f := range fields {
+		docs[i] = tokenize(f.value(m))
+	}
+
+	marked := make([]map[int]bool, len(fields))
+	for i := range marked {
+		marked[i] = map[int]bool{}
+	}
+
+	for _, c := range q.clauses {
+		found := false
+		for i, f := range fields {
+			for _, phrase := range c.phrases {
+				for _, words := range occurrences(docs[i], phrase) {
+					found = true
+					if c.negated {
+						continue
+					}
+					r.Rank += f.weight
+					for _, w := range words {
+						marked[i][w] = true
+					}
+				}
+			}
+		}
+		if found == c.negated {
+			return r, false
+		}
+	}
+
+	for i, f := range fields {
+		if len(marked[i]) > 0 {
+			r.Highlights[f.name] = highlight(f.value(m), docs[i], marked[i])
+		}
+	}
+	return r, true
+}
+
+// token is a word of a field. Stop words are kept so snippets can be cut at
+// words, but have no stem.
+type token struct {
+	stem       string
+	start, end int // Byte offsets of the word in the field
+}
+
+// tokenize splits text into words
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{stem: stem(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// phraseOf returns the stems of the words in text. Stop words within the
+// phrase are kept as empty stems that match any word, so a phrase keeps its
+// spacing as in Postgres.
+func phraseOf(text string) []string {
+	var phrase []string
+	for _, t := range tokenize(text) {
+		phrase = append(phrase, t.stem)
+	}
+	for len(phrase) > 0 && phrase[0] == "" {
+		phrase = phrase[1:]
+	}
+	for len(phrase) > 0 && phrase[len(phrase)-1] == "" {
+		phrase = phrase[:len(phrase)-1]
+	}
+	return phrase
+}
+
+// occurrences returns the token indexes of the words of each occurrence of
+// phrase, leaving out the stop words it skips
+func occurrences(tokens []token, phrase []string) [][]int {
+	var found [][]int
+	for start := 0; start+len(phrase) <= len(tokens); start++ {
+		var words []int
+		for k, s := range phrase {
+			if s == "" {
+				continue
+			}
+			if tokens[start+k].stem != s {
+				words = nil
+				break
+			}
+			words = append(words, start+k)
+		}
+		if words != nil {
+			found = append(found, words)
+		}
+	}
+	return found
+}
+
+// highlight marks the marked tokens of text. Text longer than a snippet is cut
+// to the words around the first match.
+func highlight(text string, tokens []token, marked map[int]bool) string {
+	first, last := 0, len(tokens)
+	if len(tokens) > maxSnippetWords {
+		firstMatch := len(tokens)
+		for i := range marked {
+			firstMatch = min(firstMatch, i)
+		}
+		first = max(0, min(firstMatch-5, len(tokens)-maxSnippetWords))
+		last = first + maxSnippetWords
+	}
+
+	var b strings.Builder
+	pos := 0
+	if first > 0 {
+		pos = tokens[first].start
+	}
+	for i := first; i < last; i++ {
+		t := tokens[i]
+		b.WriteString(text[pos:t.start])
+		if marked[i] {
+			b.WriteString(HighlightStart + text[t.start:t.end] + HighlightEnd)
+		} else {
+			b.WriteString(text[t.start:t.end])
+		}
+		pos = t.end
+	}
+	if last == len(tokens) {
+		b.WriteString(text[pos:])
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"medicine-reminder/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStem(t *testing.T) {
+	assert.Equal(t, stem("meal"), stem("Meals"))
+	assert.Equal(t, stem("crush"), stem("crushed"))
+	assert.Equal(t, stem("take"), stem("taking"))
+	assert.Equal(t, stem("dose"), stem("doses"))
+	assert.Equal(t, stem("allergy"), stem("allergies"))
+	assert.Equal(t, "", stem("after"))
+}
+
+func TestParse(t *testing.T) {
+	assert.True(t, Parse("after the").Empty())
+
+	q := Parse(`crushed "after meals" or food -liquid`)
+	assert.Equal(t, []clause{
+		{phrases: [][]string{{"crush"}}},
+		{phrases: [][]string{{"meal"}, {"food"}}},
+		{phrases: [][]string{{"liquid"}}, negated: true},
+	}, q.clauses)
+}
+
+func TestSearch(t *testing.T) {
+	medicines := []models.Medicine{
+		{ID: 1, Name: "Metformin", Dosage: "500mg", Notes: "Take with meals"},
+		{ID: 2, Name: "Paracetamol", Dosage: "500mg", Notes: "May be crushed and mixed with food"},
+		{ID: 3, Name: "Omeprazole", Dosage: "20mg", Notes: "Take before breakfast, do not crush"},
+		{ID: 4, Name: "Amoxicillin", Dosage: "250mg liquid", Notes: "Take after meals; can be crushed"},
+	}
+
+	results := Search(medicines, Parse("after meals"), 10)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "Take with <mark>meals</mark>", results[0].Highlights["notes"])
+
+	// Matches in the name rank above matches in the notes
+	results = Search(append(medicines, models.Medicine{ID: 5, Name: "Meal replacement"}), Parse("meal"), 10)
+	assert.Equal(t, 5, results[0].ID)
+	assert.Equal(t, "<mark>Meal</mark> replacement", results[0].Highlights["name"])
+
+	results = Search(medicines, Parse("crushed -liquid"), 10)
+	assert.Equal(t, []int{2, 3}, ids(results))
+
+	results = Search(medicines, Parse(`"mixed food"`), 10)
+	assert.Empty(t, results)
+	results = Search(medicines, Parse(`"mixed with food"`), 10)
+	assert.Equal(t, []int{2}, ids(results))
+	assert.Equal(t, "May be crushed and <mark>mixed</mark> with <mark>food</mark>", results[0].Highlights["notes"])
+
+	results = Search(medicines, Parse("breakfast or food"), 1)
+	assert.Len(t, results, 1)
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	notes := "one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen " +
+		"sixteen seventeen eighteen nineteen twenty twenty-one twenty-two twenty-three twenty-four " +
+		"twenty-five twenty-six twenty-seven twenty-eight twenty-nine thirty thirty-one thirty-two crush here end"
+	r, ok := Match(models.Medicine{Notes: notes}, Parse("crush"))
+	assert.True(t, ok)
+	assert.Contains(t, r.Highlights["notes"], "<mark>crush</mark> here end")
+	assert.NotContains(t, r.Highlights["notes"], "one two")
+}
+
+func ids(results []Result) []int {
+	var ids []int
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
@@ -0,0 +1,48 @@
+package search
+
+import "strings"
+
+// stopWords are common English words left out of searches, a subset of
+// Postgres's English stop words
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a about above after again against all am an and any are as at
+		be because been before being below between both but by can could did do does doing down
+		during each few for from further had has have having he her here hers him his how i if in
+		into is it its itself just me more most my no nor not now of off on once only or other our
+		out over own same she should so some such than that the their them then there these they
+		this those through to too under until up very was we were what when where which while who
+		whom why will with would you your`) {
+		stopWords[w] = true
+	}
+}
+
+// stem lower-cases a word and strips common English suffixes so forms of the
+// same word match, e.g. "meals" and "meal" or "crushed" and "crush". Stop
+// words have no stem.
+func stem(word string) string {
+	w := strings.ToLower(word)
+	if stopWords[w] {
+		return ""
+	}
+
+	switch {
+	case strings.HasSuffix(w, "sses"):
+		w = strings.TrimSuffix(w, "es")
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		w = strings.TrimSuffix(w, "ies") + "y"
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && len(w) > 3:
+		w = strings.TrimSuffix(w, "s")
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= 3 {
+			w = strings.TrimSuffix(w, suffix)
+			break
+		}
+	}
+	if strings.HasSuffix(w, "e") && len(w) > 3 {
+		w = strings.TrimSuffix(w, "e")
+	}
+	return w
+}
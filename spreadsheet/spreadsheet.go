@@ -0,0 +1,421 @@
+// Package spreadsheet converts medicines to and from CSV rows so they can be
+// exported to and imported from spreadsheets
+package spreadsheet
+
+import (
+	"encoding/json"
+	"fmt"
+	"medicine-reminder/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns lists the columns of an export in order. Imports take the same
+// columns, except that id and status are ignored.
+var Columns = []string{
+	"id", "patient_id", "name", "dosage", "frequency", "time_of_day", "start_date", "end_date", "notes",
+	"quiet_hours_policy", "schedule_type", "stock_quantity", "units_per_dose", "refill_threshold_days",
+	"as_needed", "max_doses_per_24h", "min_dose_interval_minutes", "drug_id", "review_interval_days",
+	"relative_times", "phases", "status",
+}
+
+// importOnlyColumns can be imported but aren't exported
+var importOnlyColumns = []string{"acknowledge_interactions", "acknowledge_allergies"}
+
+// readOnlyColumns are exported but ignored on import
+var readOnlyColumns = map[string]bool{"id": true, "status": true}
+
+// aliases maps other common header names to columns
+var aliases = map[string]string{
+	"medicine":      "name",
+	"medication":    "name",
+	"drug":          "name",
+	"dose":          "dosage",
+	"strength":      "dosage",
+	"times":         "time_of_day",
+	"times_of_day":  "time_of_day",
+	"time":          "time_of_day",
+	"start":         "start_date",
+	"end":           "end_date",
+	"stop_date":     "end_date",
+	"patient":       "patient_id",
+	"stock":         "stock_quantity",
+	"prn":           "as_needed",
+	"review_days":   "review_interval_days",
+	"instructions":  "notes",
+	"refill_days":   "refill_threshold_days",
+	"units":         "units_per_dose",
+	"catalog_id":    "drug_id",
+	"max_doses":     "max_doses_per_24h",
+	"min_interval":  "min_dose_interval_minutes",
+	"quiet_hours":   "quiet_hours_policy",
+	"schedule":      "schedule_type",
+	"medicine_name": "name",
+}
+
+// textColumns hold free text, which could be read as a formula when the
+// export is opened in a spreadsheet
+var textColumns = map[string]bool{"name": true, "dosage": true, "frequency": true, "notes": true, "drug_id": true}
+
+// formulaPrefixes are the characters that make spreadsheets treat a cell as a
+// formula
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula puts a ' in front of a value that would otherwise be read as a
+// formula, so spreadsheets show it as text
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeFormula removes the ' escapeFormula adds
+func unescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// Record returns a medicine as a row of Columns. Times of day are separated
+// by semicolons and dates at midnight UTC are written without a time.
+// Relative times and dose phases are written as JSON arrays, as in the API.
+// Text that would be read as a formula is escaped with a leading '.
+func Record(m models.Medicine) []string {
+	record := make([]string, 0, len(Columns))
+	for _, column := range Columns {
+		var value string
+		switch column {
+		case "id":
+			value = strconv.Itoa(m.ID)
+		case "patient_id":
+			value = formatInt(m.PatientID)
+		case "name":
+			value = m.Name
+		case "dosage":
+			value = m.Dosage
+		case "frequency":
+			value = m.Frequency
+		case "time_of_day":
+			value = strings.Join(m.TimeOfDay.Strings(), ";")
+		case "start_date":
+			value = formatDate(m.StartDate)
+		case "end_date":
+			if m.EndDate != nil {
+				value = formatDate(*m.EndDate)
+			}
+		case "notes":
+			value = m.Notes
+		case "quiet_hours_policy":
+			value = m.QuietHoursPolicy
+		case "schedule_type":
+			value = m.ScheduleType
+		case "stock_quantity":
+			if m.StockQuantity != nil {
+				value = strconv.FormatFloat(*m.StockQuantity, 'f', -1, 64)
+			}
+		case "units_per_dose":
+			value = strconv.FormatFloat(m.UnitsPerDose, 'f', -1, 64)
+		case "refill_threshold_days":
+			value = strconv.Itoa(m.RefillThresholdDays)
+		case "as_needed":
+			value = strconv.FormatBool(m.AsNeeded)
+		case "max_doses_per_24h":
+			value = formatInt(m.MaxDosesPer24h)
+		case "min_dose_interval_minutes":
+			value = formatInt(m.MinDoseIntervalMinutes)
+		case "drug_id":
+			if m.DrugID != nil {
+				value = *m.DrugID
+			}
+		case "review_interval_days":
+			value = formatInt(m.ReviewIntervalDays)
+		case "relative_times":
+			if len(m.RelativeTimes) > 0 {
+				value = formatJSON(m.RelativeTimes)
+			}
+		case "phases":
+			if len(m.Phases) > 0 {
+				value = formatJSON(m.Phases)
+			}
+		case "status":
+			value = m.Status
+		}
+		if textColumns[column] {
+			value = escapeFormula(value)
+		}
+		record = append(record, value)
+	}
+	return record
+}
+
+// formatInt formats an optional number, leaving it empty when unset
+func formatInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// formatJSON encodes a value that marshals without error, such as a slice of
+// plain structs
+func formatJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// formatDate formats a course date as YYYY-MM-DD, or as RFC 3339 if it has a time
+func formatDate(t time.Time) string {
+	t = t.UTC()
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Header maps the columns of an imported file to medicine fields
+type Header struct {
+	fields  []string // Field of each column, empty if the column is ignored
+	Ignored []string // Columns that don't match a field
+}
+
+// ParseHeader matches the column names of an import to fields. Names are
+// matched ignoring case, spaces and dashes, and common alternatives such as
+// "medication" or "start" are understood. mapping gives the field of columns
+// that don't match, e.g. {"Rx": "name"}.
+func ParseHeader(names []string, mapping map[string]string) (Header, error) {
+	known := map[string]bool{}
+	for _, column := range append(Columns, importOnlyColumns...) {
+		known[column] = true
+	}
+	for column, field := range mapping {
+		if !known[field] {
+			return Header{}, fmt.Errorf("column %q is mapped to unknown field %q", column, field)
+		}
+	}
+
+	h := Header{fields: make([]string, len(names))}
+	seen := map[string]string{}
+	for i, name := range names {
+		// Excel starts UTF-8 files with a byte order mark
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		field, ok := mapping[name]
+		if !ok {
+			field = normalize(name)
+			if alias, ok := aliases[field]; ok {
+				field = alias
+			}
+		}
+		if !known[field] || readOnlyColumns[field] {
+			if !readOnlyColumns[field] {
+				h.Ignored = append(h.Ignored, name)
+			}
+			continue
+		}
+		if other, ok := seen[field]; ok {
+			return Header{}, fmt.Errorf("columns %q and %q are both %s", other, name, field)
+		}
+		seen[field] = name
+		h.fields[i] = field
+	}
+	if len(seen) == 0 {
+		return Header{}, fmt.Errorf("no column matches a medicine field")
+	}
+	return h, nil
+}
+
+// normalize turns a header name like "Start Date" into a field name
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// Input reads a row into a medicine input, returning an error for each value
+// that can't be read. Dates are read with ParseDate using order.
+func (h Header) Input(record []string, order string) (models.MedicineInput, []string) {
+	var input models.MedicineInput
+	var errs []string
+	fail := func(field string, err error) {
+		errs = append(errs, fmt.Sprintf("%s: %v", field, err))
+	}
+
+	for i, field := range h.fields {
+		if field == "" || i >= len(record) {
+			continue
+		}
+		value := strings.TrimSpace(unescapeFormula(record[i]))
+		if value == "" {
+			continue
+		}
+
+		var err error
+		switch field {
+		case "name":
+			input.Name = value
+		case "dosage":
+			input.Dosage = value
+		case "frequency":
+			input.Frequency = value
+		case "time_of_day":
+			input.TimeOfDay = splitTimes(value)
+		case "start_date":
+			input.StartDate, err = ParseDate(value, order)
+		case "end_date":
+			input.EndDate, err = ParseDate(value, order)
+		case "notes":
+			input.Notes = value
+		case "quiet_hours_policy":
+			input.QuietHoursPolicy = value
+		case "schedule_type":
+			input.ScheduleType = value
+		case "patient_id":
+			input.PatientID, err = parseInt(value)
+		case "refill_threshold_days":
+			input.RefillThresholdDays, err = parseInt(value)
+		case "max_doses_per_24h":
+			input.MaxDosesPer24h, err = parseInt(value)
+		case "min_dose_interval_minutes":
+			input.MinDoseIntervalMinutes, err = parseInt(value)
+		case "review_interval_days":
+			input.ReviewIntervalDays, err = parseInt(value)
+		case "stock_quantity":
+			var n float64
+			if n, err = strconv.ParseFloat(value, 64); err == nil {
+				input.StockQuantity = &n
+			}
+		case "units_per_dose":
+			input.UnitsPerDose, err = strconv.ParseFloat(value, 64)
+		case "drug_id":
+			input.DrugID = &value
+		case "relative_times":
+			err = parseJSON(value, &input.RelativeTimes)
+		case "phases":
+			err = parseJSON(value, &input.Phases)
+		case "as_needed":
+			input.AsNeeded, err = parseBool(value)
+		case "acknowledge_interactions":
+			input.AcknowledgeInteractions, err = parseBool(value)
+		case "acknowledge_allergies":
+			input.AcknowledgeAllergies, err = parseBool(value)
+		}
+		if err != nil {
+			if _, ok := err.(*strconv.NumError); ok {
+				err = fmt.Errorf("%q is not a number", value)
+			}
+			fail(field, err)
+		}
+	}
+	return input, errs
+}
+
+// splitTimes splits a list of times of day separated by semicolons, commas,
+// pipes or line breaks
+func splitTimes(value string) []string {
+	var times []string
+	for _, t := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ',' || r == '|' || r == '\n'
+	}) {
+		if t = strings.TrimSpace(t); t != "" {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// parseInt parses an optional whole number
+func parseInt(value string) (*int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// parseJSON reads a JSON array cell into v
+func parseJSON(value string, v interface{}) error {
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return fmt.Errorf("%q is not a JSON list", value)
+	}
+	return nil
+}
+
+// parseBool parses true/false, yes/no, y/n or 1/0
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "1":
+		return true, nil
+	case "false", "no", "n", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not yes or no", value)
+}
+
+// Orders of the day and month in numeric dates like 03/04/2024
+const (
+	DayFirst   = "dmy"
+	MonthFirst = "mdy"
+)
+
+// dateLayouts are the unambiguous date formats ParseDate accepts
+var dateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02",
+	"2 Jan 2006",
+	"2 January 2006",
+	"2-Jan-2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"Jan 2 2006",
+	"January 2 2006",
+}
+
+// numericDate matches dates like 03/04/2024, 3.4.2024 or 03-04-2024
+var numericDate = regexp.MustCompile(`^(\d{1,2})[/.\-](\d{1,2})[/.\-](\d{4})$`)
+
+// ParseDate parses a date in one of the common spreadsheet formats. Numeric
+// dates like 03/04/2024 are read in the given order, DayFirst or MonthFirst;
+// without one, they must be unambiguous, e.g. 25/04/2024. Dates without a
+// time zone are UTC.
+func ParseDate(s, order string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	m := numericDate.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("%q is not a date", s)
+	}
+	first, _ := strconv.Atoi(m[1])
+	second, _ := strconv.Atoi(m[2])
+	year, _ := strconv.Atoi(m[3])
+
+	if order == "" {
+		switch {
+		case first == second || second > 12:
+			order = MonthFirst
+		case first > 12:
+			order = DayFirst
+		default:
+			return time.Time{}, fmt.Errorf("%q could be day or month first; set the date order", s)
+		}
+	}
+	day, month := first, second
+	if order == MonthFirst {
+		day, month = second, first
+	}
+
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != day || int(t.Month()) != month {
+		return time.Time{}, fmt.Errorf("%q is not a date", s)
+	}
+	return t, nil
+}
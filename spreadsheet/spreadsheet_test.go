@@ -0,0 +1,180 @@
+package spreadsheet
+
+import (
+	"medicine-reminder/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDate(t *testing.T) {
+	march4 := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	for _, s := range []string{"2024-03-04", "2024/03/04", "4 Mar 2024", "4 March 2024", "4-Mar-2024",
+		"Mar 4, 2024", "March 4, 2024", "Mar 4 2024", "04/03/2024", "4.3.2024"} {
+		d, err := ParseDate(s, DayFirst)
+		assert.NoError(t, err, s)
+		assert.Equal(t, march4, d, s)
+	}
+
+	d, err := ParseDate("03/04/2024", MonthFirst)
+	assert.NoError(t, err)
+	assert.Equal(t, march4, d)
+
+	d, err = ParseDate("2024-03-04T09:30:00+01:00", "")
+	assert.NoError(t, err)
+	assert.True(t, d.Equal(time.Date(2024, 3, 4, 8, 30, 0, 0, time.UTC)))
+
+	// Numeric dates must be unambiguous without an order
+	d, err = ParseDate("25/03/2024", "")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC), d)
+	d, err = ParseDate("03/25/2024", "")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC), d)
+	_, err = ParseDate("03/04/2024", "")
+	assert.ErrorContains(t, err, "date order")
+
+	for _, bad := range []string{"", "soon", "31/02/2024", "13/13/2024", "2024-02-30"} {
+		_, err := ParseDate(bad, DayFirst)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	h, err := ParseHeader([]string{"\ufeffMedication", "Dose", "Start Date", "times", "ID", "Colour", "Rx Notes"},
+		map[string]string{"Rx Notes": "notes"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"name", "dosage", "start_date", "time_of_day", "", "", "notes"}, h.fields)
+	assert.Equal(t, []string{"Colour"}, h.Ignored)
+
+	_, err = ParseHeader([]string{"name", "Medicine"}, nil)
+	assert.ErrorContains(t, err, "both name")
+	_, err = ParseHeader([]string{"Colour"}, nil)
+	assert.Error(t, err)
+	_, err = ParseHeader([]string{"Rx"}, map[string]string{"Rx": "colour"})
+	assert.Error(t, err)
+}
+
+func TestHeaderInput(t *testing.T) {
+	h, err := ParseHeader([]string{"name", "dosage", "time_of_day", "start_date", "end_date", "stock", "prn", "patient_id"}, nil)
+	assert.NoError(t, err)
+
+	input, errs := h.Input([]string{"Amoxicillin", "500mg", "08:00; 14:00 |20:00", "01/03/2024", "", "21", "no", ""}, DayFirst)
+	assert.Empty(t, errs)
+	assert.Equal(t, "Amoxicillin", input.Name)
+	assert.Equal(t, []string{"08:00", "14:00", "20:00"}, input.TimeOfDay)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), input.StartDate)
+	assert.True(t, input.EndDate.IsZero())
+	assert.Equal(t, 21.0, *input.StockQuantity)
+	assert.False(t, input.AsNeeded)
+	assert.Nil(t, input.PatientID)
+
+	_, errs = h.Input([]string{"Amoxicillin", "500mg", "08:00", "someday", "", "lots", "maybe", "x"}, DayFirst)
+	assert.Len(t, errs, 4)
+	assert.Contains(t, errs, `stock_quantity: "lots" is not a number`)
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	times, err := models.ParseTimesOfDay([]string{"08:00", "20:00"})
+	assert.NoError(t, err)
+	patientID, review := 3, 180
+	m := models.Medicine{
+		ID:                  9,
+		PatientID:           &patientID,
+		Name:                "Atorvastatin",
+		Dosage:              "20mg",
+		Frequency:           "Twice daily",
+		TimeOfDay:           times,
+		StartDate:           time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Notes:               "Evening, with water",
+		QuietHoursPolicy:    models.QuietHoursDefer,
+		ScheduleType:        models.ScheduleWallClock,
+		UnitsPerDose:        1,
+		RefillThresholdDays: 7,
+		ReviewIntervalDays:  &review,
+		Status:              models.StatusActive,
+	}
+
+	record := Record(m)
+	assert.Len(t, record, len(Columns))
+	assert.Equal(t, "08:00;20:00", record[5])
+	assert.Equal(t, "2024-03-01", record[6])
+	assert.Equal(t, "", record[7])
+
+	h, err := ParseHeader(Columns, nil)
+	assert.NoError(t, err)
+	input, errs := h.Input(record, "")
+	assert.Empty(t, errs)
+	assert.Equal(t, m.Name, input.Name)
+	assert.Equal(t, m.PatientID, input.PatientID)
+	assert.Equal(t, m.TimeOfDay.Strings(), input.TimeOfDay)
+	assert.Equal(t, m.StartDate, input.StartDate)
+	assert.True(t, input.EndDate.IsZero())
+	assert.Equal(t, m.Notes, input.Notes)
+	assert.Equal(t, m.ReviewIntervalDays, input.ReviewIntervalDays)
+}
+
+func TestRecordEscapesFormulas(t *testing.T) {
+	m := models.Medicine{
+		Name:      `=HYPERLINK("http://example.com","Aspirin")`,
+		Dosage:    "-1 tablet",
+		Frequency: "@daily",
+		Notes:     "+1 with food",
+		StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	record := Record(m)
+	assert.Equal(t, `'=HYPERLINK("http://example.com","Aspirin")`, record[2])
+	assert.Equal(t, "'-1 tablet", record[3])
+	assert.Equal(t, "'@daily", record[4])
+	assert.Equal(t, "'+1 with food", record[8])
+
+	// Importing the export reads the original values
+	h, err := ParseHeader(Columns, nil)
+	assert.NoError(t, err)
+	input, errs := h.Input(record, "")
+	assert.Empty(t, errs)
+	assert.Equal(t, m.Name, input.Name)
+	assert.Equal(t, m.Dosage, input.Dosage)
+	assert.Equal(t, m.Frequency, input.Frequency)
+	assert.Equal(t, m.Notes, input.Notes)
+
+	// Other apostrophes are kept
+	record[2] = "'Tis the season"
+	input, _ = h.Input(record, "")
+	assert.Equal(t, "'Tis the season", input.Name)
+}
+
+func TestRecordRoundTripSchedules(t *testing.T) {
+	morning, err := models.ParseTimesOfDay([]string{"08:00"})
+	assert.NoError(t, err)
+	m := models.Medicine{
+		Name:          "Prednisolone",
+		Dosage:        "40mg",
+		Frequency:     "Once daily",
+		StartDate:     time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		RelativeTimes: models.RelativeTimes{{Event: "breakfast", OffsetMinutes: 30}},
+		Phases: models.DosePhases{
+			{StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC), Dosage: "40mg"},
+			{StartDate: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), Dosage: "20mg", TimeOfDay: morning},
+		},
+	}
+
+	// Medicines timed only by relative times come back with them
+	record := Record(m)
+	h, err := ParseHeader(Columns, nil)
+	assert.NoError(t, err)
+	input, errs := h.Input(record, "")
+	assert.Empty(t, errs)
+	assert.Empty(t, input.TimeOfDay)
+	assert.Equal(t, []models.RelativeTime(m.RelativeTimes), input.RelativeTimes)
+	assert.Len(t, input.Phases, 2)
+	assert.Equal(t, "20mg", input.Phases[1].Dosage)
+	assert.Equal(t, []string{"08:00"}, input.Phases[1].TimeOfDay)
+	assert.Equal(t, m.Phases[1].EndDate, input.Phases[1].EndDate)
+
+	record[len(Columns)-2] = "not json"
+	_, errs = h.Input(record, "")
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,108 @@
+// Package trash purges deleted medicines once they have been in the trash
+// longer than the retention period. Until then they can be restored.
+package trash
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"medicine-reminder/audit"
+	"medicine-reminder/database"
+	"medicine-reminder/models"
+	"strconv"
+	"time"
+)
+
+// DefaultRetention is how long deleted medicines are kept unless configured
+const DefaultRetention = 30 * 24 * time.Hour
+
+// Retention is how long deleted medicines are kept before they are purged
+var Retention = DefaultRetention
+
+// ParseRetention parses a retention period given as a number of days
+func ParseRetention(days string) (time.Duration, error) {
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("retention must be a positive number of days, got %q", days)
+	}
+	return time.Duration(n) * 24 * time.Hour, nil
+}
+
+// PurgeAt returns when a medicine deleted at deletedAt will be purged
+func PurgeAt(deletedAt time.Time) time.Time {
+	return deletedAt.Add(Retention)
+}
+
+// Purger periodically purges medicines deleted longer than the retention period ago
+type Purger struct {
+	interval time.Duration
+}
+
+// NewPurger creates a purger that looks for expired medicines every interval
+func NewPurger(interval time.Duration) *Purger {
+	return &Purger{interval: interval}
+}
+
+// Run purges expired medicines until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.Purge(time.Now()); err != nil {
+			log.Printf("Error purging deleted medicines: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d deleted medicines", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge permanently deletes the medicines whose retention period has passed
+// by now, along with their doses, inventory and alerts, returning how many
+// were purged. Each purge is recorded in the audit log with the medicine's
+// last state.
+func (p *Purger) Purge(now time.Time) (int64, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT "+database.MedicineColumns+" FROM medicines WHERE deleted_at <= $1 FOR UPDATE",
+		now.Add(-Retention))
+	if err != nil {
+		return 0, err
+	}
+	var expired []models.Medicine
+	for rows.Next() {
+		m, err := database.ScanMedicine(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, m := range expired {
+		if _, err := tx.Exec("DELETE FROM medicines WHERE id = $1", m.ID); err != nil {
+			return 0, err
+		}
+		if err := audit.Record(tx, models.AuditPurge, audit.SystemActor, "purge", &m, m); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(expired)), nil
+}
@@ -0,0 +1,24 @@
+package trash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetention(t *testing.T) {
+	retention, err := ParseRetention("7")
+	assert.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, retention)
+
+	for _, bad := range []string{"", "0", "-3", "1.5", "week"} {
+		_, err := ParseRetention(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPurgeAt(t *testing.T) {
+	deletedAt := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 5, 31, 9, 30, 0, 0, time.UTC), PurgeAt(deletedAt))
+}